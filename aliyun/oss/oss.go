@@ -10,9 +10,9 @@ import (
 )
 
 type Conf struct {
-	Endpoint     string
-	AccessKey    string
-	AccessSecret string
+	Endpoint     string `mapstructure:"endpoint"`
+	AccessKey    string `mapstructure:"accessKey"`
+	AccessSecret string `mapstructure:"accessSecret" log:"secret"`
 }
 
 type Client struct {
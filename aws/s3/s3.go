@@ -13,9 +13,9 @@ import (
 )
 
 type Conf struct {
-	Region       string
-	AccessKey    string
-	AccessSecret string
+	Region       string `mapstructure:"region"`
+	AccessKey    string `mapstructure:"accessKey"`
+	AccessSecret string `mapstructure:"accessSecret" log:"secret"`
 }
 
 type Client struct {
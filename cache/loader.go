@@ -0,0 +1,310 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/chain-products-org/goal/redisx"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// notFoundValue is cached for keys whose loader returned gorm.ErrRecordNotFound, it is not valid
+// JSON so it never collides with a value.
+const notFoundValue = "\x00nf"
+
+type options struct {
+	prefix      string
+	ttl         time.Duration
+	notFoundTTL time.Duration
+	jitter      float64
+	localSize   int
+	localTTL    time.Duration
+}
+
+// Option configures a Loader.
+type Option func(o *options)
+
+// WithPrefix sets the prefix of the redis keys, e.g. the app name.
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithTTL sets the time to live of cached values, 10 minutes by default.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithNotFoundTTL sets the time to live of not found results, 1 minute by default, 0 disables
+// negative caching.
+func WithNotFoundTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.notFoundTTL = ttl
+	}
+}
+
+// WithJitter sets the max fraction of the TTL randomly added to it, 0.1 by default, so that keys
+// cached at the same time do not expire at the same time.
+func WithJitter(jitter float64) Option {
+	return func(o *options) {
+		o.jitter = jitter
+	}
+}
+
+// WithLocal enables the in-process L1 tier, which holds at most size values for at most ttl.
+// It is invalidated across replicas through redis pub/sub, but may still serve stale values for a
+// short time after an invalidation, so keep ttl short.
+func WithLocal(size int, ttl time.Duration) Option {
+	return func(o *options) {
+		o.localSize = size
+		o.localTTL = ttl
+	}
+}
+
+type entry[V any] struct {
+	val      V
+	notFound bool
+}
+
+// Loader is a read-through cache of values of type V by keys of type K. Values are read from
+// redis, and on a miss loaded by the load func, e.g. a gorm query, and written back. Concurrent
+// misses of the same key in a process are loaded once.
+//
+// If the load func returns gorm.ErrRecordNotFound, it is cached as well, and Get returns
+// gorm.ErrRecordNotFound until it expires or is invalidated.
+//
+// Redis errors do not fail Get, the value is loaded from the source instead.
+//
+//	users := cache.NewLoader(rc, "user", func(ctx context.Context, id uint64) (*User, error) {
+//		return repo.Get(ctx, id)
+//	}, cache.WithTTL(time.Hour))
+//	u, err := users.Get(ctx, 1)
+type Loader[K comparable, V any] struct {
+	rc    redisx.Client
+	name  string
+	load  func(ctx context.Context, key K) (V, error)
+	opts  options
+	tags  func(key K, v V) []string
+	sf    group[entry[V]]
+	local *local[V]
+	sub   *redis.PubSub
+}
+
+// NewLoader returns a Loader, name is the namespace of its keys. If the local tier is enabled,
+// Close must be called to stop listening for invalidations.
+func NewLoader[K comparable, V any](rc redisx.Client, name string, load func(ctx context.Context, key K) (V, error), opts ...Option) *Loader[K, V] {
+	o := options{ttl: 10 * time.Minute, notFoundTTL: time.Minute, jitter: 0.1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	l := &Loader[K, V]{rc: rc, name: name, load: load, opts: o}
+	if o.localSize > 0 && o.localTTL > 0 {
+		l.local = newLocal[V](o.localSize, o.localTTL)
+		l.sub = rc.Subscribe(context.Background(), l.channel())
+		go l.listen(l.sub.Channel())
+	}
+	return l
+}
+
+// TagBy sets the func returning the tags of a value, the value is invalidated by InvalidateTag
+// of any of them.
+func (l *Loader[K, V]) TagBy(tags func(key K, v V) []string) *Loader[K, V] {
+	l.tags = tags
+	return l
+}
+
+// Get returns the value of key, from the cache if present, otherwise from the load func.
+func (l *Loader[K, V]) Get(ctx context.Context, key K) (V, error) {
+	k := l.key(key)
+	if l.local != nil {
+		if e, ok := l.local.get(k); ok {
+			return l.result(entry[V]{val: e.val, notFound: e.notFound})
+		}
+	}
+	e, err := l.sf.do(k, func() (entry[V], error) {
+		if e, ok := l.read(ctx, k); ok {
+			return e, nil
+		}
+		v, err := l.load(ctx, key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if l.opts.notFoundTTL > 0 {
+				ttl := l.jittered(l.opts.notFoundTTL)
+				_ = l.rc.Set(ctx, k, notFoundValue, ttl).Err()
+				if l.local != nil {
+					l.local.set(k, v, true, ttl)
+				}
+			}
+			return entry[V]{val: v, notFound: true}, nil
+		}
+		if err != nil {
+			return entry[V]{val: v}, err
+		}
+		_ = l.write(ctx, key, v)
+		return entry[V]{val: v}, nil
+	})
+	if err != nil {
+		return e.val, err
+	}
+	return l.result(e)
+}
+
+// Set writes the value of key to the cache, e.g. after it is updated in the source.
+func (l *Loader[K, V]) Set(ctx context.Context, key K, v V) error {
+	if err := l.write(ctx, key, v); err != nil {
+		return err
+	}
+	return l.publish(ctx, l.key(key))
+}
+
+// Invalidate removes keys from the cache, of all replicas.
+func (l *Loader[K, V]) Invalidate(ctx context.Context, keys ...K) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ks := make([]string, len(keys))
+	for i, key := range keys {
+		ks[i] = l.key(key)
+	}
+	return l.del(ctx, ks...)
+}
+
+// InvalidateTag removes the values tagged with any of tags from the cache, of all replicas.
+func (l *Loader[K, V]) InvalidateTag(ctx context.Context, tags ...string) error {
+	var ks []string
+	for _, tag := range tags {
+		members, err := l.rc.SMembers(ctx, l.tagKey(tag)).Result()
+		if err != nil {
+			return err
+		}
+		ks = append(ks, members...)
+		ks = append(ks, l.tagKey(tag))
+	}
+	if len(ks) == 0 {
+		return nil
+	}
+	return l.del(ctx, ks...)
+}
+
+// Close stops listening for invalidations of the local tier.
+func (l *Loader[K, V]) Close() error {
+	if l.sub != nil {
+		return l.sub.Close()
+	}
+	return nil
+}
+
+func (l *Loader[K, V]) result(e entry[V]) (V, error) {
+	if e.notFound {
+		return e.val, gorm.ErrRecordNotFound
+	}
+	return e.val, nil
+}
+
+// read reads key from redis, a missing key, or a redis or decoding error, is a miss.
+func (l *Loader[K, V]) read(ctx context.Context, k string) (entry[V], bool) {
+	var e entry[V]
+	s, err := l.rc.Get(ctx, k).Result()
+	if err != nil {
+		return e, false
+	}
+	ttl := l.opts.ttl
+	if s == notFoundValue {
+		e.notFound = true
+		ttl = l.opts.notFoundTTL
+	} else if err := json.Unmarshal([]byte(s), &e.val); err != nil {
+		return e, false
+	}
+	if l.local != nil {
+		l.local.set(k, e.val, e.notFound, ttl)
+	}
+	return e, true
+}
+
+func (l *Loader[K, V]) write(ctx context.Context, key K, v V) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("cache: encode %s: %w", l.key(key), err)
+	}
+	k := l.key(key)
+	ttl := l.jittered(l.opts.ttl)
+	var tags []string
+	if l.tags != nil {
+		tags = l.tags(key, v)
+	}
+	_, err = l.rc.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, k, bs, ttl)
+		for _, tag := range tags {
+			p.SAdd(ctx, l.tagKey(tag), k)
+			// the tag lives as long as the longest value tagged with it
+			p.Expire(ctx, l.tagKey(tag), l.maxTTL())
+		}
+		return nil
+	})
+	if l.local != nil {
+		l.local.set(k, v, false, ttl)
+	}
+	return err
+}
+
+func (l *Loader[K, V]) del(ctx context.Context, ks ...string) error {
+	if l.local != nil {
+		l.local.del(ks...)
+	}
+	// one DEL per key, the keys may hash to different slots of a cluster
+	_, err := l.rc.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, k := range ks {
+			p.Del(ctx, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return l.publish(ctx, ks...)
+}
+
+// publish notifies the replicas to remove ks from their local tier.
+func (l *Loader[K, V]) publish(ctx context.Context, ks ...string) error {
+	if l.local == nil {
+		return nil
+	}
+	return l.rc.Publish(ctx, l.channel(), strings.Join(ks, "\n")).Err()
+}
+
+func (l *Loader[K, V]) listen(ch <-chan *redis.Message) {
+	for msg := range ch {
+		l.local.del(strings.Split(msg.Payload, "\n")...)
+	}
+}
+
+func (l *Loader[K, V]) jittered(ttl time.Duration) time.Duration {
+	if l.opts.jitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(int64(float64(ttl)*l.opts.jitter)+1))
+}
+
+func (l *Loader[K, V]) maxTTL() time.Duration {
+	return l.opts.ttl + time.Duration(float64(l.opts.ttl)*l.opts.jitter)
+}
+
+func (l *Loader[K, V]) key(key K) string {
+	return fmt.Sprintf("%s%s:%v", l.opts.prefix, l.name, key)
+}
+
+func (l *Loader[K, V]) tagKey(tag string) string {
+	return l.opts.prefix + l.name + ":tag:" + tag
+}
+
+func (l *Loader[K, V]) channel() string {
+	return l.opts.prefix + l.name + ":invalidate"
+}
@@ -0,0 +1,123 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/cache"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type User struct {
+	ID   uint64
+	Name string
+	Org  string
+}
+
+func TestLoader_Get(t *testing.T) {
+	rc := testx.NewMiniRedis()
+	ctx := context.Background()
+	var loads int32
+	users := cache.NewLoader(rc, "user", func(ctx context.Context, id uint64) (*User, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(20 * time.Millisecond)
+		if id == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return &User{ID: id, Name: fmt.Sprint("user", id)}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := users.Get(ctx, 1)
+			assert.Nil(t, err)
+			assert.Equal(t, "user1", u.Name)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads), "concurrent misses must be loaded once")
+
+	u, err := users.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "user1", u.Name)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	ttl := rc.TTL(ctx, "user:1").Val()
+	assert.True(t, ttl > 10*time.Minute-time.Second && ttl <= 11*time.Minute, ttl)
+
+	for i := 0; i < 2; i++ {
+		_, err = users.Get(ctx, 0)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads), "not found must be cached")
+
+	assert.Nil(t, users.Invalidate(ctx, 1))
+	_, err = users.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&loads))
+}
+
+func TestLoader_InvalidateTag(t *testing.T) {
+	// tagged keys hash to different slots
+	rc := testx.NewRedisCluster()
+	ctx := context.Background()
+	var loads int32
+	users := cache.NewLoader(rc, "user", func(ctx context.Context, id uint64) (User, error) {
+		atomic.AddInt32(&loads, 1)
+		return User{ID: id, Org: fmt.Sprint("org", id%2)}, nil
+	}, cache.WithPrefix("app:")).TagBy(func(id uint64, u User) []string {
+		return []string{u.Org}
+	})
+
+	for _, id := range []uint64{1, 2, 3} {
+		_, err := users.Get(ctx, id)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int64(2), rc.SCard(ctx, "app:user:tag:org1").Val())
+
+	assert.Nil(t, users.InvalidateTag(ctx, "org1"))
+	assert.Equal(t, int64(0), rc.Exists(ctx, "app:user:1", "app:user:3", "app:user:tag:org1").Val())
+	assert.Equal(t, int64(1), rc.Exists(ctx, "app:user:2").Val())
+
+	for _, id := range []uint64{1, 2, 3} {
+		_, _ = users.Get(ctx, id)
+	}
+	assert.Equal(t, int32(5), atomic.LoadInt32(&loads))
+}
+
+func TestLoader_Local(t *testing.T) {
+	rc := testx.NewMiniRedis()
+	ctx := context.Background()
+	var name atomic.Value
+	name.Store("tom")
+	load := func(ctx context.Context, id uint64) (User, error) {
+		return User{ID: id, Name: name.Load().(string)}, nil
+	}
+	a := cache.NewLoader(rc, "user", load, cache.WithLocal(100, time.Minute))
+	b := cache.NewLoader(rc, "user", load, cache.WithLocal(100, time.Minute))
+	defer a.Close()
+	defer b.Close()
+
+	u, _ := a.Get(ctx, 1)
+	assert.Equal(t, "tom", u.Name)
+	// served by the local tier even if redis is flushed
+	rc.FlushAll(ctx)
+	u, _ = a.Get(ctx, 1)
+	assert.Equal(t, "tom", u.Name)
+
+	name.Store("jerry")
+	assert.Eventually(t, func() bool {
+		// invalidated by the other replica through pub/sub
+		_ = b.Invalidate(ctx, 1)
+		u, _ := a.Get(ctx, 1)
+		return u.Name == "jerry"
+	}, time.Second, 20*time.Millisecond)
+}
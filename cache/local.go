@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type localEntry[V any] struct {
+	val      V
+	notFound bool
+	expireAt time.Time
+}
+
+// local is the in-process L1 tier, a size bounded map with expiry.
+type local[V any] struct {
+	mu   sync.Mutex
+	size int
+	ttl  time.Duration
+	m    map[string]localEntry[V]
+}
+
+func newLocal[V any](size int, ttl time.Duration) *local[V] {
+	return &local[V]{size: size, ttl: ttl, m: make(map[string]localEntry[V], size)}
+}
+
+func (c *local[V]) get(key string) (localEntry[V], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.m[key]
+	if !ok {
+		return e, false
+	}
+	if time.Now().After(e.expireAt) {
+		delete(c.m, key)
+		return e, false
+	}
+	return e, true
+}
+
+func (c *local[V]) set(key string, val V, notFound bool, ttl time.Duration) {
+	if ttl > c.ttl {
+		ttl = c.ttl
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.m[key]; !ok && len(c.m) >= c.size {
+		c.evict()
+	}
+	c.m[key] = localEntry[V]{val: val, notFound: notFound, expireAt: time.Now().Add(ttl)}
+}
+
+// evict removes the expired entries, or an arbitrary one if none is expired.
+func (c *local[V]) evict() {
+	now := time.Now()
+	for k, e := range c.m {
+		if now.After(e.expireAt) {
+			delete(c.m, k)
+		}
+	}
+	if len(c.m) < c.size {
+		return
+	}
+	for k := range c.m {
+		delete(c.m, k)
+		return
+	}
+}
+
+func (c *local[V]) del(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range keys {
+		delete(c.m, k)
+	}
+}
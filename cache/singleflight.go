@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// call is an in-flight or completed load of a key.
+type call[V any] struct {
+	wg  sync.WaitGroup
+	val V
+	err error
+}
+
+// group deduplicates concurrent loads of the same key, only the first caller runs fn and the
+// others wait for, and share, its result.
+type group[V any] struct {
+	mu sync.Mutex
+	m  map[string]*call[V]
+}
+
+func (g *group[V]) do(key string, fn func() (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call[V])
+	}
+	if c, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := new(call[V])
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				c.err = fmt.Errorf("cache: load %s panicked: %v", key, p)
+			}
+		}()
+		c.val, c.err = fn()
+	}()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+	return c.val, c.err
+}
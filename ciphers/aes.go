@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"github.com/chain-products-org/goal/errorx"
+	"io"
 )
 
 // AES 加密算法
@@ -23,6 +25,10 @@ const (
 	ECB AesMode = iota
 	// CBC 加密模式：https://en.wikipedia.org/wiki/Block_cipher_mode_of_operation#CBC
 	CBC
+	// GCM 认证加密模式：https://en.wikipedia.org/wiki/Galois/Counter_Mode
+	// iv 为 12 字节的 nonce，同一个 key 不能重复使用 nonce；iv 为 nil 时随机生成 nonce 并拼接在密文之前，
+	// 解密时 iv 为 nil 则从密文头部读取 nonce
+	GCM
 )
 
 func (a *aeser) Encrypt(rawBytes []byte, key []byte, mode AesMode, iv []byte) ([]byte, error) {
@@ -31,6 +37,8 @@ func (a *aeser) Encrypt(rawBytes []byte, key []byte, mode AesMode, iv []byte) ([
 		return aesEncryptECB(rawBytes, key)
 	case CBC:
 		return aesEncryptCBC(rawBytes, key, iv)
+	case GCM:
+		return aesEncryptGCM(rawBytes, key, iv)
 	default:
 		return nil, errorx.New("unsupported encrypt mode: %v", mode)
 	}
@@ -42,6 +50,8 @@ func (a *aeser) Decrypt(cipherBytes []byte, key []byte, mode AesMode, iv []byte)
 		return aesDecryptECB(cipherBytes, key)
 	case CBC:
 		return aesDecryptCBC(cipherBytes, key, iv)
+	case GCM:
+		return aesDecryptGCM(cipherBytes, key, iv)
 	default:
 		return nil, errorx.New("unsupported decrypt mode: %v", mode)
 	}
@@ -139,3 +149,47 @@ func aesDecryptCBC(cipherBytes []byte, key []byte, iv []byte) ([]byte, error) {
 	rawBytes = pkcs7UnPadding(rawBytes)
 	return rawBytes, nil
 }
+
+func aesEncryptGCM(rawBytes []byte, key []byte, nonce []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if nonce != nil {
+		if len(nonce) != gcm.NonceSize() {
+			return nil, errorx.New("nonce length must be %d", gcm.NonceSize())
+		}
+		return gcm.Seal(nil, nonce, rawBytes, nil), nil
+	}
+	// 随机 nonce 拼接在密文之前
+	dst := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(rawBytes)+gcm.Overhead())
+	if _, err = io.ReadFull(rand.Reader, dst); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(dst, dst, rawBytes, nil), nil
+}
+
+func aesDecryptGCM(cipherBytes []byte, key []byte, nonce []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if nonce == nil {
+		if len(cipherBytes) < gcm.NonceSize() {
+			return nil, errorx.New("illegal ciphertext length")
+		}
+		nonce, cipherBytes = cipherBytes[:gcm.NonceSize()], cipherBytes[gcm.NonceSize():]
+	} else if len(nonce) != gcm.NonceSize() {
+		return nil, errorx.New("nonce length must be %d", gcm.NonceSize())
+	}
+	// 密文或 key 不正确时认证失败
+	return gcm.Open(nil, nonce, cipherBytes, nil)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/chain-products-org/goal/ciphers"
 	"github.com/chain-products-org/goal/random"
+	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
@@ -34,3 +35,28 @@ func TestAES(t *testing.T) {
 	s, _ = ciphers.AES.Decrypt(s, key, ciphers.CBC, iv)
 	fmt.Println(string(s))
 }
+
+func TestAESGCM(t *testing.T) {
+	// 随机 nonce 拼接在密文之前，每次密文不同
+	s1, err := ciphers.AES.Encrypt([]byte(plainText), key, ciphers.GCM, nil)
+	assert.NoError(t, err)
+	s2, _ := ciphers.AES.Encrypt([]byte(plainText), key, ciphers.GCM, nil)
+	assert.NotEqual(t, s1, s2)
+	s, err := ciphers.AES.Decrypt(s1, key, ciphers.GCM, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, plainText, string(s))
+
+	// 固定 nonce
+	nonce := key[:12]
+	s, _ = ciphers.AES.Encrypt([]byte(plainText), key, ciphers.GCM, nonce)
+	s, err = ciphers.AES.Decrypt(s, key, ciphers.GCM, nonce)
+	assert.NoError(t, err)
+	assert.Equal(t, plainText, string(s))
+
+	// 篡改的密文认证失败
+	s1[len(s1)-1] ^= 1
+	_, err = ciphers.AES.Decrypt(s1, key, ciphers.GCM, nil)
+	assert.Error(t, err)
+	_, err = ciphers.AES.Encrypt([]byte(plainText), key, ciphers.GCM, key)
+	assert.Error(t, err)
+}
@@ -0,0 +1,380 @@
+// Package config loads the settings of the modules, like logx.Zap and mailx.Conf, into typed
+// structs. The values are merged in the order of precedence:
+//
+//  1. the default tags of the fields, like `default:"info"`
+//  2. a YAML, JSON or TOML file, whose strings may refer to env like ${SMTP_PASSWORD}
+//  3. env like APP_ZAP_LEVEL, named by a prefix and the keys of the fields, or by an env tag
+//  4. the flags set on the command line, like -zap.level=debug
+//
+// The keys of the fields are their mapstructure, yaml or json tags, or else their names, matched
+// case-insensitively. The config is validated by the validate tags, and by the Validate or
+// CheckValid methods of the structs, and reloaded on the changes of the file by Watch.
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Option configures a Loader.
+type Option func(o *options)
+
+type options struct {
+	file      string
+	envPrefix string
+	flags     *flag.FlagSet
+	lookupEnv func(key string) (string, bool)
+	onError   func(err error)
+	debounce  time.Duration
+}
+
+// WithFile loads the file, the format is of the extension: .yaml, .yml, .json or .toml.
+func WithFile(path string) Option {
+	return func(o *options) {
+		o.file = path
+	}
+}
+
+// WithEnvPrefix overrides the fields by env named by prefix and their keys, e.g. APP_ZAP_LEVEL
+// for the level of the zap field with the prefix APP, or by the env tag of a field.
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithFlags defines a string flag of every field on fs, named by the keys joined by dots like
+// zap.level, which overrides the field when set. fs is parsed by the caller before Load.
+func WithFlags(fs *flag.FlagSet) Option {
+	return func(o *options) {
+		o.flags = fs
+	}
+}
+
+// WithLookupEnv looks up env by lookup, os.LookupEnv by default.
+func WithLookupEnv(lookup func(key string) (string, bool)) Option {
+	return func(o *options) {
+		o.lookupEnv = lookup
+	}
+}
+
+// WithOnError reports the errors of reloading by Watch, the config is kept on errors.
+func WithOnError(onError func(err error)) Option {
+	return func(o *options) {
+		o.onError = onError
+	}
+}
+
+// Loader loads a config of type T, which is a struct.
+type Loader[T any] struct {
+	o      options
+	leaves []leaf
+	flags  map[string]*string // by the flag names
+
+	current atomic.Pointer[T]
+	mu      sync.Mutex
+	subs    map[int]func(c *T)
+	nextSub int
+}
+
+// New returns a Loader of T, the flags of WithFlags are defined here.
+func New[T any](opts ...Option) *Loader[T] {
+	l := &Loader[T]{
+		o:    options{lookupEnv: os.LookupEnv, debounce: 100 * time.Millisecond},
+		subs: map[int]func(c *T){},
+	}
+	for _, opt := range opts {
+		opt(&l.o)
+	}
+	l.leaves = leaves(reflect.TypeOf((*T)(nil)).Elem(), nil)
+	if l.o.flags != nil {
+		l.flags = map[string]*string{}
+		for _, lf := range l.leaves {
+			name := strings.Join(lf.path, ".")
+			usage := lf.field.Tag.Get("usage")
+			if def := lf.field.Tag.Get("default"); def != "" {
+				usage = strings.TrimSpace(usage + " (default " + def + ")")
+			}
+			l.flags[name] = l.o.flags.String(name, "", usage)
+		}
+	}
+	return l
+}
+
+// Load loads the config by the options of l.
+func Load[T any](opts ...Option) (*T, error) {
+	return New[T](opts...).Load()
+}
+
+// Load loads and validates the config, which is kept as the current config.
+func (l *Loader[T]) Load() (*T, error) {
+	c, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	l.current.Store(c)
+	return c, nil
+}
+
+// Get returns the current config, nil before Load.
+func (l *Loader[T]) Get() *T {
+	return l.current.Load()
+}
+
+func (l *Loader[T]) load() (*T, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	merged := map[string]any{}
+
+	defaults := map[string]any{}
+	for _, lf := range l.leaves {
+		if def, ok := lf.field.Tag.Lookup("default"); ok {
+			set(defaults, lf.path, def)
+		}
+	}
+	merge(merged, defaults)
+
+	if l.o.file != "" {
+		file, err := l.readFile()
+		if err != nil {
+			return nil, err
+		}
+		canonical(file, t)
+		merge(merged, file)
+	}
+
+	env := map[string]any{}
+	for _, lf := range l.leaves {
+		name := lf.field.Tag.Get("env")
+		if name == "" && l.o.envPrefix != "" {
+			name = envName(l.o.envPrefix, lf.path)
+		}
+		if name == "" {
+			continue
+		}
+		if value, ok := l.o.lookupEnv(name); ok {
+			set(env, lf.path, value)
+		}
+	}
+	merge(merged, env)
+
+	if l.o.flags != nil {
+		flags := map[string]any{}
+		l.o.flags.Visit(func(f *flag.Flag) {
+			if value, ok := l.flags[f.Name]; ok {
+				set(flags, strings.Split(f.Name, "."), *value)
+			}
+		})
+		merge(merged, flags)
+	}
+
+	c := new(T)
+	if err := decode(reflect.ValueOf(c).Elem(), merged, ""); err != nil {
+		return nil, err
+	}
+	if err := validate(reflect.ValueOf(c)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (l *Loader[T]) readFile() (map[string]any, error) {
+	bs, err := os.ReadFile(l.o.file)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	switch ext := strings.ToLower(filepath.Ext(l.o.file)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(bs, &data)
+	case ".json":
+		err = json.Unmarshal(bs, &data)
+	case ".toml":
+		err = toml.Unmarshal(bs, &data)
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", l.o.file, err)
+	}
+	if data == nil {
+		data = map[string]any{}
+	}
+	if err = l.interpolate(data); err != nil {
+		return nil, fmt.Errorf("interpolate %s: %w", l.o.file, err)
+	}
+	return normalize(data).(map[string]any), nil
+}
+
+// envRefPattern matches ${NAME} and ${NAME:-default}.
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolate replaces the env references in the strings of v, an unset env without a default
+// is an error, so that a missing secret fails the load.
+func (l *Loader[T]) interpolate(v any) error {
+	var missing []string
+	var walk func(v any) any
+	walk = func(v any) any {
+		switch x := v.(type) {
+		case string:
+			return envRefPattern.ReplaceAllStringFunc(x, func(ref string) string {
+				m := envRefPattern.FindStringSubmatch(ref)
+				if value, ok := l.o.lookupEnv(m[1]); ok {
+					return value
+				}
+				if strings.Contains(ref, ":-") {
+					return m[2]
+				}
+				missing = append(missing, m[1])
+				return ref
+			})
+		case map[string]any:
+			for k, e := range x {
+				x[k] = walk(e)
+			}
+		case map[any]any:
+			for k, e := range x {
+				x[k] = walk(e)
+			}
+		case []any:
+			for i, e := range x {
+				x[i] = walk(e)
+			}
+		}
+		return v
+	}
+	walk(v)
+	if len(missing) > 0 {
+		return fmt.Errorf("env %s is not set", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Subscribe calls fn with the config reloaded by Watch, until cancel is called.
+func (l *Loader[T]) Subscribe(fn func(c *T)) (cancel func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := l.nextSub
+	l.nextSub++
+	l.subs[id] = fn
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.subs, id)
+	}
+}
+
+// OnChange calls fn with the section of the config selected by section, when the section of a
+// reloaded config differs from the previous one, until cancel is called.
+//
+//	config.OnChange(loader, func(c *AppConf) logx.Zap { return c.Zap }, func(z logx.Zap) {
+//		_ = logger.Levels().Apply(&z)
+//	})
+func OnChange[T, S any](l *Loader[T], section func(c *T) S, fn func(s S)) (cancel func()) {
+	var (
+		mu   sync.Mutex
+		prev S
+		ok   bool
+	)
+	if c := l.Get(); c != nil {
+		prev, ok = section(c), true
+	}
+	return l.Subscribe(func(c *T) {
+		s := section(c)
+		mu.Lock()
+		changed := !ok || !reflect.DeepEqual(prev, s)
+		prev, ok = s, true
+		mu.Unlock()
+		if changed {
+			fn(s)
+		}
+	})
+}
+
+// Watch reloads the config on the changes of the file until ctx is done, and notifies the
+// subscribers of the reloaded config. A failed reload is reported by WithOnError, and the
+// current config is kept.
+func (l *Loader[T]) Watch(ctx context.Context) error {
+	if l.o.file == "" {
+		return fmt.Errorf("no config file to watch")
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// watch the directory, as editors replace the file, and k8s config maps swap the ..data
+	// symlink the file links to, which changes the target of the file without its own events
+	if err = w.Add(filepath.Dir(l.o.file)); err != nil {
+		_ = w.Close()
+		return err
+	}
+	name := filepath.Clean(l.o.file)
+	target, _ := filepath.EvalSymlinks(name)
+	go func() {
+		defer w.Close()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				changed := filepath.Clean(ev.Name) == name && ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0
+				if t, err := filepath.EvalSymlinks(name); err == nil && t != target {
+					target, changed = t, true
+				}
+				if changed {
+					debounce = time.After(l.o.debounce)
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				l.reportError(err)
+			case <-debounce:
+				debounce = nil
+				l.reload()
+			}
+		}
+	}()
+	return nil
+}
+
+func (l *Loader[T]) reload() {
+	c, err := l.load()
+	if err != nil {
+		l.reportError(err)
+		return
+	}
+	l.current.Store(c)
+	l.mu.Lock()
+	subs := make([]func(c *T), 0, len(l.subs))
+	for _, fn := range l.subs {
+		subs = append(subs, fn)
+	}
+	l.mu.Unlock()
+	for _, fn := range subs {
+		fn(c)
+	}
+}
+
+func (l *Loader[T]) reportError(err error) {
+	if l.o.onError != nil {
+		l.o.onError(err)
+	}
+}
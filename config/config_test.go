@@ -0,0 +1,212 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/config"
+	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/logx"
+	"github.com/chain-products-org/goal/mailx"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+type appConf struct {
+	Name    string        `mapstructure:"name" yaml:"name" validate:"required"`
+	Port    int           `mapstructure:"port" yaml:"port" default:"8080" validate:"min=1,max=65535"`
+	Mode    string        `mapstructure:"mode" yaml:"mode" default:"release" validate:"oneof=debug|release"`
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout" default:"5s"`
+	Tags    []string      `mapstructure:"tags" yaml:"tags"`
+	Zap     logx.Zap      `mapstructure:"zap" yaml:"zap"`
+	Mail    mailx.Server  `mapstructure:"mail" yaml:"mail"`
+	APIKey  string        `mapstructure:"apiKey" yaml:"apiKey" env:"TEST_API_KEY"`
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func env(vars map[string]string) config.Option {
+	return config.WithLookupEnv(func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	})
+}
+
+const appYAML = `
+name: shop
+Port: 9000
+tags: [a, b]
+zap:
+  level: info
+  encode-level: cap
+  modules:
+    db: debug
+mail:
+  host: smtp.mail.com
+  port: 465
+  from: noreply@mail.com
+  userName: noreply
+  password: ${SMTP_PASSWORD}
+`
+
+func TestLoad(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := config.New[appConf](
+		config.WithFile(writeFile(t, "app.yaml", appYAML)),
+		config.WithEnvPrefix("APP"),
+		config.WithFlags(fs),
+		env(map[string]string{
+			"SMTP_PASSWORD":        "secret",
+			"APP_ZAP_LEVEL":        "warn",
+			"APP_MAIL_USER_NAME":   "admin",
+			"APP_ZAP_MAX_AGE":      "7",
+			"APP_ZAP_SHOW_LINE":    "true",
+			"APP_TAGS":             "x, y",
+			"TEST_API_KEY":         "key",
+			"APP_ZAP_ENCODE_LEVEL": "lower",
+		}),
+	)
+	assert.NoError(t, fs.Parse([]string{"-zap.encode-level=capColor", "-timeout=1m"}))
+	c, err := loader.Load()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "shop", c.Name)
+	assert.Equal(t, 9000, c.Port)
+	assert.Equal(t, "release", c.Mode)
+	assert.Equal(t, time.Minute, c.Timeout)
+	assert.Equal(t, []string{"x", "y"}, c.Tags)
+	assert.Equal(t, "warn", c.Zap.Level)
+	assert.Equal(t, logx.ZapEncodeLevelCapColor, c.Zap.EncodeLevel)
+	assert.Equal(t, 7, c.Zap.MaxAge)
+	assert.True(t, c.Zap.ShowLine)
+	assert.Equal(t, map[string]string{"db": "debug"}, c.Zap.Modules)
+	assert.Equal(t, mailx.Server{Host: "smtp.mail.com", Port: 465, From: "noreply@mail.com", UserName: "admin", Password: "secret"}, c.Mail)
+	assert.Equal(t, "key", c.APIKey)
+	assert.Same(t, c, loader.Get())
+}
+
+func TestLoad_Formats(t *testing.T) {
+	files := map[string]string{
+		"app.json": `{"name": "shop", "port": 81, "mail": {"host": "h", "port": 25, "from": "u@h.com", "userName": "u", "password": "p"}}`,
+		"app.toml": "name = \"shop\"\nport = 81\n[mail]\nhost = \"h\"\nport = 25\nfrom = \"u@h.com\"\nuserName = \"u\"\npassword = \"p\"\n",
+		"app.yml":  "name: shop\nport: 81\nmail: {host: h, port: 25, from: u@h.com, userName: u, password: p}\n",
+	}
+	for name, content := range files {
+		c, err := config.Load[appConf](config.WithFile(writeFile(t, name, content)))
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, 81, c.Port, name)
+			assert.Equal(t, mailx.Server{Host: "h", Port: 25, From: "u@h.com", UserName: "u", Password: "p"}, c.Mail, name)
+		}
+	}
+	_, err := config.Load[appConf](config.WithFile(writeFile(t, "app.ini", "")))
+	assert.ErrorContains(t, err, "unsupported config format")
+}
+
+func TestLoad_Errors(t *testing.T) {
+	_, err := config.Load[appConf](config.WithFile(writeFile(t, "app.yaml", appYAML)), env(nil))
+	assert.ErrorContains(t, err, "env SMTP_PASSWORD is not set")
+
+	_, err = config.Load[appConf](config.WithFile(writeFile(t, "app.yaml", "port: http")))
+	assert.ErrorContains(t, err, "port: ")
+
+	// the unused mail is not checked
+	_, err = config.Load[appConf](env(map[string]string{"APP_PORT": "0", "APP_MODE": "test", "APP_NAME": "x"}),
+		config.WithEnvPrefix("APP"))
+	assert.EqualError(t, err, `mode: "test" is not one of the options debug|release`)
+
+	_, err = config.Load[appConf](env(map[string]string{"APP_PORT": "70000", "APP_MAIL_HOST": "h"}),
+		config.WithEnvPrefix("APP"))
+	var multi *errorx.MultiError
+	if assert.True(t, errors.As(err, &multi)) {
+		assert.Equal(t, []errorx.FieldError{
+			{Field: "name", Message: "is required"},
+			{Field: "port", Message: "is greater than 65535"},
+			{Field: "mail", Message: "bad parameters"},
+		}, multi.FieldErrors())
+		assert.True(t, errors.Is(err, config.RequiredError))
+	}
+}
+
+func TestLoader_Watch(t *testing.T) {
+	path := writeFile(t, "app.yaml", "name: shop\nzap: {level: info}\n")
+	var reloadErr atomic.Value
+	loader := config.New[appConf](config.WithFile(path), config.WithOnError(func(err error) { reloadErr.Store(err) }))
+	_, err := loader.Load()
+	assert.NoError(t, err)
+
+	levels := make(chan string, 4)
+	var reloads atomic.Int32
+	loader.Subscribe(func(c *appConf) { reloads.Add(1) })
+	cancel := config.OnChange(loader, func(c *appConf) logx.Zap { return c.Zap }, func(z logx.Zap) { levels <- z.Level })
+	defer cancel()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	assert.NoError(t, loader.Watch(ctx))
+
+	// the zap section is unchanged
+	assert.NoError(t, os.WriteFile(path, []byte("name: mall\nzap: {level: info}\n"), 0o644))
+	assert.Eventually(t, func() bool { return loader.Get().Name == "mall" }, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, os.WriteFile(path, []byte("name: mall\nzap: {level: error}\n"), 0o644))
+	select {
+	case level := <-levels:
+		assert.Equal(t, "error", level)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload")
+	}
+	assert.Len(t, levels, 0)
+	assert.GreaterOrEqual(t, reloads.Load(), int32(2))
+
+	// an invalid config is reported, and the config is kept
+	assert.NoError(t, os.WriteFile(path, []byte("zap: {level: debug}\n"), 0o644))
+	assert.Eventually(t, func() bool { return reloadErr.Load() != nil }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "error", loader.Get().Zap.Level)
+}
+
+func TestLoader_WatchConfigMap(t *testing.T) {
+	// the layout of a k8s config map: app.yaml -> ..data/app.yaml, ..data -> ..v1
+	dir := t.TempDir()
+	version := func(v, content string) {
+		assert.NoError(t, os.Mkdir(filepath.Join(dir, v), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, v, "app.yaml"), []byte(content), 0o644))
+		assert.NoError(t, os.Symlink(v, filepath.Join(dir, "..data_tmp")))
+		assert.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	}
+	version("..v1", "name: shop\n")
+	path := filepath.Join(dir, "app.yaml")
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "app.yaml"), path))
+
+	loader := config.New[appConf](config.WithFile(path))
+	_, err := loader.Load()
+	assert.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	assert.NoError(t, loader.Watch(ctx))
+
+	version("..v2", "name: mall\n")
+	assert.Eventually(t, func() bool { return loader.Get().Name == "mall" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestModuleYAMLKeys(t *testing.T) {
+	// the keys of yaml.v3 are kept for the configs decoded without the loader
+	var server mailx.Server
+	assert.NoError(t, yaml.Unmarshal([]byte("host: h\nusername: u\npassword: p\n"), &server))
+	assert.Equal(t, mailx.Server{Host: "h", UserName: "u", Password: "p"}, server)
+
+	c, err := config.Load[appConf](config.WithFile(writeFile(t, "app.yaml",
+		"name: shop\nmail: {host: h, port: 25, from: u@h.com, username: u, password: p}\n")))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "u", c.Mail.UserName)
+	}
+}
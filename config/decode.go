@@ -0,0 +1,402 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// fieldKey returns the key of a struct field, by its mapstructure, yaml or json tag, or else its
+// name, and whether it is squashed into its parent, as an untagged embedded struct.
+func fieldKey(f reflect.StructField) (key string, squash bool) {
+	for _, tag := range []string{"mapstructure", "yaml", "json"} {
+		name, opts, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			return "", false
+		}
+		if strings.Contains(","+opts+",", ",squash,") || strings.Contains(","+opts+",", ",inline,") {
+			return "", true
+		}
+		if name != "" {
+			return name, false
+		}
+	}
+	if f.Anonymous && indirect(f.Type).Kind() == reflect.Struct {
+		return "", true
+	}
+	return f.Name, false
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isLeaf reports whether values of t are set as a whole, by a string of env or flags.
+func isLeaf(t reflect.Type) bool {
+	t = indirect(t)
+	if t == timeType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	return t.Kind() != reflect.Struct
+}
+
+// leaf is a field of a config set by a string.
+type leaf struct {
+	path  []string // the keys from the root
+	field reflect.StructField
+}
+
+// leaves returns the leaf fields of struct t.
+func leaves(t reflect.Type, prefix []string) []leaf {
+	var ls []leaf
+	t = indirect(t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, squash := fieldKey(f)
+		if squash {
+			ls = append(ls, leaves(f.Type, prefix)...)
+			continue
+		}
+		if key == "" {
+			continue
+		}
+		path := append(append([]string(nil), prefix...), key)
+		if isLeaf(f.Type) {
+			ls = append(ls, leaf{path: path, field: f})
+		} else {
+			ls = append(ls, leaves(f.Type, path)...)
+		}
+	}
+	return ls
+}
+
+// envName returns the env name of path, like PREFIX_SERVER_USER_NAME for server.userName.
+func envName(prefix string, path []string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for _, key := range path {
+		if b.Len() > 0 {
+			b.WriteByte('_')
+		}
+		rs := []rune(key)
+		for i, r := range rs {
+			switch {
+			case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(rs[i-1]) || unicode.IsDigit(rs[i-1])):
+				b.WriteByte('_')
+				b.WriteRune(r)
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				b.WriteRune(unicode.ToUpper(r))
+			default:
+				b.WriteByte('_')
+			}
+		}
+	}
+	return b.String()
+}
+
+// set sets the value of path in m, creating the maps on the path.
+func set(m map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+// merge merges src into dst, the maps are merged recursively, and the other values of src
+// replace those of dst.
+func merge(dst, src map[string]any) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]any); ok {
+			if dm, ok := dst[k].(map[string]any); ok {
+				merge(dm, sm)
+				continue
+			}
+			cp := map[string]any{}
+			merge(cp, sm)
+			v = cp
+		}
+		dst[k] = v
+	}
+}
+
+// canonical renames the keys of m matching the fields of struct t case-insensitively to their
+// keys, so that the layers are merged by the same keys.
+func canonical(m map[string]any, t reflect.Type) {
+	t = indirect(t)
+	if t.Kind() != reflect.Struct || isLeaf(t) {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, squash := fieldKey(f)
+		if squash {
+			canonical(m, f.Type)
+			continue
+		}
+		if key == "" {
+			continue
+		}
+		for k, v := range m {
+			if k != key && strings.EqualFold(k, key) {
+				if _, ok := m[key]; !ok {
+					m[key] = v
+				}
+				delete(m, k)
+			}
+		}
+		if sub, ok := m[key].(map[string]any); ok {
+			canonical(sub, f.Type)
+		}
+	}
+}
+
+// normalize converts the maps of the formats to map[string]any.
+func normalize(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, e := range x {
+			x[k] = normalize(e)
+		}
+		return x
+	case map[any]any:
+		m := make(map[string]any, len(x))
+		for k, e := range x {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case []any:
+		for i, e := range x {
+			x[i] = normalize(e)
+		}
+		return x
+	default:
+		return v
+	}
+}
+
+// decode decodes data into v, converting the strings of env and flags to the kinds of v.
+func decode(v reflect.Value, data any, path string) error {
+	if data == nil {
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decode(v.Elem(), data, path)
+	}
+	fail := func(err error) error {
+		if err == nil {
+			err = fmt.Errorf("can not decode %T into %s", data, v.Type())
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if s, ok := data.(string); ok && v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return fail(v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)))
+	}
+	switch {
+	case v.Type() == durationType:
+		switch x := data.(type) {
+		case string:
+			d, err := time.ParseDuration(x)
+			if err != nil {
+				return fail(err)
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+	case v.Type() == timeType:
+		switch x := data.(type) {
+		case time.Time:
+			v.Set(reflect.ValueOf(x))
+			return nil
+		case string:
+			t, err := time.Parse(time.RFC3339, x)
+			if err != nil {
+				return fail(err)
+			}
+			v.Set(reflect.ValueOf(t))
+			return nil
+		}
+		return fail(nil)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m, ok := data.(map[string]any)
+		if !ok {
+			return fail(nil)
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			key, squash := fieldKey(f)
+			if squash {
+				if err := decode(v.Field(i), m, path); err != nil {
+					return err
+				}
+				continue
+			}
+			if key == "" {
+				continue
+			}
+			if value, ok := m[key]; ok {
+				if err := decode(v.Field(i), value, join(path, key)); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Map:
+		m, ok := data.(map[string]any)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return fail(nil)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(m)))
+		}
+		for k, e := range m {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decode(elem, e, join(path, k)); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), elem)
+		}
+	case reflect.Slice:
+		var items []any
+		switch x := data.(type) {
+		case []any:
+			items = x
+		case string:
+			// a comma separated list of env or flags
+			for _, s := range strings.Split(x, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					items = append(items, s)
+				}
+			}
+		default:
+			return fail(nil)
+		}
+		s := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decode(s.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.String:
+		switch x := data.(type) {
+		case string:
+			v.SetString(x)
+		case bool, int, int64, uint64, float64:
+			v.SetString(fmt.Sprint(x))
+		default:
+			return fail(nil)
+		}
+	case reflect.Bool:
+		switch x := data.(type) {
+		case bool:
+			v.SetBool(x)
+		case string:
+			b, err := strconv.ParseBool(x)
+			if err != nil {
+				return fail(err)
+			}
+			v.SetBool(b)
+		default:
+			return fail(nil)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt(data)
+		if err != nil {
+			return fail(err)
+		}
+		if v.OverflowInt(n) {
+			return fail(fmt.Errorf("%d overflows %s", n, v.Type()))
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toInt(data)
+		if err != nil {
+			return fail(err)
+		}
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			return fail(fmt.Errorf("%d overflows %s", n, v.Type()))
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		switch x := data.(type) {
+		case float64:
+			v.SetFloat(x)
+		case int, int64, uint64:
+			n, _ := toInt(x)
+			v.SetFloat(float64(n))
+		case string:
+			f, err := strconv.ParseFloat(x, 64)
+			if err != nil {
+				return fail(err)
+			}
+			v.SetFloat(f)
+		default:
+			return fail(nil)
+		}
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(data))
+	default:
+		return fail(nil)
+	}
+	return nil
+}
+
+func toInt(data any) (int64, error) {
+	switch x := data.(type) {
+	case int:
+		return int64(x), nil
+	case int64:
+		return x, nil
+	case uint64:
+		return int64(x), nil
+	case float64:
+		if x != float64(int64(x)) {
+			return 0, fmt.Errorf("%v is not an integer", x)
+		}
+		return int64(x), nil
+	case string:
+		return strconv.ParseInt(x, 0, 64)
+	default:
+		return 0, fmt.Errorf("can not convert %T to an integer", data)
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/chain-products-org/goal/errorx"
+)
+
+var (
+	RequiredError = errors.New("is required")
+	OneOfError    = errors.New("is not one of the options")
+)
+
+// Validator is implemented by the configs validating themselves, the Validate or CheckValid
+// method of a nested config is called if the config is not zero.
+type Validator interface {
+	Validate() error
+}
+
+// checker is the CheckValid method of the settings of the modules, e.g. mailx.Server.
+type checker interface {
+	CheckValid() error
+}
+
+// validate validates v by the validate tags of its fields, like `validate:"required,min=1"`, and
+// by its Validate or CheckValid methods. The returned error is an errorx.MultiError of the
+// errors labeled by path.
+func validate(v reflect.Value) error {
+	h := errorx.NewAccumulator()
+	validateValue(h, v, "", true)
+	return h.Err()
+}
+
+func validateValue(h *errorx.ErrorHandler, v reflect.Value, path string, root bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || v.Type() == timeType {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, squash := fieldKey(f)
+		fieldPath := path
+		if !squash {
+			if key == "" {
+				continue
+			}
+			fieldPath = join(path, key)
+		}
+		fv := v.Field(i)
+		if tag := f.Tag.Get("validate"); tag != "" {
+			h.DoLabeled(fieldPath, func() error { return checkRules(fv, tag) })
+		}
+		if !isLeaf(f.Type) {
+			validateValue(h, fv, fieldPath, false)
+		}
+	}
+	// the nested configs of the unused modules are left zero
+	if !root && v.IsZero() {
+		return
+	}
+	label := path
+	if label == "" {
+		label = t.Name()
+	}
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	switch c := v.Interface().(type) {
+	case Validator:
+		h.DoLabeled(label, c.Validate)
+	case checker:
+		h.DoLabeled(label, c.CheckValid)
+	}
+}
+
+// checkRules checks v by the comma separated rules: required, min=n, max=n, and oneof=a|b, the
+// bounds are of the value of numbers, and of the length of strings, slices and maps. A zero value
+// is only checked by required.
+func checkRules(v reflect.Value, rules string) error {
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name != "required" && v.IsZero() {
+			continue
+		}
+		switch name {
+		case "required":
+			if v.IsZero() {
+				return RequiredError
+			}
+		case "min", "max":
+			bound, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return fmt.Errorf("bad rule %s: %w", rule, err)
+			}
+			n, ok := measure(v)
+			if !ok {
+				return fmt.Errorf("rule %s does not apply to %s", rule, v.Type())
+			}
+			if name == "min" && n < bound {
+				return fmt.Errorf("is less than %s", arg)
+			}
+			if name == "max" && n > bound {
+				return fmt.Errorf("is greater than %s", arg)
+			}
+		case "oneof":
+			s := fmt.Sprint(v.Interface())
+			ok := false
+			for _, option := range strings.Split(arg, "|") {
+				ok = ok || s == option
+			}
+			if !ok {
+				return fmt.Errorf("%q %w %s", s, OneOfError, arg)
+			}
+		case "":
+		default:
+			return fmt.Errorf("unknown rule %s", rule)
+		}
+	}
+	return nil
+}
+
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String, reflect.Slice, reflect.Map:
+		return float64(v.Len()), true
+	default:
+		return 0, false
+	}
+}
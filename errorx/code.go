@@ -0,0 +1,117 @@
+package errorx
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Def is the definition of the errors of a business code, with the HTTP status and the message
+// safe to show to users. It is an error itself, errors.Is reports whether an error is of its code.
+//
+//	var UserNotFound = errorx.Register("user.not_found", http.StatusNotFound, "user not found")
+//
+//	return UserNotFound.New().With("id", id)
+//	errors.Is(err, UserNotFound) // true
+type Def struct {
+	code    string
+	status  int
+	message string
+}
+
+var registry = struct {
+	sync.RWMutex
+	defs map[string]*Def
+}{defs: map[string]*Def{}}
+
+// Register registers the definition of code, it panics if code is registered, as the codes are
+// registered by package variables.
+func Register(code string, status int, message string) *Def {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.defs[code]; ok {
+		panic(fmt.Sprintf("error code %s is registered", code))
+	}
+	d := &Def{code: code, status: status, message: message}
+	registry.defs[code] = d
+	return d
+}
+
+// Lookup returns the definition of code.
+func Lookup(code string) (*Def, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	d, ok := registry.defs[code]
+	return d, ok
+}
+
+// Defs returns the registered definitions, sorted by code, e.g. to document the codes of an API.
+func Defs() []*Def {
+	registry.RLock()
+	defs := make([]*Def, 0, len(registry.defs))
+	for _, d := range registry.defs {
+		defs = append(defs, d)
+	}
+	registry.RUnlock()
+	sort.Slice(defs, func(i, j int) bool { return defs[i].code < defs[j].code })
+	return defs
+}
+
+func (d *Def) Code() string {
+	return d.code
+}
+
+// Status returns the HTTP status, 500 if not set.
+func (d *Def) Status() int {
+	if d.status == 0 {
+		return http.StatusInternalServerError
+	}
+	return d.status
+}
+
+func (d *Def) Message() string {
+	return d.message
+}
+
+func (d *Def) Error() string {
+	return d.message
+}
+
+// New returns an Error of d, with the stack of its caller.
+func (d *Def) New() *Error {
+	return d.newError(1)
+}
+
+// Errorf returns an Error of d with the internal message, which is logged but not shown to users.
+func (d *Def) Errorf(format string, args ...any) *Error {
+	e := d.newError(1)
+	e.internal = fmt.Sprintf(format, args...)
+	return e
+}
+
+// Wrap returns an Error of d caused by err, nil if err is nil. Use New().WithCause to add details.
+func (d *Def) Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	e := d.newError(1)
+	e.cause = err
+	return e
+}
+
+// MarshalLogObject logs d as an Error of it.
+func (d *Def) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return d.error().MarshalLogObject(enc)
+}
+
+// error returns an Error of d without the stack, for d returned as an error.
+func (d *Def) error() *Error {
+	return &Error{code: d.code, status: d.Status(), message: d.message}
+}
+
+func (d *Def) newError(skip int) *Error {
+	return &Error{code: d.code, status: d.Status(), message: d.message, stack: callers(skip + 1)}
+}
@@ -0,0 +1,225 @@
+package errorx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Error is an error of a business code and an HTTP status, with a message safe to show to users,
+// an internal message and a cause which are only logged, details, and the stack where it is
+// created. It is created by a registered Def, or by NewError.
+//
+// Error supports errors.Is by code and errors.As through its cause, it marshals to JSON as the
+// code, message and details for users, and it is logged by zap as an object, or by %+v with the
+// internal message, details and stack.
+type Error struct {
+	code     string
+	status   int
+	message  string
+	internal string
+	cause    error
+	details  map[string]any
+	stack    stack
+}
+
+// NewError returns an Error of an unregistered code, with the stack of its caller.
+func NewError(status int, code, message string) *Error {
+	return &Error{code: code, status: status, message: message, stack: callers(1)}
+}
+
+// With adds a detail of key and value to e, and returns e.
+func (e *Error) With(key string, value any) *Error {
+	if e.details == nil {
+		e.details = map[string]any{}
+	}
+	e.details[key] = value
+	return e
+}
+
+// WithInternal sets the internal message of e, and returns e.
+func (e *Error) WithInternal(format string, args ...any) *Error {
+	e.internal = fmt.Sprintf(format, args...)
+	return e
+}
+
+// WithCause sets the cause of e, and returns e.
+func (e *Error) WithCause(err error) *Error {
+	e.cause = err
+	return e
+}
+
+func (e *Error) Code() string {
+	return e.code
+}
+
+// Status returns the HTTP status, 500 if not set.
+func (e *Error) Status() int {
+	if e.status == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.status
+}
+
+// Message returns the message safe to show to users.
+func (e *Error) Message() string {
+	return e.message
+}
+
+// Internal returns the internal message.
+func (e *Error) Internal() string {
+	return e.internal
+}
+
+func (e *Error) Details() map[string]any {
+	return e.details
+}
+
+// Stack returns the stack where e is created, formatted like debug.Stack.
+func (e *Error) Stack() string {
+	return e.stack.String()
+}
+
+// Error returns the code, the internal message, or else the message, and the cause.
+func (e *Error) Error() string {
+	s := e.code + ": "
+	if e.internal != "" {
+		s += e.internal
+	} else {
+		s += e.message
+	}
+	if e.cause != nil {
+		s += ": " + e.cause.Error()
+	}
+	return s
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether target is the Def, or an Error, of the code of e.
+func (e *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case *Def:
+		return t.code == e.code
+	case *Error:
+		return t.code == e.code
+	}
+	return false
+}
+
+// Format formats e by Error for %s and %v, and adds the details, the stack, and the verbose cause
+// for %+v.
+func (e *Error) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = io.WriteString(s, e.Error())
+			for _, k := range e.detailKeys() {
+				_, _ = fmt.Fprintf(s, "\n%s: %v", k, e.details[k])
+			}
+			if e.cause != nil {
+				_, _ = fmt.Fprintf(s, "\ncause: %+v", e.cause)
+			}
+			if len(e.stack) > 0 {
+				_, _ = io.WriteString(s, "\n"+e.Stack())
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, e.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+func (e *Error) detailKeys() []string {
+	keys := make([]string, 0, len(e.details))
+	for k := range e.details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// errorJSON is the JSON of an Error for users.
+type errorJSON struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// MarshalJSON marshals the code, message and details of e, the internal message, cause and stack
+// are not marshalled to not leak them to users.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(errorJSON{Code: e.code, Message: e.message, Details: e.details})
+}
+
+// UnmarshalJSON unmarshals the JSON of MarshalJSON, the status is of the registered code.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var j errorJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*e = Error{code: j.Code, message: j.Message, details: j.Details}
+	if d, ok := Lookup(j.Code); ok {
+		e.status = d.Status()
+	}
+	return nil
+}
+
+// MarshalLogObject logs e with all its fields, as a zap object.
+func (e *Error) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("code", e.code)
+	enc.AddInt("status", e.Status())
+	enc.AddString("message", e.message)
+	if e.internal != "" {
+		enc.AddString("internal", e.internal)
+	}
+	if e.cause != nil {
+		enc.AddString("cause", e.cause.Error())
+	}
+	if len(e.details) > 0 {
+		if err := enc.AddReflected("details", e.details); err != nil {
+			return err
+		}
+	}
+	if len(e.stack) > 0 {
+		enc.AddString("stack", e.Stack())
+	}
+	return nil
+}
+
+// AsError returns the first Error in the tree of err, or else an Error of the first Def in it, as
+// the Defs are returned as errors themselves.
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	var d *Def
+	if errors.As(err, &d) {
+		return d.error(), true
+	}
+	return nil, false
+}
+
+// StatusOf returns the HTTP status of err, of the first Error or PreferredError in its tree, or
+// else 500.
+func StatusOf(err error) int {
+	if e, ok := AsError(err); ok {
+		return e.Status()
+	}
+	var perr *PreferredError
+	if errors.As(err, &perr) && perr.Code() != 0 {
+		return perr.Code()
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,119 @@
+package errorx_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/logx"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var (
+	userNotFound = errorx.Register("test.user_not_found", http.StatusNotFound, "user not found")
+	quotaFull    = errorx.Register("test.quota_full", http.StatusTooManyRequests, "quota is full")
+)
+
+func TestError(t *testing.T) {
+	err := userNotFound.Errorf("no user %d in db", 7).With("id", 7)
+	assert.Equal(t, "test.user_not_found", err.Code())
+	assert.Equal(t, http.StatusNotFound, err.Status())
+	assert.Equal(t, "user not found", err.Message())
+	assert.Equal(t, "test.user_not_found: no user 7 in db", err.Error())
+	assert.Equal(t, map[string]any{"id": 7}, err.Details())
+	assert.True(t, strings.HasPrefix(err.Stack(), "github.com/chain-products-org/goal/errorx_test.TestError\n"), err.Stack())
+
+	verbose := fmt.Sprintf("%+v", err)
+	assert.Contains(t, verbose, "test.user_not_found: no user 7 in db\nid: 7\n")
+	assert.Contains(t, verbose, "coded_error_test.go")
+	assert.Equal(t, err.Error(), fmt.Sprintf("%v", err))
+	assert.Equal(t, `"`+err.Error()+`"`, fmt.Sprintf("%q", err))
+
+	assert.Equal(t, http.StatusInternalServerError, errorx.NewError(0, "x", "x").Status())
+}
+
+func TestError_Is(t *testing.T) {
+	err := fmt.Errorf("load profile: %w", userNotFound.Wrap(fs.ErrNotExist))
+	assert.True(t, errors.Is(err, userNotFound))
+	assert.True(t, errors.Is(err, userNotFound.New()))
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	assert.False(t, errors.Is(err, quotaFull))
+	assert.Nil(t, userNotFound.Wrap(nil))
+
+	joined := errors.Join(errors.New("plain"), quotaFull.New())
+	assert.True(t, errors.Is(joined, quotaFull))
+	e, ok := errorx.AsError(joined)
+	if assert.True(t, ok) {
+		assert.Equal(t, "test.quota_full", e.Code())
+	}
+	assert.Equal(t, http.StatusTooManyRequests, errorx.StatusOf(joined))
+	assert.Equal(t, http.StatusForbidden, errorx.StatusOf(fmt.Errorf("wrap: %w", errorx.InvalidOperation)))
+	assert.Equal(t, http.StatusInternalServerError, errorx.StatusOf(errors.New("plain")))
+
+	// a Def is an error itself
+	e, ok = errorx.AsError(fmt.Errorf("wrap: %w", userNotFound))
+	if assert.True(t, ok) {
+		assert.Equal(t, "test.user_not_found", e.Code())
+		assert.Empty(t, e.Stack())
+	}
+	assert.Equal(t, http.StatusNotFound, errorx.StatusOf(userNotFound))
+}
+
+func TestError_JSON(t *testing.T) {
+	err := userNotFound.Errorf("internal").WithCause(errors.New("sql: no rows")).With("id", "u1")
+	bs, jerr := json.Marshal(err)
+	assert.NoError(t, jerr)
+	assert.JSONEq(t, `{"code":"test.user_not_found","message":"user not found","details":{"id":"u1"}}`, string(bs))
+
+	var decoded errorx.Error
+	assert.NoError(t, json.Unmarshal(bs, &decoded))
+	assert.Equal(t, http.StatusNotFound, decoded.Status())
+	assert.True(t, errors.Is(&decoded, userNotFound))
+}
+
+func TestError_Log(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	err := quotaFull.New().WithInternal("user %s", "u1").WithCause(errors.New("redis: nil")).With("limit", 10)
+	zap.New(core).Sugar().Errorw("request failed", "error", err)
+
+	fields := logs.All()[0].ContextMap()["error"].(map[string]any)
+	assert.Equal(t, "test.quota_full", fields["code"])
+	assert.EqualValues(t, http.StatusTooManyRequests, fields["status"])
+	assert.Equal(t, "user u1", fields["internal"])
+	assert.Equal(t, "redis: nil", fields["cause"])
+	assert.Equal(t, map[string]any{"limit": 10}, fields["details"])
+	assert.Contains(t, fields["stack"], "coded_error_test.go")
+
+	// wrapped, or a Def returned as is
+	zap.New(core).Error("request failed", logx.Err(fmt.Errorf("pay: %w", err)), logx.NamedErr("def", userNotFound))
+	fields = logs.All()[1].ContextMap()["error"].(map[string]any)
+	assert.Equal(t, "pay: "+err.Error(), fields["error"])
+	assert.Equal(t, "test.quota_full", fields["code"])
+	fields = logs.All()[1].ContextMap()["def"].(map[string]any)
+	assert.Equal(t, "test.user_not_found", fields["code"])
+	assert.EqualValues(t, http.StatusNotFound, fields["status"])
+
+	zap.New(core).Error("request failed", logx.Err(errors.New("plain")), logx.Err(nil))
+	assert.Equal(t, map[string]any{"error": "plain"}, logs.All()[2].ContextMap())
+}
+
+func TestRegister(t *testing.T) {
+	d, ok := errorx.Lookup("test.quota_full")
+	assert.True(t, ok)
+	assert.Same(t, quotaFull, d)
+	assert.Panics(t, func() { errorx.Register("test.quota_full", http.StatusOK, "again") })
+
+	var codes []string
+	for _, d := range errorx.Defs() {
+		codes = append(codes, d.Code())
+	}
+	assert.Equal(t, []string{"test.quota_full", "test.user_not_found"}, codes)
+}
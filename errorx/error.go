@@ -85,7 +85,7 @@ func Prefer426(format string, args ...any) error {
 
 // Prefer429 create a PreferredError with http.StatusTooManyRequests
 func Prefer429(format string, args ...any) error {
-	return &PreferredError{code: http.StatusForbidden, error: fmt.Errorf(format, args...)}
+	return &PreferredError{code: http.StatusTooManyRequests, error: fmt.Errorf(format, args...)}
 }
 
 // Prefer500 create a PreferredError with http.StatusInternalServerError
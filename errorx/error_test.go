@@ -1,8 +1,10 @@
 package errorx
 
 import (
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"net/http"
 	"testing"
 )
 
@@ -33,6 +35,19 @@ func TestIsPreferred(t *testing.T) {
 	assert.True(t, b)
 }
 
+func TestPreferCodes(t *testing.T) {
+	for code, err := range map[int]error{
+		http.StatusBadRequest:      Prefer400("bad"),
+		http.StatusForbidden:       Prefer403("forbidden"),
+		http.StatusTooManyRequests: Prefer429("too many requests"),
+	} {
+		var pe *PreferredError
+		if assert.True(t, errors.As(err, &pe)) {
+			assert.Equal(t, code, pe.Code(), err.Error())
+		}
+	}
+}
+
 func ExampleIsPreferred() {
 	err := fmt.Errorf("demo error")
 	b := IsPreferred(err)
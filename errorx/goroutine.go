@@ -0,0 +1,200 @@
+package errorx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/chain-products-org/goal/logx"
+)
+
+// PanicError is a recovered panic, with the stack where it panicked.
+type PanicError struct {
+	Value any
+	stack stack
+}
+
+func newPanicError(p any) *PanicError {
+	s := callers(1)
+	// start at the panicking function, after the deferred recover and runtime.gopanic
+	for i, pc := range s {
+		if fn := runtime.FuncForPC(pc - 1); fn != nil && fn.Name() == "runtime.gopanic" {
+			s = s[i+1:]
+			break
+		}
+	}
+	return &PanicError{Value: p, stack: s}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Stack returns the stack where e panicked, formatted like debug.Stack.
+func (e *PanicError) Stack() string {
+	return e.stack.String()
+}
+
+// Format formats e by Error, and adds the stack for %+v.
+func (e *PanicError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = io.WriteString(s, e.Error()+"\n"+e.Stack())
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, e.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// PanicHook is called with the panics recovered by Go and Group, e.g. to report them to an alerting
+// service.
+type PanicHook func(ctx context.Context, err *PanicError)
+
+var panicHook atomic.Pointer[PanicHook]
+
+// SetPanicHook sets the hook of the recovered panics, nil to remove it.
+func SetPanicHook(hook PanicHook) {
+	if hook == nil {
+		panicHook.Store(nil)
+		return
+	}
+	panicHook.Store(&hook)
+}
+
+// call calls fn, and returns a panic of fn as a PanicError, which is logged by the logger of ctx,
+// or by logger, and reported to the panic hook.
+func call(ctx context.Context, logger *logx.Logger, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			perr := newPanicError(p)
+			if logger == nil {
+				logger = logx.Default
+			}
+			logx.FromContextOr(ctx, logger).Errorf("%+v", perr)
+			if hook := panicHook.Load(); hook != nil {
+				(*hook)(ctx, perr)
+			}
+			err = perr
+		}
+	}()
+	return fn(ctx)
+}
+
+// Go runs fn in a goroutine, a panic of fn is recovered as a PanicError, logged by the logger of
+// ctx, or by logger, and reported to the panic hook. The returned channel receives the error of fn,
+// and is closed when fn returns.
+func Go(ctx context.Context, logger *logx.Logger, fn func(ctx context.Context) error) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+		done <- call(ctx, logger, fn)
+	}()
+	return done
+}
+
+// GroupOption configures a Group.
+type GroupOption func(g *Group)
+
+// WithLimit limits the running functions of a group to n, Group.Go blocks beyond it.
+func WithLimit(n int) GroupOption {
+	return func(g *Group) {
+		if n > 0 {
+			g.sem = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithFailFast cancels the context of a group on the first error, the functions of Group.Go
+// afterwards are not run.
+func WithFailFast() GroupOption {
+	return func(g *Group) {
+		g.failFast = true
+	}
+}
+
+// Group runs functions in goroutines like Go, and collects all their errors.
+type Group struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	logger   *logx.Logger
+	sem      chan struct{}
+	failFast bool
+
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewGroup returns a Group, and its context which is canceled by Wait, or on the first error
+// WithFailFast.
+func NewGroup(ctx context.Context, logger *logx.Logger, opts ...GroupOption) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Group{ctx: ctx, cancel: cancel, logger: logger}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g, ctx
+}
+
+// Go runs fn with the context of g in a goroutine, it blocks while the limit of g is reached.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	if g.sem != nil {
+		if g.failFast {
+			select {
+			case g.sem <- struct{}{}:
+			case <-g.ctx.Done():
+				return
+			}
+		} else {
+			g.sem <- struct{}{}
+		}
+	}
+	if g.failFast && g.ctx.Err() != nil {
+		g.release()
+		return
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.release()
+		if err := call(g.ctx, g.logger, fn); err != nil {
+			g.mu.Lock()
+			g.errs = append(g.errs, err)
+			g.mu.Unlock()
+			if g.failFast {
+				g.cancel()
+			}
+		}
+	}()
+}
+
+func (g *Group) release() {
+	if g.sem != nil {
+		<-g.sem
+	}
+}
+
+// Wait waits for the functions of g, cancels its context, and returns their errors joined by
+// errors.Join, in the order they are returned.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return errors.Join(g.errs...)
+}
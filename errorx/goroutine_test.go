@@ -0,0 +1,97 @@
+package errorx_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/stretchr/testify/assert"
+)
+
+func panicky() error {
+	panic(io.ErrUnexpectedEOF)
+}
+
+func TestGo(t *testing.T) {
+	var hooked atomic.Pointer[errorx.PanicError]
+	errorx.SetPanicHook(func(ctx context.Context, err *errorx.PanicError) {
+		hooked.Store(err)
+	})
+	defer errorx.SetPanicHook(nil)
+
+	err := <-errorx.Go(context.Background(), testx.NewLog(), func(ctx context.Context) error {
+		return panicky()
+	})
+	var perr *errorx.PanicError
+	if assert.True(t, errors.As(err, &perr)) {
+		assert.Equal(t, "panic: unexpected EOF", perr.Error())
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+		assert.True(t, strings.HasPrefix(perr.Stack(), "github.com/chain-products-org/goal/errorx_test.panicky\n"), perr.Stack())
+		assert.Contains(t, fmt.Sprintf("%+v", perr), "goroutine_test.go")
+	}
+	assert.Same(t, perr, hooked.Load())
+
+	done := errorx.Go(context.Background(), nil, func(ctx context.Context) error { return io.EOF })
+	assert.Equal(t, io.EOF, <-done)
+	_, open := <-done
+	assert.False(t, open)
+}
+
+func TestGroup(t *testing.T) {
+	var running, peak atomic.Int32
+	g, _ := errorx.NewGroup(context.Background(), testx.NewLog(), errorx.WithLimit(2))
+	for i := 0; i < 6; i++ {
+		i := i
+		g.Go(func(ctx context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			switch i {
+			case 1:
+				return fmt.Errorf("task %d", i)
+			case 4:
+				panic("task 4")
+			}
+			return nil
+		})
+	}
+	err := g.Wait()
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+	assert.ErrorContains(t, err, "task 1")
+	assert.ErrorContains(t, err, "panic: task 4")
+	var perr *errorx.PanicError
+	assert.True(t, errors.As(err, &perr))
+}
+
+func TestGroup_FailFast(t *testing.T) {
+	g, ctx := errorx.NewGroup(context.Background(), testx.NewLog(), errorx.WithFailFast(), errorx.WithLimit(1))
+	var ran atomic.Int32
+	g.Go(func(ctx context.Context) error {
+		ran.Add(1)
+		return errors.New("first")
+	})
+	g.Go(func(ctx context.Context) error {
+		ran.Add(1)
+		<-ctx.Done()
+		return nil
+	})
+	assert.EqualError(t, g.Wait(), "first")
+	assert.Error(t, ctx.Err())
+	assert.Equal(t, int32(1), ran.Load())
+
+	// the functions run regardless of errors without fail fast
+	g, _ = errorx.NewGroup(context.Background(), testx.NewLog())
+	for i := 0; i < 3; i++ {
+		g.Go(func(ctx context.Context) error { return errors.New("again") })
+	}
+	assert.Equal(t, "again\nagain\nagain", g.Wait().Error())
+}
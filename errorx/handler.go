@@ -4,46 +4,80 @@ package errorx
 // 如果 ErrorHandler 持有非 nil 的 error，则其 Do 方法将不再处理给定的函数；反之，则会将 Do 方法给定的函数参数的返回 error 赋给
 // ErrorHandler 持有，以后多次调用 Do 方法则什么都不会做。最终可以通过 HasErr 方法来判断是否持有非 nil 的 error，或者通过 Done 方法来添加
 // 处理钩子。
+//
+// 累积模式（NewAccumulator）下，Do 方法总是执行给定的函数，并收集每个 error，Err 返回 *MultiError。
 type ErrorHandler struct {
-	err error
+	err        error
+	accumulate bool
+	errs       []error
 }
 
 func NewHandler() *ErrorHandler {
 	return &ErrorHandler{}
 }
 
-// Do 如果当前持有的 error 为 nil，则执行给定返回 error 的函数，否则什么也不做
+// NewAccumulator 创建累积模式的 ErrorHandler，用于校验配置、批量导入等需要收集全部 error 的场景
+func NewAccumulator() *ErrorHandler {
+	return &ErrorHandler{accumulate: true}
+}
+
+// Do 如果当前持有的 error 为 nil，则执行给定返回 error 的函数，否则什么也不做；累积模式下总是执行并收集 error
 func (h *ErrorHandler) Do(f func() error) *ErrorHandler {
+	if h.accumulate {
+		if err := f(); err != nil {
+			h.errs = append(h.errs, err)
+		}
+		return h
+	}
 	if h.err == nil {
 		h.err = f()
 	}
 	return h
 }
 
+// DoLabeled 同 Do，返回的 error 以 LabeledError 标注 label，如字段名或行号
+func (h *ErrorHandler) DoLabeled(label string, f func() error) *ErrorHandler {
+	return h.Do(func() error {
+		if err := f(); err != nil {
+			return &LabeledError{Label: label, Err: err}
+		}
+		return nil
+	})
+}
+
 // HasErr 如果持有非 nil error，则返回 true，否则返回 false
 func (h *ErrorHandler) HasErr() bool {
-	return h.err != nil
+	return h.Err() != nil
 }
 
-// Err 返回持有的 error
+// Err 返回持有的 error，累积模式下返回收集了全部 error 的 *MultiError，没有 error 则返回 nil
 func (h *ErrorHandler) Err() error {
+	if h.accumulate {
+		if len(h.errs) == 0 {
+			return nil
+		}
+		return &MultiError{errs: h.errs}
+	}
 	return h.err
 }
 
 // Done 方法表示处理完成的钩子函数，执行给定的函数，其参数为当前 handler 持有的 error
 func (h *ErrorHandler) Done(f func(err error)) {
-	f(h.err)
+	f(h.Err())
 }
 
-// IsPreferredErr 如果持有的 error 是首选的则返回 true，否则返回 false
+// IsPreferredErr 如果持有的 error 是首选的则返回 true，否则返回 false；累积模式下要求每个 error 都是首选的
 func (h *ErrorHandler) IsPreferredErr() bool {
+	if h.accumulate {
+		return len(h.errs) > 0 && (&MultiError{errs: h.errs}).IsPreferred()
+	}
 	return IsPreferred(h.err)
 }
 
 // PreferredOr 如果持有的 error 是首选的则返回，否则返回给定 error
 func (h *ErrorHandler) PreferredOr(err error) error {
 	if h.IsPreferredErr() {
-		return h.err
+		return h.Err()
 	}
 	return err
 }
@@ -1,9 +1,15 @@
 package errorx
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"net/http"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestDo(t *testing.T) {
@@ -79,3 +85,41 @@ func f2() (int, error) {
 	}
 	return n, nil
 }
+
+func TestAccumulator(t *testing.T) {
+	notFound := NewError(http.StatusNotFound, "test.not_found", "not found")
+	h := NewAccumulator()
+	var ran int
+	h.DoLabeled("name", func() error {
+		ran++
+		return Prefer400("is required")
+	}).Do(func() error {
+		ran++
+		return nil
+	}).DoLabeled("port", func() error {
+		ran++
+		return notFound.WithInternal("port %d", 0)
+	})
+	assert.Equal(t, 3, ran)
+	assert.True(t, h.HasErr())
+	assert.True(t, h.IsPreferredErr())
+	assert.Equal(t, "2 errors occurred:\n\t* name: is required\n\t* port: test.not_found: port 0", h.Err().Error())
+	assert.True(t, errors.Is(h.Err(), notFound))
+	var labeled *LabeledError
+	assert.True(t, errors.As(h.Err(), &labeled))
+	assert.Equal(t, "name", labeled.Label)
+
+	bs, err := json.Marshal(h.Err())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"message":"2 errors occurred","errors":[
+		{"field":"name","message":"is required"},
+		{"field":"port","code":"test.not_found","message":"not found"}]}`, string(bs))
+
+	h.Do(func() error { return errors.New("internal") })
+	assert.False(t, h.IsPreferredErr())
+	assert.Equal(t, io.EOF, h.PreferredOr(io.EOF))
+	assert.Len(t, h.Err().(*MultiError).Errors(), 3)
+
+	assert.Nil(t, NewAccumulator().Do(func() error { return nil }).Err())
+	assert.False(t, NewAccumulator().IsPreferredErr())
+}
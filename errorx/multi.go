@@ -0,0 +1,109 @@
+package errorx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LabeledError is an error labeled by what failed, e.g. the field of a config, or the row of an
+// import.
+type LabeledError struct {
+	Label string
+	Err   error
+}
+
+func (e *LabeledError) Error() string {
+	return e.Label + ": " + e.Err.Error()
+}
+
+func (e *LabeledError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is the errors collected by an accumulating ErrorHandler. It supports errors.Is and
+// errors.As like errors.Join, and marshals to JSON as field errors.
+type MultiError struct {
+	errs []error
+}
+
+// Errors returns the collected errors, labeled by LabeledError if they are labeled.
+func (m *MultiError) Errors() []error {
+	return m.errs
+}
+
+func (m *MultiError) Unwrap() []error {
+	return m.errs
+}
+
+// Error formats the errors as a list.
+func (m *MultiError) Error() string {
+	if len(m.errs) == 1 {
+		return m.errs[0].Error()
+	}
+	var b strings.Builder
+	b.WriteString(m.summary() + ":")
+	for _, err := range m.errs {
+		b.WriteString("\n\t* ")
+		b.WriteString(strings.ReplaceAll(err.Error(), "\n", "\n\t  "))
+	}
+	return b.String()
+}
+
+// IsPreferred reports whether every error is a PreferredError, or an Error, so that they can be
+// shown to users.
+func (m *MultiError) IsPreferred() bool {
+	if len(m.errs) == 0 {
+		return false
+	}
+	for _, err := range m.errs {
+		var perr *PreferredError
+		if _, ok := AsError(err); !ok && !errors.As(err, &perr) {
+			return false
+		}
+	}
+	return true
+}
+
+// FieldError is the JSON of an error of a MultiError.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// FieldErrors returns the errors as field errors, the field is the label, and the message is the
+// safe message of an Error, or else the message of the error without its label.
+func (m *MultiError) FieldErrors() []FieldError {
+	fields := make([]FieldError, len(m.errs))
+	for i, err := range m.errs {
+		var labeled *LabeledError
+		if errors.As(err, &labeled) {
+			fields[i].Field = labeled.Label
+			err = labeled.Err
+		}
+		if e, ok := AsError(err); ok {
+			fields[i].Code, fields[i].Message = e.Code(), e.Message()
+		} else {
+			fields[i].Message = err.Error()
+		}
+	}
+	return fields
+}
+
+// MarshalJSON marshals m like {"message":"2 errors occurred","errors":[{"field":"name",
+// "message":"is required"}]}.
+func (m *MultiError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Message string       `json:"message"`
+		Errors  []FieldError `json:"errors"`
+	}{m.summary(), m.FieldErrors()})
+}
+
+func (m *MultiError) summary() string {
+	if len(m.errs) == 1 {
+		return "1 error occurred"
+	}
+	return fmt.Sprintf("%d errors occurred", len(m.errs))
+}
@@ -20,13 +20,14 @@ func Recover(logger *logx.Logger, cleanups ...func()) {
 	}
 }
 
-// RecoverCtx is used with defer to do cleanup on panics.
+// RecoverCtx is used with defer to do cleanup on panics, the panic is logged by the logger of
+// ctx, or by logger with the ids of ctx.
 func RecoverCtx(ctx context.Context, logger *logx.Logger, cleanups ...func()) {
 	for _, cleanup := range cleanups {
 		cleanup()
 	}
 
 	if p := recover(); p != nil {
-		logger.Errorf("%+v\n%s", p, debug.Stack())
+		logx.FromContextOr(ctx, logger).Errorf("%+v\n%s", p, debug.Stack())
 	}
 }
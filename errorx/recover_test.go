@@ -3,7 +3,10 @@ package errorx_test
 import (
 	"context"
 	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/logx"
 	"github.com/chain-products-org/goal/testx"
+	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
 
@@ -37,3 +40,22 @@ func TestRescueCtx(t *testing.T) {
 	})
 	assert.Equal(t, int32(5), atomic.LoadInt32(&count))
 }
+
+func TestRescueCtx_ContextLogger(t *testing.T) {
+	dir := t.TempDir()
+	ctx := logx.IntoContext(context.Background(), logx.NewLog(&logx.Zap{Level: "info", Director: dir}))
+	ctx = logx.WithRequestID(ctx, "req-1")
+	func() {
+		defer errorx.RecoverCtx(ctx, testx.NewLog())
+		panic("hello")
+	}()
+	// the file of the day the logger wrote, as the test may cross midnight
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "error.log"))
+	if !assert.Len(t, files, 1) {
+		return
+	}
+	bs, err := os.ReadFile(files[0])
+	assert.Nil(t, err)
+	assert.Contains(t, string(bs), "hello")
+	assert.Contains(t, string(bs), `"requestId": "req-1"`)
+}
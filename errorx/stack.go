@@ -0,0 +1,36 @@
+package errorx
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const maxStackDepth = 32
+
+// stack is the program counters of a captured call stack.
+type stack []uintptr
+
+// callers captures the call stack, skipping the skip frames above its caller.
+func callers(skip int) stack {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+	return pcs[:n]
+}
+
+// String formats s like debug.Stack, a function and its file:line per frame.
+func (s stack) String() string {
+	if len(s) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	frames := runtime.CallersFrames(s)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
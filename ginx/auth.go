@@ -0,0 +1,56 @@
+package ginx
+
+import (
+	"strings"
+
+	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/jwtx"
+	"github.com/chain-products-org/goal/logx"
+	"github.com/gin-gonic/gin"
+)
+
+// ClaimsKey is the key of the verified *jwtx.Claims in gin.Context.
+const ClaimsKey = "jwtClaims"
+
+// JWTAuth returns a middleware that verifies the bearer token in the Authorization header with
+// the given service. The verified claims are stored in the context under ClaimsKey, otherwise
+// the request is aborted with errorx.NoAuth. The subject is logged as the user id by the context
+// logger, see logx.FromContext.
+func JWTAuth(s *jwtx.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token := bearerToken(ctx.GetHeader("Authorization"))
+		if token == "" {
+			Resp.PreferError(ctx, errorx.NoAuth)
+			ctx.Abort()
+			return
+		}
+		claims, err := s.Verify(ctx, token)
+		if err != nil {
+			_ = ctx.Error(err)
+			Resp.PreferError(ctx, errorx.NoAuth)
+			ctx.Abort()
+			return
+		}
+		ctx.Set(ClaimsKey, claims)
+		ctx.Request = ctx.Request.WithContext(logx.WithUserID(ctx.Request.Context(), claims.Subject))
+		ctx.Next()
+	}
+}
+
+// Claims returns the claims verified by JWTAuth.
+func Claims(ctx *gin.Context) (*jwtx.Claims, bool) {
+	v, ok := ctx.Get(ClaimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*jwtx.Claims)
+	return claims, ok
+}
+
+func bearerToken(header string) string {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
@@ -0,0 +1,49 @@
+package ginx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chain-products-org/goal/ginx"
+	"github.com/chain-products-org/goal/jwtx"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := jwtx.NewService(jwtx.NewKeyRing(jwtx.MustNewKey("k1", jwtx.HS256, []byte("secret")), 0))
+	r := gin.New()
+	r.GET("/me", ginx.JWTAuth(s), func(ctx *gin.Context) {
+		claims, ok := ginx.Claims(ctx)
+		assert.True(t, ok)
+		ginx.Resp.OkJson(ctx, claims.Subject)
+	})
+
+	token, err := s.Issue(jwtx.Claims{Subject: "1001"})
+	assert.Nil(t, err)
+
+	cases := []struct {
+		header string
+		code   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Basic xxx", http.StatusUnauthorized},
+		{"Bearer " + token + "x", http.StatusUnauthorized},
+		{"Bearer " + token, http.StatusOK},
+		{"bearer " + token, http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		r.ServeHTTP(w, req)
+		assert.Equal(t, c.code, w.Code, c.header)
+		if c.code == http.StatusOK {
+			assert.Equal(t, `"1001"`, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package ginx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/idempotency"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on the responses replayed by Idempotency.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// BodyTooLargeError is responded by Idempotency to the requests whose body is over the max size.
+var BodyTooLargeError = errorx.NewPreferredCodeErrf(http.StatusRequestEntityTooLarge, "request body is too large")
+
+// IdempotencyOption configures Idempotency.
+type IdempotencyOption func(o *idempotencyOptions)
+
+type idempotencyOptions struct {
+	maxBodySize int64
+}
+
+// WithMaxBodySize sets the max size of the bodies read to fingerprint the requests, 1 MiB by
+// default. The requests over it get 413.
+func WithMaxBodySize(n int64) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.maxBodySize = n
+	}
+}
+
+// Idempotency returns a middleware which executes the requests with the same Idempotency-Key
+// header once, and replays the response to the retries. Retries in flight get 409, and reusing
+// a key for a different method, path or body gets 422. Server errors are not stored, so that
+// they can be retried. The keys are scoped by the JWT subject if JWTAuth runs before.
+func Idempotency(store *idempotency.Store, opts ...IdempotencyOption) gin.HandlerFunc {
+	o := idempotencyOptions{maxBodySize: 1 << 20}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if claims, ok := Claims(ctx); ok {
+			key = claims.Subject + ":" + key
+		}
+		fp, err := fingerprint(ctx.Request, o.maxBodySize)
+		if err != nil {
+			if !errors.Is(err, BodyTooLargeError) {
+				err = errorx.BadReq
+			}
+			Resp.PreferError(ctx, err)
+			ctx.Abort()
+			return
+		}
+
+		claim, replay, err := store.Claim(ctx, key, fp)
+		if err != nil {
+			_ = ctx.Error(err)
+			if !errorx.IsPreferred(err) {
+				err = errorx.ServerBusy
+			}
+			Resp.PreferError(ctx, err)
+			ctx.Abort()
+			return
+		}
+		if replay != nil {
+			for k, vs := range replay.Header {
+				ctx.Writer.Header()[k] = vs
+			}
+			ctx.Header(IdempotentReplayedHeader, "true")
+			ctx.Status(replay.Status)
+			_, _ = ctx.Writer.Write(replay.Body)
+			ctx.Abort()
+			return
+		}
+
+		w := &captureWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = w
+		finished := false
+		defer func() {
+			if !finished { // panicked
+				_ = claim.Release(context.Background())
+			}
+		}()
+		ctx.Next()
+		finished = true
+
+		if w.Status() >= http.StatusInternalServerError {
+			err = claim.Release(context.Background())
+		} else {
+			err = claim.Complete(context.Background(), &idempotency.Response{
+				Status: w.Status(),
+				Header: w.Header().Clone(),
+				Body:   w.body.Bytes(),
+			})
+		}
+		if err != nil {
+			_ = ctx.Error(err)
+		}
+	}
+}
+
+// fingerprint hashes the method, path and body of the request, the body is restored. A body
+// over maxBodySize is BodyTooLargeError.
+func fingerprint(req *http.Request, maxBodySize int64) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	if req.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+		if err != nil {
+			return "", err
+		}
+		if int64(len(body)) > maxBodySize {
+			return "", BodyTooLargeError
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// captureWriter keeps a copy of the response body.
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
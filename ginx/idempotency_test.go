@@ -0,0 +1,84 @@
+package ginx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/ginx"
+	"github.com/chain-products-org/goal/idempotency"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var calls int32
+	block := make(chan struct{})
+	r := gin.New()
+	r.Use(ginx.Idempotency(idempotency.NewStore(testx.NewMiniRedis(), "idem:"), ginx.WithMaxBodySize(16)))
+	r.POST("/orders", func(ctx *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		ctx.Header("X-Order", "1")
+		ctx.JSON(http.StatusCreated, n)
+	})
+	r.POST("/slow", func(ctx *gin.Context) {
+		<-block
+		ginx.Resp.Ok(ctx)
+	})
+	r.POST("/fail", func(ctx *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		ginx.Resp.ServerErr(ctx)
+	})
+
+	do := func(path, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(ginx.IdempotencyKeyHeader, key)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/orders", "k1", "a")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "1", w.Body.String())
+
+	// replayed
+	w = do("/orders", "k1", "a")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "1", w.Body.String())
+	assert.Equal(t, "1", w.Header().Get("X-Order"))
+	assert.Equal(t, "true", w.Header().Get(ginx.IdempotentReplayedHeader))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// reused for another body
+	assert.Equal(t, http.StatusUnprocessableEntity, do("/orders", "k1", "b").Code)
+	// without the key
+	assert.Equal(t, "2", do("/orders", "", "a").Body.String())
+	// the body is too large to fingerprint
+	w = do("/orders", "k3", strings.Repeat("a", 17))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, http.StatusCreated, do("/orders", "k4", strings.Repeat("a", 16)).Code)
+
+	// server errors are retried
+	assert.Equal(t, http.StatusInternalServerError, do("/fail", "k2", "").Code)
+	assert.Equal(t, http.StatusInternalServerError, do("/fail", "k2", "").Code)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+
+	// in flight
+	done := make(chan int)
+	go func() {
+		done <- do("/slow", "k3", "").Code
+	}()
+	assert.Eventually(t, func() bool {
+		return do("/slow", "k3", "").Code == http.StatusConflict
+	}, time.Second, 10*time.Millisecond)
+	close(block)
+	assert.Equal(t, http.StatusOK, <-done)
+}
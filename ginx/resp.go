@@ -1,6 +1,7 @@
 package ginx
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -77,8 +78,15 @@ func (r *resp) NoAuthI18n(ctx *gin.Context, key string, vs ...any) {
 	ctx.String(http.StatusUnauthorized, i18n.MustGetMessage(key), vs...)
 }
 
+// PreferError responds an errorx.MultiError by 400 and its field errors, an errorx.Error in err
+// by its status and JSON, a PreferredError by its code and message, or else err by 400.
 func (r *resp) PreferError(ctx *gin.Context, err error) {
-	if errorx.IsPreferred(err) {
+	var multi *errorx.MultiError
+	if errors.As(err, &multi) {
+		ctx.JSON(http.StatusBadRequest, multi)
+	} else if e, ok := errorx.AsError(err); ok {
+		ctx.JSON(e.Status(), e)
+	} else if errorx.IsPreferred(err) {
 		perr := err.(*errorx.PreferredError)
 		ctx.String(perr.Code(), perr.Error())
 	} else {
@@ -0,0 +1,50 @@
+package ginx_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/ginx"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var orderLocked = errorx.Register("ginx_test.order_locked", http.StatusConflict, "order is locked")
+
+func TestResp_PreferError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		err  error
+		code int
+		body string
+	}{
+		{
+			fmt.Errorf("pay: %w", orderLocked.Errorf("locked by job 9").With("order", "o1")),
+			http.StatusConflict,
+			`{"code":"ginx_test.order_locked","message":"order is locked","details":{"order":"o1"}}`,
+		},
+		{
+			errorx.NewAccumulator().DoLabeled("email", func() error { return errorx.Prefer400("is invalid") }).Err(),
+			http.StatusBadRequest,
+			`{"message":"1 error occurred","errors":[{"field":"email","message":"is invalid"}]}`,
+		},
+		{orderLocked, http.StatusConflict, `{"code":"ginx_test.order_locked","message":"order is locked"}`},
+		{errorx.NoAuth, http.StatusUnauthorized, "unauthorized"},
+		{errors.New("bad input"), http.StatusBadRequest, "bad input"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ginx.Resp.PreferError(ctx, c.err)
+		assert.Equal(t, c.code, w.Code)
+		if w.Header().Get("Content-Type") == "application/json; charset=utf-8" {
+			assert.JSONEq(t, c.body, w.Body.String())
+		} else {
+			assert.Equal(t, c.body, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package gormx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Actions recorded in ChangeLog.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+const auditBeforeKey = "gormx:audit_before"
+
+type operatorKey struct{}
+
+// WithOperator returns a context carrying the operator, e.g. the user id, of the changes made
+// with it, which the Auditor fills into CreatedBy and UpdatedBy.
+func WithOperator(ctx context.Context, operator string) context.Context {
+	return context.WithValue(ctx, operatorKey{}, operator)
+}
+
+// Operator returns the operator carried by ctx, or "" if there is none.
+func Operator(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	op, _ := ctx.Value(operatorKey{}).(string)
+	return op
+}
+
+// AuditFields can be embedded in models to have the operator filled by the Auditor.
+type AuditFields struct {
+	CreatedBy string `gorm:"size:64" json:"createdBy"`
+	UpdatedBy string `gorm:"size:64" json:"updatedBy"`
+}
+
+// Auditable is implemented by models whose changes are written to the change log.
+type Auditable interface {
+	Audited() bool
+}
+
+// ChangeLog is a row of the change log, Before and After are the JSON of the model.
+type ChangeLog struct {
+	ID        uint64    `gorm:"primaryKey" json:"id"`
+	Table     string    `gorm:"size:64;index:idx_change_log_row" json:"table"`
+	RowID     string    `gorm:"size:64;index:idx_change_log_row" json:"rowId"`
+	Action    string    `gorm:"size:16" json:"action"`
+	Operator  string    `gorm:"size:64" json:"operator"`
+	Before    string    `gorm:"type:text" json:"before"`
+	After     string    `gorm:"type:text" json:"after"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Auditor is a gorm plugin which fills the CreatedBy and UpdatedBy fields from the operator of
+// the context, see WithOperator, and writes a ChangeLog row for every change of Auditable models
+// in the same transaction as the change.
+//
+//	db.Use(&gormx.Auditor{})
+//	db.WithContext(gormx.WithOperator(ctx, uid)).Create(&order)
+//
+// Changes are logged per row: created values, and updated or deleted values identified by their
+// primary key, e.g. db.Model(&order).Updates(...) or db.Delete(&order). Batch updates and deletes
+// by conditions are not logged.
+type Auditor struct {
+	// Table is the table of the change log, "change_logs" by default.
+	Table string
+}
+
+func (a *Auditor) Name() string {
+	return "gormx:auditor"
+}
+
+func (a *Auditor) Initialize(db *gorm.DB) error {
+	if a.Table == "" {
+		a.Table = "change_logs"
+	}
+	// the change log is written in the transaction of the change, before it commits
+	const commit = "gorm:commit_or_rollback_transaction"
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("gormx:audit_before_create", a.beforeCreate); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Before(commit).Register("gormx:audit_after_create", a.afterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("gormx:audit_before_update", a.beforeUpdate); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Before(commit).Register("gormx:audit_after_update", a.afterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("gormx:audit_before_delete", a.loadBefore); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Before(commit).Register("gormx:audit_after_delete", a.afterDelete)
+}
+
+func (a *Auditor) beforeCreate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	op := Operator(db.Statement.Context)
+	if op == "" {
+		return
+	}
+	for _, name := range []string{"CreatedBy", "UpdatedBy"} {
+		if db.Statement.Schema.LookUpField(name) != nil {
+			db.Statement.SetColumn(name, op, true)
+		}
+	}
+}
+
+func (a *Auditor) afterCreate(db *gorm.DB) {
+	if db.Error != nil || db.RowsAffected < 1 || !a.audited(db) {
+		return
+	}
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			a.write(db, AuditCreate, reflect.Indirect(rv.Index(i)), nil)
+		}
+	case reflect.Struct:
+		a.write(db, AuditCreate, rv, nil)
+	}
+}
+
+func (a *Auditor) beforeUpdate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	if _, ok := db.Statement.Get("gorm:update_column"); !ok {
+		if op := Operator(db.Statement.Context); op != "" {
+			if f := db.Statement.Schema.LookUpField("UpdatedBy"); f != nil {
+				db.Statement.SetColumn(f.Name, op, true)
+				if len(db.Statement.Selects) > 0 {
+					db.Statement.Selects = append(db.Statement.Selects, f.DBName)
+				}
+			}
+		}
+	}
+	a.loadBefore(db)
+}
+
+func (a *Auditor) afterUpdate(db *gorm.DB) {
+	if db.Error != nil || db.RowsAffected < 1 {
+		return
+	}
+	before, ok := db.InstanceGet(auditBeforeKey)
+	if !ok {
+		return
+	}
+	after, err := a.load(db)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	a.write(db, AuditUpdate, after, before)
+}
+
+func (a *Auditor) afterDelete(db *gorm.DB) {
+	if db.Error != nil || db.RowsAffected < 1 {
+		return
+	}
+	if before, ok := db.InstanceGet(auditBeforeKey); ok {
+		a.write(db, AuditDelete, reflect.Value{}, before)
+	}
+}
+
+// loadBefore stores the current row of an auditable model identified by its primary key.
+func (a *Auditor) loadBefore(db *gorm.DB) {
+	if db.Error != nil || !a.audited(db) {
+		return
+	}
+	if _, zero := a.primaryKey(db); zero {
+		return
+	}
+	before, err := a.load(db)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	db.InstanceSet(auditBeforeKey, before)
+}
+
+// load reads the row of the statement's model by its primary key, in the same transaction.
+func (a *Auditor) load(db *gorm.DB) (reflect.Value, error) {
+	pk, _ := a.primaryKey(db)
+	sch := db.Statement.Schema
+	ptr := reflect.New(sch.ModelType)
+	err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Unscoped().
+		Table(db.Statement.Table).
+		Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: sch.PrioritizedPrimaryField.DBName}, Value: pk}).
+		Take(ptr.Interface()).Error
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("audit: load %s %v: %w", sch.Table, pk, err)
+	}
+	return ptr.Elem(), nil
+}
+
+func (a *Auditor) write(db *gorm.DB, action string, after reflect.Value, before any) {
+	sch := db.Statement.Schema
+	log := ChangeLog{
+		Table:    db.Statement.Table,
+		Action:   action,
+		Operator: Operator(db.Statement.Context),
+	}
+	var key any
+	if bv, ok := before.(reflect.Value); ok {
+		key, _ = sch.PrioritizedPrimaryField.ValueOf(db.Statement.Context, bv)
+		bs, err := json.Marshal(bv.Interface())
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		log.Before = string(bs)
+	}
+	if after.IsValid() {
+		key, _ = sch.PrioritizedPrimaryField.ValueOf(db.Statement.Context, after)
+		bs, err := json.Marshal(after.Interface())
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		log.After = string(bs)
+	}
+	log.RowID = fmt.Sprint(key)
+	err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(a.Table).Create(&log).Error
+	if err != nil {
+		_ = db.AddError(fmt.Errorf("audit: write change log: %w", err))
+	}
+}
+
+func (a *Auditor) primaryKey(db *gorm.DB) (any, bool) {
+	rv := db.Statement.ReflectValue
+	if rv.Kind() != reflect.Struct {
+		return nil, true
+	}
+	return db.Statement.Schema.PrioritizedPrimaryField.ValueOf(db.Statement.Context, rv)
+}
+
+// audited reports whether the statement's model opts in to the change log.
+func (a *Auditor) audited(db *gorm.DB) bool {
+	sch := db.Statement.Schema
+	if sch == nil || sch.PrioritizedPrimaryField == nil {
+		return false
+	}
+	m, ok := reflect.New(sch.ModelType).Interface().(Auditable)
+	return ok && m.Audited()
+}
+
+var _ gorm.Plugin = (*Auditor)(nil)
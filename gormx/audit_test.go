@@ -0,0 +1,77 @@
+package gormx_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chain-products-org/goal/gormx"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/stretchr/testify/assert"
+)
+
+type Doc struct {
+	ID    uint64
+	Title string
+	gormx.AuditFields
+}
+
+func (Doc) Audited() bool { return true }
+
+func TestAuditor(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	assert.Nil(t, mock.DB.Use(&gormx.Auditor{}))
+	db := mock.DB.WithContext(gormx.WithOperator(context.Background(), "alice"))
+	insertLog := regexp.QuoteMeta("INSERT INTO `change_logs` (`table`,`row_id`,`action`,`operator`,`before`,`after`,`created_at`) VALUES (?,?,?,?,?,?,?)")
+	selectDoc := regexp.QuoteMeta("SELECT * FROM `docs` WHERE `docs`.`id` = ? LIMIT ?")
+	docRows := func(title string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "title", "created_by", "updated_by"}).AddRow(1, title, "alice", "alice")
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `docs` (`title`,`created_by`,`updated_by`) VALUES (?,?,?)")).
+		WithArgs("a", "alice", "alice").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertLog).
+		WithArgs("docs", "1", gormx.AuditCreate, "alice", "", `{"ID":1,"Title":"a","createdBy":"alice","updatedBy":"alice"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	doc := &Doc{Title: "a"}
+	assert.Nil(t, db.Create(doc).Error)
+	assert.Equal(t, "alice", doc.CreatedBy)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectDoc).WithArgs(1, 1).WillReturnRows(docRows("a"))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `docs` SET `title`=?,`updated_by`=? WHERE `id` = ?")).
+		WithArgs("b", "alice", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(selectDoc).WithArgs(1, 1).WillReturnRows(docRows("b"))
+	mock.ExpectExec(insertLog).
+		WithArgs("docs", "1", gormx.AuditUpdate, "alice",
+			`{"ID":1,"Title":"a","createdBy":"alice","updatedBy":"alice"}`,
+			`{"ID":1,"Title":"b","createdBy":"alice","updatedBy":"alice"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	assert.Nil(t, db.Model(doc).Update("Title", "b").Error)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectDoc).WithArgs(1, 1).WillReturnRows(docRows("b"))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `docs` WHERE `docs`.`id` = ?")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertLog).
+		WithArgs("docs", "1", gormx.AuditDelete, "alice", `{"ID":1,"Title":"b","createdBy":"alice","updatedBy":"alice"}`, "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+	ok, err := gormx.DeleteResult[Doc](db.Delete(doc))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	// not auditable: only the operator is filled
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	assert.Nil(t, db.Create(&User{Name: "a"}).Error)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
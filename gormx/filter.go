@@ -0,0 +1,170 @@
+package gormx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Scope is a gorm scope, which is used with db.Scopes or as a query condition of Repo.
+type Scope = func(db *gorm.DB) *gorm.DB
+
+// Filter operators used in the "filter" struct tag.
+const (
+	OpEq     = "eq"
+	OpNe     = "ne"
+	OpGt     = "gt"
+	OpGte    = "gte"
+	OpLt     = "lt"
+	OpLte    = "lte"
+	OpLike   = "like"   // %v%
+	OpPrefix = "prefix" // v%
+	OpSuffix = "suffix" // %v
+	OpIn     = "in"
+	OpNotIn  = "notin"
+)
+
+// Filter builds a scope from a struct of optional fields, every field that is set becomes a where
+// condition. Nil pointers, empty slices and zero values are ignored, so a request struct bound
+// by gin can be passed directly:
+//
+//	type UserFilter struct {
+//		Name     *string `filter:"name,like"`
+//		Status   []int   `filter:"status"` // in
+//		MinAge   *int    `filter:"age,gte"`
+//		MaxAge   *int    `filter:"age,lte"`
+//		Internal string  `filter:"-"`
+//	}
+//
+// The tag is "column,op", column defaults to the column name of the field by the naming strategy
+// of the db, and op defaults to "eq", or "in" for slices.
+func Filter(f any) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		v := reflect.Indirect(reflect.ValueOf(f))
+		if v.Kind() != reflect.Struct {
+			_ = db.AddError(fmt.Errorf("filter requires a struct, but got %T", f))
+			return db
+		}
+		exprs, err := filterExprs(db, v)
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		if len(exprs) == 0 {
+			return db
+		}
+		return db.Where(clause.And(exprs...))
+	}
+}
+
+func filterExprs(db *gorm.DB, v reflect.Value) ([]clause.Expression, error) {
+	var exprs []clause.Expression
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("filter")
+		if tag == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		// embedded structs are flattened
+		if sf.Anonymous && reflect.Indirect(fv).Kind() == reflect.Struct && tag == "" {
+			if fv.Kind() == reflect.Pointer && fv.IsNil() {
+				continue
+			}
+			sub, err := filterExprs(db, reflect.Indirect(fv))
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, sub...)
+			continue
+		}
+		if isUnset(fv) {
+			continue
+		}
+		fv = reflect.Indirect(fv)
+
+		column, op, _ := strings.Cut(tag, ",")
+		if column == "" {
+			column = db.NamingStrategy.ColumnName("", sf.Name)
+		}
+		if op == "" {
+			op = OpEq
+			if fv.Kind() == reflect.Slice {
+				op = OpIn
+			}
+		}
+		expr, err := filterExpr(clause.Column{Name: column}, op, fv)
+		if err != nil {
+			return nil, fmt.Errorf("filter field %s: %w", sf.Name, err)
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+func filterExpr(col clause.Column, op string, v reflect.Value) (clause.Expression, error) {
+	value := v.Interface()
+	switch op {
+	case OpEq:
+		return clause.Eq{Column: col, Value: value}, nil
+	case OpNe:
+		return clause.Neq{Column: col, Value: value}, nil
+	case OpGt:
+		return clause.Gt{Column: col, Value: value}, nil
+	case OpGte:
+		return clause.Gte{Column: col, Value: value}, nil
+	case OpLt:
+		return clause.Lt{Column: col, Value: value}, nil
+	case OpLte:
+		return clause.Lte{Column: col, Value: value}, nil
+	case OpLike:
+		return like(col, "%"+escapeLike(fmt.Sprint(value))+"%"), nil
+	case OpPrefix:
+		return like(col, escapeLike(fmt.Sprint(value))+"%"), nil
+	case OpSuffix:
+		return like(col, "%"+escapeLike(fmt.Sprint(value))), nil
+	case OpIn, OpNotIn:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("operator %s requires a slice", op)
+		}
+		values := make([]any, v.Len())
+		for i := range values {
+			values[i] = v.Index(i).Interface()
+		}
+		in := clause.IN{Column: col, Values: values}
+		if op == OpNotIn {
+			return clause.Not(in), nil
+		}
+		return in, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+func isUnset(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// "!" is used as the escape character of LIKE, since the backslash is not portable between
+// MySQL and SQLite.
+var likeReplacer = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func escapeLike(s string) string {
+	return likeReplacer.Replace(s)
+}
+
+func like(col clause.Column, pattern string) clause.Expression {
+	return clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []any{col, pattern}}
+}
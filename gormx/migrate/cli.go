@@ -0,0 +1,109 @@
+package migrate
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/chain-products-org/goal/cmd"
+	"github.com/urfave/cli/v2"
+)
+
+// Command returns the "migrate" command with the subcommands up, down and status. newMigrator
+// creates the Migrator with the given options, which carry the --dry-run flag.
+//
+//	app := &cli.App{Commands: []*cli.Command{migrate.Command(func(c *cli.Context, opts ...migrate.Option) (*migrate.Migrator, error) {
+//		return migrate.New(db, migrations, append(opts, migrate.WithLocker(locker))...)
+//	})}}
+func Command(newMigrator func(c *cli.Context, opts ...Option) (*Migrator, error)) *cli.Command {
+	open := func(c *cli.Context) (*Migrator, error) {
+		var opts []Option
+		if c.Bool("dry-run") {
+			opts = append(opts, WithDryRun(c.App.Writer))
+		}
+		return newMigrator(c, opts...)
+	}
+	return &cli.Command{
+		Name:  "migrate",
+		Usage: "run database migrations",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "dry-run", Usage: "print the sql instead of executing it"},
+		},
+		Subcommands: []*cli.Command{
+			{
+				Name:  "up",
+				Usage: "apply the pending migrations",
+				Flags: []cli.Flag{
+					&cli.Int64Flag{Name: "to", Usage: "apply up to this version, all by default"},
+				},
+				Action: func(c *cli.Context) error {
+					m, err := open(c)
+					if err != nil {
+						return err
+					}
+					done, err := m.UpTo(c.Context, c.Int64("to"))
+					for _, mg := range done {
+						fmt.Fprintf(c.App.Writer, "applied %d_%s\n", mg.Version, mg.Name)
+					}
+					return err
+				},
+			},
+			{
+				Name:  "down",
+				Usage: "roll back the last applied migrations",
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "steps", Value: 1, Usage: "number of migrations to roll back"},
+					&cli.BoolFlag{Name: "q", Usage: "skip the confirmation"},
+				},
+				Action: func(c *cli.Context) error {
+					m, err := open(c)
+					if err != nil {
+						return err
+					}
+					steps := c.Int("steps")
+					title := fmt.Sprintf("确定要回滚最近的 %d 个迁移吗? ", steps)
+					if c.Bool("dry-run") {
+						title = "[dry run] " + title
+					}
+					cmd.Confirm(c, title, func() {
+						var done []Migration
+						done, err = m.Down(c.Context, steps)
+						for _, mg := range done {
+							fmt.Fprintf(c.App.Writer, "rolled back %d_%s\n", mg.Version, mg.Name)
+						}
+					})
+					return err
+				},
+			},
+			{
+				Name:  "status",
+				Usage: "list the migrations and whether they are applied",
+				Action: func(c *cli.Context) error {
+					m, err := open(c)
+					if err != nil {
+						return err
+					}
+					list, err := m.Status(c.Context)
+					if err != nil {
+						return err
+					}
+					w := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+					fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+					for _, s := range list {
+						status, at := "pending", ""
+						if s.Applied {
+							status, at = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+						}
+						if s.Modified {
+							status += " (modified)"
+						}
+						if s.Missing {
+							status += " (missing)"
+						}
+						fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, status, at)
+					}
+					return w.Flush()
+				},
+			},
+		},
+	}
+}
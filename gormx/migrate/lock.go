@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chain-products-org/goal/redisx"
+	"gorm.io/gorm"
+)
+
+// Locker is a lock shared by the replicas of an app, held while migrating.
+type Locker interface {
+	// Lock blocks until the lock is acquired or ctx is done, and returns the func to release it.
+	Lock(ctx context.Context) (unlock func() error, err error)
+}
+
+// LockerFunc adapts a func to a Locker.
+type LockerFunc func(ctx context.Context) (func() error, error)
+
+func (f LockerFunc) Lock(ctx context.Context) (func() error, error) {
+	return f(ctx)
+}
+
+// MySQLLocker returns a Locker using the MySQL advisory lock GET_LOCK(name). The lock belongs
+// to a session, so a connection is reserved until it is released.
+func MySQLLocker(db *gorm.DB, name string) Locker {
+	return LockerFunc(func(ctx context.Context) (func() error, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			var got sql.NullInt64
+			if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, 1).Scan(&got); err != nil {
+				_ = conn.Close()
+				return nil, err
+			}
+			if got.Valid && got.Int64 == 1 {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				_ = conn.Close()
+				return nil, ctx.Err()
+			default:
+			}
+		}
+		return func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+			return errors.Join(err, conn.Close())
+		}, nil
+	})
+}
+
+// RedisLocker returns a Locker using a redisx.Lock on key, which is renewed every third of ttl
+// while it is held, so that it expires soon if the holder dies.
+func RedisLocker(rc redisx.Client, key string, ttl time.Duration) Locker {
+	return LockerFunc(func(ctx context.Context) (func() error, error) {
+		seconds := uint32(ttl / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		lock := redisx.NewLock(rc, key, seconds)
+		if err := lock.AcquireWaitCtx(ctx); err != nil {
+			return nil, err
+		}
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			ticker := time.NewTicker(time.Duration(seconds) * time.Second / 3)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					// the lock is reentrant, acquiring it again extends its expiration
+					_, _ = lock.AcquireCtx(context.Background())
+				}
+			}
+		}()
+		return func() error {
+			close(stop)
+			<-stopped
+			ok, err := lock.Release()
+			if err == nil && !ok {
+				err = fmt.Errorf("migration lock %s expired before released", key)
+			}
+			return err
+		}, nil
+	})
+}
@@ -0,0 +1,396 @@
+// Package migrate runs versioned SQL migrations.
+//
+// Migrations are pairs of files named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// e.g. 0001_create_users.up.sql, usually embedded with embed.FS:
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	sub, _ := fs.Sub(migrations, "migrations")
+//	m, err := migrate.New(db, sub, migrate.WithLocker(migrate.MySQLLocker(db, "app_migrate")))
+//	applied, err := m.Up(ctx)
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ChecksumError = errors.New("checksum of applied migration mismatch")
+	NoDownError   = errors.New("migration has no down sql")
+)
+
+// Migration is a versioned pair of up and down SQL scripts.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of Up
+}
+
+// Status is a migration and whether it is applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set if the up sql changed since the migration was applied.
+	Modified bool
+	// Missing is set if the migration is applied but its files are gone.
+	Missing bool
+}
+
+type record struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Load reads the migrations in the root of fsys, sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, file := range files {
+		base := path.Base(file)
+		var up bool
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			up = true
+			base = strings.TrimSuffix(base, ".up.sql")
+		case strings.HasSuffix(base, ".down.sql"):
+			base = strings.TrimSuffix(base, ".down.sql")
+		default:
+			return nil, fmt.Errorf("migration %s must end with .up.sql or .down.sql", file)
+		}
+		v, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s must start with a version number", file)
+		}
+		bs, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, name)
+		}
+		if up {
+			m.Up = string(bs)
+			sum := sha256.Sum256(bs)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(bs)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up sql", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Option configures a Migrator.
+type Option func(m *Migrator)
+
+// WithTable sets the table recording the applied migrations, "schema_migrations" by default.
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithLocker sets the lock held while migrating, so that only one replica migrates at a time.
+func WithLocker(locker Locker) Option {
+	return func(m *Migrator) {
+		m.locker = locker
+	}
+}
+
+// WithDryRun makes the migrator write the SQL it would execute to w instead of executing it.
+func WithDryRun(w io.Writer) Option {
+	return func(m *Migrator) {
+		m.dryRun = w
+	}
+}
+
+// Migrator applies and rolls back migrations.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	table      string
+	locker     Locker
+	dryRun     io.Writer
+}
+
+// New loads the migrations of fsys, see Load, and returns a Migrator for them.
+func New(db *gorm.DB, fsys fs.FS, opts ...Option) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	m := &Migrator{db: db, migrations: migrations, table: "schema_migrations"}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Migrations returns the loaded migrations.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Status lists the migrations, and the applied ones whose files are missing, by version. The
+// table of the applied migrations is created if absent, except in a dry run, which takes a
+// missing table as nothing applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	exists := true
+	if m.dryRun != nil {
+		exists = m.db.WithContext(ctx).Migrator().HasTable(m.table)
+	} else if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied := map[int64]record{}
+	if exists {
+		var err error
+		if applied, err = m.applied(ctx); err != nil {
+			return nil, err
+		}
+	}
+	list := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		s := Status{Migration: mg}
+		if r, ok := applied[mg.Version]; ok {
+			s.Applied, s.AppliedAt = true, r.AppliedAt
+			s.Modified = r.Checksum != mg.Checksum
+			delete(applied, mg.Version)
+		}
+		list = append(list, s)
+	}
+	for _, r := range applied {
+		list = append(list, Status{
+			Migration: Migration{Version: r.Version, Name: r.Name, Checksum: r.Checksum},
+			Applied:   true, AppliedAt: r.AppliedAt, Missing: true,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list, nil
+}
+
+// Up applies all pending migrations, see UpTo.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.UpTo(ctx, 0)
+}
+
+// UpTo applies the pending migrations up to version, or all if version is 0, in order, and
+// returns the applied ones. It fails before applying anything if an applied migration has been
+// modified since.
+func (m *Migrator) UpTo(ctx context.Context, version int64) (done []Migration, err error) {
+	err = m.withLock(ctx, func() error {
+		list, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range list {
+			if s.Modified {
+				return fmt.Errorf("%w: %d_%s", ChecksumError, s.Version, s.Name)
+			}
+		}
+		for _, s := range list {
+			if s.Applied || (version > 0 && s.Version > version) {
+				continue
+			}
+			if err := m.run(ctx, s.Migration, true); err != nil {
+				return err
+			}
+			done = append(done, s.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the last n applied migrations, in reverse order, and returns them.
+func (m *Migrator) Down(ctx context.Context, n int) (done []Migration, err error) {
+	err = m.withLock(ctx, func() error {
+		list, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(list) - 1; i >= 0 && len(done) < n; i-- {
+			s := list[i]
+			if !s.Applied {
+				continue
+			}
+			if s.Missing || s.Down == "" {
+				return fmt.Errorf("%w: %d_%s", NoDownError, s.Version, s.Name)
+			}
+			if err := m.run(ctx, s.Migration, false); err != nil {
+				return err
+			}
+			done = append(done, s.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if m.locker == nil {
+		return fn()
+	}
+	unlock, err := m.locker.Lock(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	err = fn()
+	return errors.Join(err, unlock())
+}
+
+// run applies, or rolls back, a migration and records it in a transaction. Note that MySQL
+// commits DDL statements implicitly, so a failed migration may be applied partially.
+func (m *Migrator) run(ctx context.Context, mg Migration, up bool) error {
+	script, direction := mg.Up, "up"
+	if !up {
+		script, direction = mg.Down, "down"
+	}
+	stmts := Split(script)
+	if m.dryRun != nil {
+		_, err := fmt.Fprintf(m.dryRun, "-- %s %d_%s\n", direction, mg.Version, mg.Name)
+		for _, stmt := range stmts {
+			if err == nil {
+				_, err = fmt.Fprintf(m.dryRun, "%s;\n", stmt)
+			}
+		}
+		return err
+	}
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		if up {
+			return tx.Exec("INSERT INTO "+m.table+" (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				mg.Version, mg.Name, mg.Checksum, time.Now()).Error
+		}
+		return tx.Exec("DELETE FROM "+m.table+" WHERE version = ?", mg.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate %s %d_%s: %w", direction, mg.Version, mg.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	return m.db.WithContext(ctx).Exec("CREATE TABLE IF NOT EXISTS " + m.table + ` (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	checksum CHAR(64) NOT NULL,
+	applied_at DATETIME NOT NULL
+)`).Error
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]record, error) {
+	var records []record
+	err := m.db.WithContext(ctx).
+		Raw("SELECT version, name, checksum, applied_at FROM " + m.table + " ORDER BY version").
+		Scan(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// Split splits a script into statements by the semicolons outside of quotes and comments,
+// empty statements are dropped.
+func Split(script string) []string {
+	var (
+		stmts []string
+		start int
+		quote byte
+	)
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '#' || (c == '-' && strings.HasPrefix(script[i:], "--")):
+			if end := strings.IndexByte(script[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(script)
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			if end := strings.Index(script[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(script)
+			}
+		case c == ';':
+			stmts = appendStmt(stmts, script[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(script) {
+		stmts = appendStmt(stmts, script[start:])
+	}
+	return stmts
+}
+
+func appendStmt(stmts []string, stmt string) []string {
+	stmt = strings.TrimSpace(stmt)
+	if stmt == "" || onlyComments(stmt) {
+		return stmts
+	}
+	return append(stmts, stmt)
+}
+
+func onlyComments(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
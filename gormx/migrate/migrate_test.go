@@ -0,0 +1,173 @@
+package migrate_test
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chain-products-org/goal/gormx/migrate"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+var fsys = fstest.MapFS{
+	"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id BIGINT PRIMARY KEY, name VARCHAR(64));\n-- seed\nINSERT INTO users VALUES (1, 'a;b');")},
+	"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	"0002_add_age.up.sql":        {Data: []byte("ALTER TABLE users ADD COLUMN age INT;")},
+	"0002_add_age.down.sql":      {Data: []byte("ALTER TABLE users DROP COLUMN age;")},
+}
+
+var (
+	createTable   = regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")
+	selectApplied = regexp.QuoteMeta("SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version")
+	insertApplied = regexp.QuoteMeta("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)")
+	appliedCols   = []string{"version", "name", "checksum", "applied_at"}
+)
+
+func TestSplit(t *testing.T) {
+	stmts := migrate.Split("INSERT INTO t VALUES ('a;b', \"c;\\\"d\");\n-- comment;\n/* block; */ UPDATE t SET a = 1;\n# done;\n")
+	assert.Equal(t, []string{
+		"INSERT INTO t VALUES ('a;b', \"c;\\\"d\")",
+		"-- comment;\n/* block; */ UPDATE t SET a = 1",
+	}, stmts)
+}
+
+func TestLoad(t *testing.T) {
+	ms, err := migrate.Load(fsys)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(ms))
+	assert.Equal(t, int64(1), ms[0].Version)
+	assert.Equal(t, "create_users", ms[0].Name)
+	assert.Equal(t, 64, len(ms[0].Checksum))
+	assert.Equal(t, "DROP TABLE users;", ms[0].Down)
+
+	_, err = migrate.Load(fstest.MapFS{"x_bad.up.sql": {Data: []byte("SELECT 1")}})
+	assert.NotNil(t, err)
+	_, err = migrate.Load(fstest.MapFS{"0003_only_down.down.sql": {Data: []byte("SELECT 1")}})
+	assert.NotNil(t, err)
+}
+
+func TestMigrator_Up(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	m, err := migrate.New(mock.DB, fsys)
+	assert.Nil(t, err)
+	ms := m.Migrations()
+
+	mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectApplied).WillReturnRows(sqlmock.NewRows(appliedCols).
+		AddRow(1, "create_users", ms[0].Checksum, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE users ADD COLUMN age INT")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(insertApplied).WithArgs(2, "add_age", ms[1].Checksum, testx.AnyTime{}).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	done, err := m.Up(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(done))
+	assert.Equal(t, int64(2), done[0].Version)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_ChecksumMismatch(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	m, _ := migrate.New(mock.DB, fsys)
+
+	mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectApplied).WillReturnRows(sqlmock.NewRows(appliedCols).
+		AddRow(1, "create_users", "changed", time.Now()))
+
+	done, err := m.Up(context.Background())
+	assert.ErrorIs(t, err, migrate.ChecksumError)
+	assert.Empty(t, done)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_DryRunDown(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	out := &bytes.Buffer{}
+	m, _ := migrate.New(mock.DB, fsys, migrate.WithDryRun(out))
+	ms := m.Migrations()
+
+	expectHasTable(mock, true)
+	mock.ExpectQuery(selectApplied).WillReturnRows(sqlmock.NewRows(appliedCols).
+		AddRow(1, "create_users", ms[0].Checksum, time.Now()).
+		AddRow(2, "add_age", ms[1].Checksum, time.Now()))
+
+	done, err := m.Down(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(done))
+	assert.Equal(t, "-- down 2_add_age\nALTER TABLE users DROP COLUMN age;\n", out.String())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_DryRunUp(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	out := &bytes.Buffer{}
+	m, _ := migrate.New(mock.DB, fsys, migrate.WithDryRun(out))
+
+	// no DDL is run, and a missing table is nothing applied
+	expectHasTable(mock, false)
+
+	done, err := m.Up(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(done))
+	assert.Contains(t, out.String(), "-- up 1_create_users\nCREATE TABLE users")
+	assert.Contains(t, out.String(), "-- up 2_add_age\nALTER TABLE users ADD COLUMN age INT;\n")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func expectHasTable(mock *testx.MockGorm, exists bool) {
+	n := 0
+	if exists {
+		n = 1
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT DATABASE()")).
+		WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow("app"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT SCHEMA_NAME from Information_schema.SCHEMATA")).
+		WillReturnRows(sqlmock.NewRows([]string{"SCHEMA_NAME"}).AddRow("app"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM information_schema.tables WHERE table_schema = ? AND table_name = ?")).
+		WithArgs("app", "schema_migrations", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(n))
+}
+
+func TestRedisLocker(t *testing.T) {
+	rc := testx.NewMiniRedis()
+	locker := migrate.RedisLocker(rc, "migrate", time.Second)
+	unlock, err := locker.Lock(context.Background())
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_, err = locker.Lock(ctx)
+	assert.NotNil(t, err, "lock must be exclusive")
+
+	assert.Nil(t, unlock())
+	unlock, err = locker.Lock(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, unlock())
+}
+
+func TestCommand_Status(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	out := &bytes.Buffer{}
+	app := &cli.App{Writer: out, Commands: []*cli.Command{
+		migrate.Command(func(c *cli.Context, opts ...migrate.Option) (*migrate.Migrator, error) {
+			return migrate.New(mock.DB, fsys, opts...)
+		}),
+	}}
+	ms, _ := migrate.Load(fsys)
+
+	mock.ExpectExec(createTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectApplied).WillReturnRows(sqlmock.NewRows(appliedCols).
+		AddRow(1, "create_users", ms[0].Checksum, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	assert.Nil(t, app.Run([]string{"app", "migrate", "status"}))
+	assert.Contains(t, out.String(), "1        create_users  applied  2024-01-02 03:04:05")
+	assert.Contains(t, out.String(), "2        add_age       pending")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
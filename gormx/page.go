@@ -0,0 +1,117 @@
+package gormx
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/chain-products-org/goal/ciphers/base64x"
+)
+
+var (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	CursorError = errors.New("invalid cursor")
+)
+
+// PageReq is an offset pagination request, it can be bound from query string by gin, e.g.
+// ctx.ShouldBindQuery(&req).
+type PageReq struct {
+	Page int `form:"page" json:"page"`
+	Size int `form:"size" json:"size"`
+}
+
+// Normalize makes Page start from 1 and limits Size to (0, MaxPageSize].
+func (r PageReq) Normalize() PageReq {
+	if r.Page < 1 {
+		r.Page = 1
+	}
+	if r.Size < 1 {
+		r.Size = DefaultPageSize
+	}
+	if r.Size > MaxPageSize {
+		r.Size = MaxPageSize
+	}
+	return r
+}
+
+// Offset returns the offset of the first row of the page.
+func (r PageReq) Offset() int {
+	return (r.Page - 1) * r.Size
+}
+
+// Page is a page of items returned by offset pagination. It is ready to be responded as JSON,
+// e.g. ginx.Resp.OkJson(ctx, page).
+type Page[T any] struct {
+	Items []T   `json:"items"`
+	Total int64 `json:"total"`
+	Page  int   `json:"page"`
+	Size  int   `json:"size"`
+}
+
+// Pages returns the total number of pages.
+func (p *Page[T]) Pages() int64 {
+	if p.Size <= 0 {
+		return 0
+	}
+	return (p.Total + int64(p.Size) - 1) / int64(p.Size)
+}
+
+// CursorReq is a keyset pagination request. Cursor is the opaque NextCursor of the previous
+// page, empty for the first page.
+type CursorReq struct {
+	Cursor string `form:"cursor" json:"cursor"`
+	Size   int    `form:"size" json:"size"`
+}
+
+// CursorPage is a page of items returned by keyset pagination.
+type CursorPage[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+}
+
+// Keyset describes the ordering of keyset pagination. Rows are ordered by Column and then by
+// Key, which must be unique, to break ties.
+type Keyset struct {
+	Column string // order column, defaults to Key
+	Key    string // unique tie-breaker column, defaults to "id"
+	Desc   bool
+}
+
+func (k Keyset) normalize() Keyset {
+	if k.Key == "" {
+		k.Key = "id"
+	}
+	if k.Column == "" {
+		k.Column = k.Key
+	}
+	return k
+}
+
+// encodeCursor encodes the values of the last row into an opaque cursor.
+func encodeCursor(vs ...any) (string, error) {
+	bs, err := json.Marshal(vs)
+	if err != nil {
+		return "", err
+	}
+	return base64x.RawURLEncoding.Encode(bs), nil
+}
+
+// decodeCursor decodes a cursor into the given pointers.
+func decodeCursor(cursor string, ptrs ...any) error {
+	bs, err := base64x.RawURLEncoding.Decode(cursor, true)
+	if err != nil {
+		return CursorError
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal(bs, &raws); err != nil || len(raws) != len(ptrs) {
+		return CursorError
+	}
+	for i, raw := range raws {
+		if err := json.Unmarshal(raw, ptrs[i]); err != nil {
+			return CursorError
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Repo is a generic repository of the model T, which provides CRUD and pagination.
+type Repo[T any] struct {
+	db *gorm.DB
+}
+
+// NewRepo creates a Repo of model T.
+func NewRepo[T any](db *gorm.DB) *Repo[T] {
+	return &Repo[T]{db: db}
+}
+
+// DB returns a session for model T bound to ctx, it is the base of all the queries of the repo.
+// If ctx carries a transaction started by WithTx, the session joins it.
+func (r *Repo[T]) DB(ctx context.Context) *gorm.DB {
+	return DB(ctx, r.db).Model(new(T))
+}
+
+// Create inserts e.
+func (r *Repo[T]) Create(ctx context.Context, e *T) (*T, error) {
+	return InsertResult(r.DB(ctx).Create(e), e)
+}
+
+// CreateBatch inserts es in batches of batchSize.
+func (r *Repo[T]) CreateBatch(ctx context.Context, es []*T, batchSize int) error {
+	if len(es) == 0 {
+		return nil
+	}
+	_, err := InsertResult(r.DB(ctx).CreateInBatches(es, batchSize), es)
+	return err
+}
+
+// Get finds the row with the given primary key, gorm.ErrRecordNotFound is returned if it does
+// not exist.
+func (r *Repo[T]) Get(ctx context.Context, id any) (*T, error) {
+	e := new(T)
+	return QueryResult(r.DB(ctx).First(e, id), e)
+}
+
+// First finds the first row matching the scopes.
+func (r *Repo[T]) First(ctx context.Context, scopes ...Scope) (*T, error) {
+	e := new(T)
+	return QueryResult(r.DB(ctx).Scopes(scopes...).First(e), e)
+}
+
+// Find finds all the rows matching the scopes.
+func (r *Repo[T]) Find(ctx context.Context, scopes ...Scope) ([]T, error) {
+	var es []T
+	return QueryResult(r.DB(ctx).Scopes(scopes...).Find(&es), es)
+}
+
+// Count counts the rows matching the scopes.
+func (r *Repo[T]) Count(ctx context.Context, scopes ...Scope) (int64, error) {
+	var n int64
+	return QueryResult(r.DB(ctx).Scopes(scopes...).Count(&n), n)
+}
+
+// Update updates the non-zero fields of e by its primary key.
+func (r *Repo[T]) Update(ctx context.Context, e *T) (*T, error) {
+	// the model is e rather than a zero T, whose primary key would be lost
+	return UpdateResult(DB(ctx, r.db).Model(e).Updates(e), e)
+}
+
+// UpdateFields updates the given columns of the row with the primary key id.
+func (r *Repo[T]) UpdateFields(ctx context.Context, id any, fields map[string]any) error {
+	_, err := UpdateResult(r.DB(ctx).Where(r.pkCondition(id)).Updates(fields), fields)
+	return err
+}
+
+// Save inserts or updates all the fields of e.
+func (r *Repo[T]) Save(ctx context.Context, e *T) (*T, error) {
+	return UpdateResult(DB(ctx, r.db).Save(e), e)
+}
+
+// Delete deletes the row with the primary key id.
+func (r *Repo[T]) Delete(ctx context.Context, id any) (bool, error) {
+	return DeleteResult[T](r.DB(ctx).Delete(new(T), id))
+}
+
+// DeleteWhere deletes all the rows matching the scopes, at least one scope is required to avoid
+// deleting the whole table.
+func (r *Repo[T]) DeleteWhere(ctx context.Context, scope Scope, scopes ...Scope) (int64, error) {
+	res := r.DB(ctx).Scopes(append([]Scope{scope}, scopes...)...).Delete(new(T))
+	return res.RowsAffected, res.Error
+}
+
+// Page queries a page of rows matching the scopes by offset pagination. Ordering should be given
+// by the scopes, e.g. OrderBy("created_at desc").
+func (r *Repo[T]) Page(ctx context.Context, req PageReq, scopes ...Scope) (*Page[T], error) {
+	req = req.Normalize()
+	p := &Page[T]{Items: []T{}, Page: req.Page, Size: req.Size}
+	if err := r.DB(ctx).Scopes(scopes...).Count(&p.Total).Error; err != nil {
+		return nil, err
+	}
+	if p.Total == 0 || int64(req.Offset()) >= p.Total {
+		return p, nil
+	}
+	err := r.DB(ctx).Scopes(scopes...).Offset(req.Offset()).Limit(req.Size).Find(&p.Items).Error
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Cursor queries a page of rows matching the scopes by keyset pagination, which is stable and
+// efficient for deep pages. The returned NextCursor is used to query the next page.
+func (r *Repo[T]) Cursor(ctx context.Context, req CursorReq, ks Keyset, scopes ...Scope) (*CursorPage[T], error) {
+	ks = ks.normalize()
+	size := req.Size
+	if size < 1 {
+		size = DefaultPageSize
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+
+	db := r.DB(ctx)
+	if err := db.Statement.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	sch := db.Statement.Schema
+	colField, keyField := sch.LookUpField(ks.Column), sch.LookUpField(ks.Key)
+	if colField == nil || keyField == nil {
+		return nil, fmt.Errorf("keyset columns %s, %s not found in %s", ks.Column, ks.Key, sch.Name)
+	}
+
+	q := db.Scopes(scopes...)
+	if req.Cursor != "" {
+		cond, err := keysetCondition(req.Cursor, ks.Desc, colField, keyField)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where(cond)
+	}
+	q = q.Order(clause.OrderByColumn{Column: clause.Column{Name: colField.DBName}, Desc: ks.Desc})
+	if colField != keyField {
+		q = q.Order(clause.OrderByColumn{Column: clause.Column{Name: keyField.DBName}, Desc: ks.Desc})
+	}
+
+	items := make([]T, 0, size+1)
+	if err := q.Limit(size + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	p := &CursorPage[T]{Items: items}
+	if len(items) > size {
+		p.Items, p.HasMore = items[:size], true
+		last := reflect.ValueOf(&p.Items[size-1]).Elem()
+		colValue, _ := colField.ValueOf(ctx, last)
+		keyValue, _ := keyField.ValueOf(ctx, last)
+		cursor, err := encodeCursor(colValue, keyValue)
+		if err != nil {
+			return nil, err
+		}
+		p.NextCursor = cursor
+	}
+	return p, nil
+}
+
+func keysetCondition(cursor string, desc bool, colField, keyField *schema.Field) (clause.Expression, error) {
+	colPtr, keyPtr := reflect.New(colField.FieldType), reflect.New(keyField.FieldType)
+	if err := decodeCursor(cursor, colPtr.Interface(), keyPtr.Interface()); err != nil {
+		return nil, err
+	}
+	colValue, keyValue := colPtr.Elem().Interface(), keyPtr.Elem().Interface()
+	col, key := clause.Column{Name: colField.DBName}, clause.Column{Name: keyField.DBName}
+
+	after := func(c clause.Column, v any) clause.Expression {
+		if desc {
+			return clause.Lt{Column: c, Value: v}
+		}
+		return clause.Gt{Column: c, Value: v}
+	}
+	if colField == keyField {
+		return after(key, keyValue), nil
+	}
+	// (col > v) OR (col = v AND key > k)
+	return clause.Or(
+		after(col, colValue),
+		clause.And(clause.Eq{Column: col, Value: colValue}, after(key, keyValue)),
+	), nil
+}
+
+func (r *Repo[T]) pkCondition(id any) clause.Expression {
+	return clause.Eq{Column: clause.PrimaryColumn, Value: id}
+}
+
+// OrderBy returns a scope ordering by the given expression, e.g. "created_at desc".
+func OrderBy(order string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Order(order)
+	}
+}
+
+// Where returns a scope with the given condition, see gorm.DB.Where.
+func Where(query any, args ...any) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(query, args...)
+	}
+}
@@ -0,0 +1,164 @@
+package gormx_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chain-products-org/goal/gormx"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/stretchr/testify/assert"
+)
+
+type User struct {
+	ID     uint64
+	Name   string
+	Age    int
+	Status int
+}
+
+type UserFilter struct {
+	Name   *string `filter:"name,like"`
+	Status []int
+	MinAge *int    `filter:"age,gte"`
+	Ignore string  `filter:"-"`
+	Empty  *string // nil is ignored
+}
+
+func TestRepo_Page(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	repo := gormx.NewRepo[User](mock.DB)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `users` WHERE `age` > ?")).
+		WithArgs(18).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE `age` > ? ORDER BY id desc LIMIT ? OFFSET ?")).
+		WithArgs(18, 2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age"}).AddRow(1, "tom", 20))
+
+	page, err := repo.Page(context.Background(), gormx.PageReq{Page: 2, Size: 2},
+		gormx.Where("`age` > ?", 18), gormx.OrderBy("id desc"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	assert.Equal(t, int64(2), page.Pages())
+	assert.Equal(t, 2, page.Page)
+	assert.Equal(t, 1, len(page.Items))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_PageEmpty(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	repo := gormx.NewRepo[User](mock.DB)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `users`")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	page, err := repo.Page(context.Background(), gormx.PageReq{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, page.Page)
+	assert.Equal(t, gormx.DefaultPageSize, page.Size)
+	assert.NotNil(t, page.Items)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Cursor(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	repo := gormx.NewRepo[User](mock.DB)
+	ks := gormx.Keyset{Column: "age", Desc: true}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` ORDER BY `age` DESC,`id` DESC LIMIT ?")).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age"}).
+			AddRow(1, "a", 30).AddRow(2, "b", 20).AddRow(3, "c", 20))
+	page, err := repo.Cursor(context.Background(), gormx.CursorReq{Size: 2}, ks)
+	assert.Nil(t, err)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, 2, len(page.Items))
+	assert.NotEmpty(t, page.NextCursor)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE (`age` < ? OR (`age` = ? AND `id` < ?)) ORDER BY `age` DESC,`id` DESC LIMIT ?")).
+		WithArgs(20, 20, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age"}).AddRow(3, "c", 20))
+	page, err = repo.Cursor(context.Background(), gormx.CursorReq{Cursor: page.NextCursor, Size: 2}, ks)
+	assert.Nil(t, err)
+	assert.False(t, page.HasMore)
+	assert.Empty(t, page.NextCursor)
+	assert.Equal(t, 1, len(page.Items))
+	assert.Nil(t, mock.ExpectationsWereMet())
+
+	_, err = repo.Cursor(context.Background(), gormx.CursorReq{Cursor: "bad"}, ks)
+	assert.ErrorIs(t, err, gormx.CursorError)
+}
+
+func TestFilter(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	repo := gormx.NewRepo[User](mock.DB)
+
+	name, age := "t_m", 18
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE `name` LIKE ? ESCAPE '!' AND `status` IN (?,?) AND `age` >= ?")).
+		WithArgs("%t!_m%", 1, 2, 18).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "t_m"))
+
+	users, err := repo.Find(context.Background(), gormx.Filter(UserFilter{
+		Name: &name, Status: []int{1, 2}, MinAge: &age, Ignore: "x",
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(users))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_CRUD(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	repo := gormx.NewRepo[User](mock.DB)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `users` (`name`,`age`,`status`) VALUES (?,?,?)")).
+		WithArgs("tom", 20, 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	u, err := repo.Create(ctx, &User{Name: "tom", Age: 20})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), u.ID)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE `users`.`id` = ? ORDER BY `users`.`id` LIMIT ?")).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age"}).AddRow(1, "tom", 20))
+	u, err = repo.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "tom", u.Name)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `age`=? WHERE `users`.`id` = ?")).
+		WithArgs(21, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.Nil(t, repo.UpdateFields(ctx, 1, map[string]any{"age": 21}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `age`=? WHERE `id` = ?")).
+		WithArgs(22, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	_, err = repo.Update(ctx, &User{ID: 1, Age: 22})
+	assert.Nil(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `name`=?,`age`=?,`status`=? WHERE `id` = ?")).
+		WithArgs("tommy", 22, 0, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	_, err = repo.Save(ctx, &User{ID: 1, Name: "tommy", Age: 22})
+	assert.Nil(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `users` WHERE `users`.`id` = ?")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	ok, err := repo.Delete(ctx, 1)
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, gormx.DeleteError)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,114 @@
+package gormx
+
+import (
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// SoftDelete marks the rows of model matching conds as deleted by setting their deleted_at
+// column, instead of removing them. Rows already deleted are not touched, so the result can be
+// checked by DeleteResult:
+//
+//	ok, err := gormx.DeleteResult[User](gormx.SoftDelete(db, &User{}, id))
+//
+// Models with a gorm.DeletedAt field are soft deleted by db.Delete as well, SoftDelete also
+// works for models using a plain *time.Time deleted_at column.
+//
+// Without conds, model must have the value of its primary key, or else the error is
+// gorm.ErrMissingWhereClause, to avoid deleting the whole table.
+func SoftDelete(db *gorm.DB, model any, conds ...any) *gorm.DB {
+	tx := db.Model(model)
+	if len(conds) == 0 && !hasPrimaryKey(tx) {
+		_ = tx.AddError(gorm.ErrMissingWhereClause)
+		return tx
+	}
+	tx = tx.Scopes(NotDeleted)
+	if len(conds) > 0 {
+		tx = tx.Where(conds[0], conds[1:]...)
+	}
+	return tx.UpdateColumn(deletedAtColumn(tx), time.Now())
+}
+
+// Restore clears the deleted_at column of the soft deleted rows of model matching conds, which
+// are required as by SoftDelete.
+func Restore(db *gorm.DB, model any, conds ...any) *gorm.DB {
+	tx := db.Model(model)
+	if len(conds) == 0 && !hasPrimaryKey(tx) {
+		_ = tx.AddError(gorm.ErrMissingWhereClause)
+		return tx
+	}
+	tx = tx.Scopes(OnlyDeleted)
+	if len(conds) > 0 {
+		tx = tx.Where(conds[0], conds[1:]...)
+	}
+	return tx.UpdateColumn(deletedAtColumn(tx), nil)
+}
+
+// hasPrimaryKey reports whether the model of the statement has the values of its primary key,
+// which gorm adds to the conditions of the updates.
+func hasPrimaryKey(db *gorm.DB) bool {
+	stmt := db.Statement
+	if stmt.Schema == nil && stmt.Model != nil {
+		_ = stmt.Parse(stmt.Model)
+	}
+	if stmt.Schema == nil || len(stmt.Schema.PrimaryFields) == 0 {
+		return false
+	}
+	v := reflect.Indirect(reflect.ValueOf(stmt.Model))
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	for _, f := range stmt.Schema.PrimaryFields {
+		if _, zero := f.ValueOf(stmt.Context, v); zero {
+			return false
+		}
+	}
+	return true
+}
+
+// NotDeleted is a scope that excludes soft deleted rows. It is implied for models with a
+// gorm.DeletedAt field, and is useful for plain deleted_at columns and raw joins.
+func NotDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where(clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: deletedAtColumn(db)},
+		Value:  nil,
+	})
+}
+
+// OnlyDeleted is a scope that queries only the soft deleted rows.
+func OnlyDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where(clause.Neq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: deletedAtColumn(db)},
+		Value:  nil,
+	})
+}
+
+// WithDeleted is a scope that queries both the deleted and not deleted rows.
+func WithDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// deletedAtColumn finds the column of the gorm.DeletedAt field, or of the field named DeletedAt,
+// of the statement's model, "deleted_at" is used if it can not be found.
+func deletedAtColumn(db *gorm.DB) string {
+	stmt := db.Statement
+	if stmt.Schema == nil && stmt.Model != nil {
+		_ = stmt.Parse(stmt.Model)
+	}
+	if stmt.Schema != nil {
+		for _, f := range stmt.Schema.Fields {
+			if f.FieldType == deletedAtType {
+				return f.DBName
+			}
+		}
+		if f := stmt.Schema.LookUpField("DeletedAt"); f != nil {
+			return f.DBName
+		}
+	}
+	return "deleted_at"
+}
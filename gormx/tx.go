@@ -0,0 +1,230 @@
+package gormx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// MySQL error numbers of the errors that can be resolved by retrying the transaction.
+const (
+	ErLockWaitTimeout = 1205
+	ErLockDeadlock    = 1213
+)
+
+type txKey struct{}
+
+// txState is the transaction, or the savepoint of a nested transaction, stored in the context.
+type txState struct {
+	mu     sync.Mutex
+	tx     *gorm.DB
+	parent *txState
+	depth  int
+	hooks  []func()
+}
+
+func (s *txState) addHook(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, f)
+}
+
+func (s *txState) takeHooks() []func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hooks := s.hooks
+	s.hooks = nil
+	return hooks
+}
+
+type txOptions struct {
+	maxRetries int
+	backoff    time.Duration
+	sqlOptions []*sql.TxOptions
+}
+
+// TxOption configures WithTx.
+type TxOption func(o *txOptions)
+
+// TxMaxRetries sets the max times to retry the transaction on deadlocks and lock wait timeouts,
+// 3 by default, 0 disables retrying.
+func TxMaxRetries(n int) TxOption {
+	return func(o *txOptions) {
+		o.maxRetries = n
+	}
+}
+
+// TxBackoff sets the base of the exponential backoff between retries, 50ms by default.
+func TxBackoff(base time.Duration) TxOption {
+	return func(o *txOptions) {
+		o.backoff = base
+	}
+}
+
+// TxSqlOptions sets the isolation level and read only mode of the transaction.
+func TxSqlOptions(opts *sql.TxOptions) TxOption {
+	return func(o *txOptions) {
+		o.sqlOptions = []*sql.TxOptions{opts}
+	}
+}
+
+// WithTx runs fn in a transaction, which is committed if fn returns nil and rolled back otherwise,
+// or if fn panics. The transaction is stored in the context of tx, so that repositories called
+// with tx.Statement.Context, see WithTxCtx, join it automatically.
+//
+// If ctx already carries a transaction, fn runs in a nested transaction backed by a savepoint,
+// only the work of fn is rolled back on error, and the options are ignored.
+//
+// The outermost transaction is retried with exponential backoff when it fails because of a MySQL
+// deadlock (1213) or lock wait timeout (1205), so fn must be safe to run more than once.
+func WithTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error, opts ...TxOption) error {
+	if parent := txFrom(ctx); parent != nil {
+		return runSavepoint(ctx, parent, fn)
+	}
+
+	o := txOptions{maxRetries: 3, backoff: 50 * time.Millisecond}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for attempt := 0; ; attempt++ {
+		err := runTx(ctx, db, fn, o.sqlOptions)
+		if err == nil || attempt >= o.maxRetries || !IsRetryable(err) {
+			return err
+		}
+		// full jitter backoff: random(0, base * 2^attempt)
+		wait := time.Duration(rand.Int63n(int64(o.backoff<<attempt) + 1))
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// WithTxCtx is like WithTx, but passes the context carrying the transaction to fn, which is
+// convenient for code that uses repositories.
+func WithTxCtx(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error, opts ...TxOption) error {
+	return WithTx(ctx, db, func(tx *gorm.DB) error {
+		return fn(tx.Statement.Context)
+	}, opts...)
+}
+
+func runTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error, opts []*sql.TxOptions) (err error) {
+	state := &txState{}
+	ctx = context.WithValue(ctx, txKey{}, state)
+	tx := db.WithContext(ctx).Begin(opts...)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	state.tx = tx
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return err
+	}
+	committed = true
+	runHooks(ctx, tx, state.takeHooks())
+	return nil
+}
+
+func runSavepoint(ctx context.Context, parent *txState, fn func(tx *gorm.DB) error) (err error) {
+	state := &txState{parent: parent, depth: parent.depth + 1}
+	ctx = context.WithValue(ctx, txKey{}, state)
+	tx := parent.tx.WithContext(ctx)
+	state.tx = tx
+
+	name := fmt.Sprintf("sp%d", state.depth)
+	if err = tx.SavePoint(name).Error; err != nil {
+		return err
+	}
+	done := false
+	defer func() {
+		if !done { // panicked
+			tx.RollbackTo(name)
+		}
+	}()
+	err = fn(tx)
+	done = true
+	if err != nil {
+		if rbErr := tx.RollbackTo(name).Error; rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	// the hooks run only if the outermost transaction commits
+	for _, hook := range state.takeHooks() {
+		parent.addHook(hook)
+	}
+	return nil
+}
+
+func runHooks(ctx context.Context, db *gorm.DB, hooks []func()) {
+	for _, hook := range hooks {
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					db.Logger.Error(ctx, "after commit hook panicked: %v", p)
+				}
+			}()
+			hook()
+		}()
+	}
+}
+
+func txFrom(ctx context.Context) *txState {
+	if ctx == nil {
+		return nil
+	}
+	state, _ := ctx.Value(txKey{}).(*txState)
+	return state
+}
+
+// DB returns the transaction carried by ctx, or db if there is none, bound to ctx.
+func DB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if state := txFrom(ctx); state != nil {
+		return state.tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// InTx reports whether ctx carries a transaction.
+func InTx(ctx context.Context) bool {
+	return txFrom(ctx) != nil
+}
+
+// AfterCommit registers f to run after the transaction carried by ctx commits successfully, e.g.
+// to publish a message to a redisx queue only when the data is persisted. f is discarded if the
+// transaction, or the savepoint it is registered in, is rolled back. If ctx carries no
+// transaction, f runs immediately. Errors must be handled by f itself, and panics are recovered
+// and logged by the gorm logger.
+func AfterCommit(ctx context.Context, f func()) {
+	if state := txFrom(ctx); state != nil {
+		state.addHook(f)
+		return
+	}
+	f()
+}
+
+// IsRetryable reports whether err is a MySQL deadlock or lock wait timeout error.
+func IsRetryable(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == ErLockDeadlock || me.Number == ErLockWaitTimeout
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package gormx_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chain-products-org/goal/gormx"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var insertUser = regexp.QuoteMeta("INSERT INTO `users` (`name`,`age`,`status`) VALUES (?,?,?)")
+
+func TestWithTx_Commit(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	repo := gormx.NewRepo[User](mock.DB)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	var published []string
+	err := gormx.WithTxCtx(context.Background(), mock.DB, func(ctx context.Context) error {
+		assert.True(t, gormx.InTx(ctx))
+		if _, err := repo.Create(ctx, &User{Name: "a"}); err != nil {
+			return err
+		}
+		gormx.AfterCommit(ctx, func() { published = append(published, "a") })
+		_, err := repo.Create(ctx, &User{Name: "b"})
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, published)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTx_Rollback(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	repo := gormx.NewRepo[User](mock.DB)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	bizErr := errors.New("biz error")
+	hookRan := false
+	err := gormx.WithTxCtx(context.Background(), mock.DB, func(ctx context.Context) error {
+		_, _ = repo.Create(ctx, &User{Name: "a"})
+		gormx.AfterCommit(ctx, func() { hookRan = true })
+		return bizErr
+	})
+	assert.ErrorIs(t, err, bizErr)
+	assert.False(t, hookRan, "hooks must not run when rolled back")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTx_Panic(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	assert.Panics(t, func() {
+		_ = gormx.WithTx(context.Background(), mock.DB, func(tx *gorm.DB) error {
+			panic("boom")
+		})
+	})
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTx_Savepoint(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	repo := gormx.NewRepo[User](mock.DB)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SAVEPOINT sp1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	var hooks []string
+	err := gormx.WithTxCtx(context.Background(), mock.DB, func(ctx context.Context) error {
+		_, _ = repo.Create(ctx, &User{Name: "outer"})
+		nestedErr := gormx.WithTxCtx(ctx, mock.DB, func(ctx context.Context) error {
+			_, _ = repo.Create(ctx, &User{Name: "inner"})
+			gormx.AfterCommit(ctx, func() { hooks = append(hooks, "rolled back") })
+			return errors.New("inner failed")
+		})
+		assert.NotNil(t, nestedErr)
+		return gormx.WithTxCtx(ctx, mock.DB, func(ctx context.Context) error {
+			gormx.AfterCommit(ctx, func() { hooks = append(hooks, "committed") })
+			return nil
+		})
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"committed"}, hooks)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTx_RetryDeadlock(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+
+	deadlock := &mysql.MySQLError{Number: gormx.ErLockDeadlock, Message: "Deadlock found"}
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WillReturnError(deadlock)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	attempts := 0
+	err := gormx.WithTx(context.Background(), mock.DB, func(tx *gorm.DB) error {
+		attempts++
+		return tx.Create(&User{Name: "a"}).Error
+	}, gormx.TxBackoff(time.Millisecond))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Nil(t, mock.ExpectationsWereMet())
+
+	assert.True(t, gormx.IsRetryable(deadlock))
+	assert.False(t, gormx.IsRetryable(&mysql.MySQLError{Number: 1062}))
+}
+
+func TestAfterCommit_NoTx(t *testing.T) {
+	ran := false
+	gormx.AfterCommit(context.Background(), func() { ran = true })
+	assert.True(t, ran)
+}
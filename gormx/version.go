@@ -0,0 +1,92 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VersionField is the name of the optimistic lock version field, or column, of models.
+var VersionField = "Version"
+
+// ErrStaleObject is matched by errors.Is for every StaleObjectError.
+var ErrStaleObject = errors.New("stale object")
+
+// StaleObjectError is returned by UpdateVersioned when the row has been modified, or deleted,
+// since it was read, i.e. its version no longer matches.
+type StaleObjectError struct {
+	Table   string
+	Key     any
+	Version int64
+}
+
+func (e *StaleObjectError) Error() string {
+	return fmt.Sprintf("stale object: %s %v with version %d has been modified or deleted", e.Table, e.Key, e.Version)
+}
+
+func (e *StaleObjectError) Is(target error) bool {
+	return target == ErrStaleObject
+}
+
+// UpdateVersioned updates e by its primary key with optimistic locking. The update only applies
+// if the version column still equals the version of e, and it increments the version. If no row
+// is affected, a *StaleObjectError is returned and the version of e is left unchanged.
+//
+// If fields are given, only these fields are updated, otherwise the non-zero fields of e.
+//
+//	type Account struct {
+//		ID      uint64
+//		Balance int64
+//		Version int64
+//	}
+//
+//	acc.Balance -= 10
+//	_, err := gormx.UpdateVersioned(ctx, db, acc, "Balance")
+//	if errors.Is(err, gormx.ErrStaleObject) {
+//		// reload and retry
+//	}
+func UpdateVersioned[T any](ctx context.Context, db *gorm.DB, e *T, fields ...string) (*T, error) {
+	tx := DB(ctx, db).Model(e)
+	if err := tx.Statement.Parse(e); err != nil {
+		return e, err
+	}
+	sch := tx.Statement.Schema
+	vf := sch.LookUpField(VersionField)
+	if vf == nil {
+		return e, fmt.Errorf("model %s has no version field %s", sch.Name, VersionField)
+	}
+	pk := sch.PrioritizedPrimaryField
+	if pk == nil {
+		return e, fmt.Errorf("model %s has no primary key", sch.Name)
+	}
+
+	rv := reflect.ValueOf(e).Elem()
+	key, zero := pk.ValueOf(ctx, rv)
+	if zero {
+		return e, fmt.Errorf("primary key of %s is required", sch.Name)
+	}
+	fv := vf.ReflectValueOf(ctx, rv)
+	if !fv.CanInt() {
+		return e, fmt.Errorf("version field of %s must be an integer", sch.Name)
+	}
+	current := fv.Int()
+
+	fv.SetInt(current + 1)
+	tx = tx.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: vf.DBName}, Value: current})
+	if len(fields) > 0 {
+		tx = tx.Select(append(fields, vf.Name))
+	}
+	res := tx.Updates(e)
+	if res.Error == nil && res.RowsAffected < 1 {
+		res.Error = &StaleObjectError{Table: sch.Table, Key: key, Version: current}
+	}
+	if res.Error != nil {
+		fv.SetInt(current)
+		return e, res.Error
+	}
+	return e, nil
+}
@@ -0,0 +1,98 @@
+package gormx_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chain-products-org/goal/gormx"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type Account struct {
+	ID      uint64
+	Balance int64
+	Version int64
+}
+
+type Post struct {
+	ID        uint64
+	Title     string
+	DeletedAt gorm.DeletedAt
+}
+
+func TestUpdateVersioned(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+	ctx := context.Background()
+	update := regexp.QuoteMeta("UPDATE `accounts` SET `balance`=?,`version`=? WHERE `accounts`.`version` = ? AND `id` = ?")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WithArgs(90, 2, 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	acc := &Account{ID: 1, Balance: 90, Version: 1}
+	_, err := gormx.UpdateVersioned(ctx, mock.DB, acc, "Balance")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), acc.Version)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WithArgs(80, 3, 2, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	acc.Balance = 80
+	_, err = gormx.UpdateVersioned(ctx, mock.DB, acc, "Balance")
+	assert.ErrorIs(t, err, gormx.ErrStaleObject)
+	var stale *gormx.StaleObjectError
+	assert.True(t, errors.As(err, &stale))
+	assert.Equal(t, int64(2), stale.Version)
+	assert.Equal(t, int64(2), acc.Version, "version must be restored")
+	assert.Nil(t, mock.ExpectationsWereMet())
+
+	_, err = gormx.UpdateVersioned(ctx, mock.DB, &User{ID: 1})
+	assert.NotNil(t, err)
+}
+
+func TestSoftDelete(t *testing.T) {
+	mock := testx.NewSqlmock().Gorm()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `posts` SET `deleted_at`=? WHERE `posts`.`id` = ? AND `posts`.`deleted_at` IS NULL")).
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	ok, err := gormx.DeleteResult[Post](gormx.SoftDelete(mock.DB, &Post{}, 1))
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, gormx.DeleteError)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `posts` SET `deleted_at`=? WHERE `posts`.`id` = ? AND `posts`.`deleted_at` IS NOT NULL")).
+		WithArgs(nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.Nil(t, gormx.Restore(mock.DB, &Post{}, 1).Error)
+
+	// the primary key of the model is a condition
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `posts` SET `deleted_at`=? WHERE `posts`.`deleted_at` IS NULL AND `id` = ?")).
+		WithArgs(sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.Nil(t, gormx.SoftDelete(mock.DB, &Post{ID: 2}).Error)
+
+	// the whole table is not updated
+	assert.ErrorIs(t, gormx.SoftDelete(mock.DB, &Post{}).Error, gorm.ErrMissingWhereClause)
+	assert.ErrorIs(t, gormx.Restore(mock.DB, &Post{}).Error, gorm.ErrMissingWhereClause)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `posts` WHERE `posts`.`deleted_at` IS NOT NULL")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(1, "a"))
+	var posts []Post
+	assert.Nil(t, mock.DB.Scopes(gormx.OnlyDeleted).Find(&posts).Error)
+	assert.Equal(t, 1, len(posts))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `posts`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}))
+	assert.Nil(t, mock.DB.Scopes(gormx.WithDeleted).Find(&posts).Error)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
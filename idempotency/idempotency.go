@@ -0,0 +1,142 @@
+// Package idempotency makes retried requests execute once, by claiming their idempotency key
+// in redis and storing the response for replay.
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/redisx"
+	"github.com/chain-products-org/goal/stringx"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	InFlightError = errorx.NewPreferredCodeErrf(http.StatusConflict, "a request with the same idempotency key is in flight")
+	MismatchError = errorx.NewPreferredCodeErrf(http.StatusUnprocessableEntity, "the idempotency key is used by a different request")
+	ExpiredError  = errors.New("idempotency key claim expired")
+)
+
+var (
+	// Claim the key if absent, otherwise return the record, KEYS: key; ARGV: record, ttl ms
+	claimScript = redis.NewScript(`local v = redis.call("GET", KEYS[1])
+if v then
+    return v
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return false`)
+
+	// Set the record if the key is still claimed by the token, KEYS: key; ARGV: token, record, ttl ms
+	completeScript = redis.NewScript(`local v = redis.call("GET", KEYS[1])
+if not v or cjson.decode(v)["token"] ~= ARGV[1] then
+    return 0
+end
+if ARGV[2] == "" then
+    redis.call("DEL", KEYS[1])
+else
+    redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+end
+return 1`)
+)
+
+// Response is a stored response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+type record struct {
+	Token       string    `json:"token,omitempty"`
+	Fingerprint string    `json:"fingerprint"`
+	Response    *Response `json:"response,omitempty"`
+}
+
+// Store stores the claims and responses of idempotency keys.
+type Store struct {
+	rc     redisx.Client
+	prefix string
+	// LockTTL is the max time a request may hold its key, after which a retry may execute again,
+	// 1 minute by default.
+	LockTTL time.Duration
+	// TTL is the time the response is kept for replay, 24 hours by default.
+	TTL time.Duration
+}
+
+// NewStore returns a Store whose keys are prefixed by prefix.
+func NewStore(rc redisx.Client, prefix string) *Store {
+	return &Store{rc: rc, prefix: prefix, LockTTL: time.Minute, TTL: 24 * time.Hour}
+}
+
+// Claim is the claim of an idempotency key by a request, which must either Complete or Release
+// it.
+type Claim struct {
+	store *Store
+	key   string
+	token string
+	fp    string
+}
+
+// Claim atomically claims key for the request identified by fingerprint, e.g. a hash of its
+// method, path and body.
+//
+// If the key is new, the claim is returned and the request should execute. If a request with
+// the key completed, its response is returned for replay. If it is still in flight,
+// InFlightError is returned, and MismatchError if the key was used by a different request.
+func (s *Store) Claim(ctx context.Context, key, fingerprint string) (*Claim, *Response, error) {
+	c := &Claim{store: s, key: s.prefix + key, token: stringx.Randn(16), fp: fingerprint}
+	bs, err := json.Marshal(record{Token: c.token, Fingerprint: fingerprint})
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err := claimScript.Run(ctx, s.rc, []string{c.key}, bs, s.LockTTL.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return c, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var r record
+	if err = json.Unmarshal([]byte(v), &r); err != nil {
+		return nil, nil, fmt.Errorf("decode idempotency record %s: %w", c.key, err)
+	}
+	if r.Fingerprint != fingerprint {
+		return nil, nil, MismatchError
+	}
+	if r.Response == nil {
+		return nil, nil, InFlightError
+	}
+	return nil, r.Response, nil
+}
+
+// Complete stores the response of the request for replay, it fails with ExpiredError if the
+// request held the key for longer than LockTTL.
+func (c *Claim) Complete(ctx context.Context, resp *Response) error {
+	bs, err := json.Marshal(record{Fingerprint: c.fp, Response: resp})
+	if err != nil {
+		return err
+	}
+	return c.finish(ctx, string(bs))
+}
+
+// Release removes the claim without storing a response, e.g. if the request failed with a
+// server error, so that a retry executes again.
+func (c *Claim) Release(ctx context.Context) error {
+	return c.finish(ctx, "")
+}
+
+func (c *Claim) finish(ctx context.Context, rec string) error {
+	ok, err := completeScript.Run(ctx, c.store.rc, []string{c.key}, c.token, rec, c.store.TTL.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ExpiredError
+	}
+	return nil
+}
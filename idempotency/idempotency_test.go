@@ -0,0 +1,43 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/chain-products-org/goal/idempotency"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s := idempotency.NewStore(testx.NewRedisCluster(), "idem:")
+
+	c, replay, err := s.Claim(ctx, "k1", "fp1")
+	assert.Nil(t, err)
+	assert.Nil(t, replay)
+	assert.NotNil(t, c)
+
+	_, _, err = s.Claim(ctx, "k1", "fp1")
+	assert.ErrorIs(t, err, idempotency.InFlightError)
+	_, _, err = s.Claim(ctx, "k1", "fp2")
+	assert.ErrorIs(t, err, idempotency.MismatchError)
+
+	resp := &idempotency.Response{Status: http.StatusCreated, Header: http.Header{"X-Id": {"1"}}, Body: []byte("ok")}
+	assert.Nil(t, c.Complete(ctx, resp))
+	assert.ErrorIs(t, c.Complete(ctx, resp), idempotency.ExpiredError)
+
+	c2, replay, err := s.Claim(ctx, "k1", "fp1")
+	assert.Nil(t, err)
+	assert.Nil(t, c2)
+	assert.Equal(t, resp, replay)
+
+	// released keys can be claimed again
+	c, _, err = s.Claim(ctx, "k2", "fp1")
+	assert.Nil(t, err)
+	assert.Nil(t, c.Release(ctx))
+	c, _, err = s.Claim(ctx, "k2", "fp1")
+	assert.Nil(t, err)
+	assert.NotNil(t, c)
+}
@@ -0,0 +1,385 @@
+package jwtx
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/chain-products-org/goal/ciphers/base64x"
+)
+
+// Key types defined in RFC 7518 section 6.1 and RFC 8037.
+const (
+	KtyOct = "oct"
+	KtyRSA = "RSA"
+	KtyEC  = "EC"
+	KtyOKP = "OKP"
+)
+
+// Key is a signing or verification key bound to a key id and an algorithm. It marshals to and
+// from the JSON Web Key representation, see https://www.rfc-editor.org/rfc/rfc7517.
+//
+// The underlying material must be one of:
+//
+//   - []byte for HS256
+//   - *rsa.PrivateKey or *rsa.PublicKey for RS256
+//   - *ecdsa.PrivateKey or *ecdsa.PublicKey on P-256 for ES256
+//   - ed25519.PrivateKey or ed25519.PublicKey for EdDSA
+type Key struct {
+	ID  string
+	Alg string
+	Use string
+
+	material any
+}
+
+// NewKey creates a Key with the given id, algorithm and key material. If kid is empty, the RFC 7638
+// thumbprint of the key is used as its id.
+func NewKey(kid string, alg string, material any) (*Key, error) {
+	k := &Key{ID: kid, Alg: alg, Use: "sig", material: material}
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	if k.ID == "" {
+		tp, err := k.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		k.ID = base64x.RawURLEncoding.Encode(tp)
+	}
+	return k, nil
+}
+
+// MustNewKey is like NewKey but panics if the key is invalid.
+func MustNewKey(kid string, alg string, material any) *Key {
+	k, err := NewKey(kid, alg, material)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// Material returns the raw key material.
+func (k *Key) Material() any {
+	return k.material
+}
+
+// IsPrivate reports whether the key can be used for signing.
+func (k *Key) IsPrivate() bool {
+	switch k.material.(type) {
+	case []byte, *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return true
+	}
+	return false
+}
+
+// Public returns a copy of the key with the private part removed. Symmetric keys have no public
+// part, so nil is returned for them.
+func (k *Key) Public() *Key {
+	var pub any
+	switch m := k.material.(type) {
+	case []byte:
+		return nil
+	case *rsa.PrivateKey:
+		pub = &m.PublicKey
+	case *ecdsa.PrivateKey:
+		pub = &m.PublicKey
+	case ed25519.PrivateKey:
+		pub = m.Public()
+	default:
+		pub = m
+	}
+	return &Key{ID: k.ID, Alg: k.Alg, Use: k.Use, material: pub}
+}
+
+func (k *Key) check() error {
+	switch k.Alg {
+	case HS256:
+		if m, ok := k.material.([]byte); !ok || len(m) == 0 {
+			return fmt.Errorf("%w: %s requires a non-empty []byte secret", ErrInvalidKey, k.Alg)
+		}
+	case RS256:
+		switch k.material.(type) {
+		case *rsa.PrivateKey, *rsa.PublicKey:
+		default:
+			return fmt.Errorf("%w: %s requires an RSA key", ErrInvalidKey, k.Alg)
+		}
+	case ES256:
+		var curve elliptic.Curve
+		switch m := k.material.(type) {
+		case *ecdsa.PrivateKey:
+			curve = m.Curve
+		case *ecdsa.PublicKey:
+			curve = m.Curve
+		default:
+			return fmt.Errorf("%w: %s requires an ECDSA key", ErrInvalidKey, k.Alg)
+		}
+		if curve != elliptic.P256() {
+			return fmt.Errorf("%w: %s requires curve P-256", ErrInvalidKey, k.Alg)
+		}
+	case EdDSA:
+		switch k.material.(type) {
+		case ed25519.PrivateKey, ed25519.PublicKey:
+		default:
+			return fmt.Errorf("%w: %s requires an Ed25519 key", ErrInvalidKey, k.Alg)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlg, k.Alg)
+	}
+	return nil
+}
+
+// jwk is the JSON representation of a Key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	K   string `json:"k,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	D   string `json:"d,omitempty"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+}
+
+func (k *Key) MarshalJSON() ([]byte, error) {
+	j := jwk{Kid: k.ID, Alg: k.Alg, Use: k.Use}
+	switch m := k.material.(type) {
+	case []byte:
+		j.Kty = KtyOct
+		j.K = base64x.RawURLEncoding.Encode(m)
+	case *rsa.PrivateKey:
+		j.Kty = KtyRSA
+		j.N = base64x.Base64UrlUint.Encode(m.N)
+		j.E = base64x.Base64UrlUint.Encode(big.NewInt(int64(m.E)))
+		j.D = base64x.Base64UrlUint.Encode(m.D)
+		if len(m.Primes) == 2 {
+			j.P = base64x.Base64UrlUint.Encode(m.Primes[0])
+			j.Q = base64x.Base64UrlUint.Encode(m.Primes[1])
+		}
+	case *rsa.PublicKey:
+		j.Kty = KtyRSA
+		j.N = base64x.Base64UrlUint.Encode(m.N)
+		j.E = base64x.Base64UrlUint.Encode(big.NewInt(int64(m.E)))
+	case *ecdsa.PrivateKey:
+		j.Kty = KtyEC
+		j.Crv = m.Curve.Params().Name
+		j.X = base64x.RawURLEncoding.Encode(fixedBytes(m.X, 32))
+		j.Y = base64x.RawURLEncoding.Encode(fixedBytes(m.Y, 32))
+		j.D = base64x.RawURLEncoding.Encode(fixedBytes(m.D, 32))
+	case *ecdsa.PublicKey:
+		j.Kty = KtyEC
+		j.Crv = m.Curve.Params().Name
+		j.X = base64x.RawURLEncoding.Encode(fixedBytes(m.X, 32))
+		j.Y = base64x.RawURLEncoding.Encode(fixedBytes(m.Y, 32))
+	case ed25519.PrivateKey:
+		j.Kty = KtyOKP
+		j.Crv = "Ed25519"
+		j.X = base64x.RawURLEncoding.Encode(m.Public().(ed25519.PublicKey))
+		j.D = base64x.RawURLEncoding.Encode(m.Seed())
+	case ed25519.PublicKey:
+		j.Kty = KtyOKP
+		j.Crv = "Ed25519"
+		j.X = base64x.RawURLEncoding.Encode(m)
+	default:
+		return nil, fmt.Errorf("%w: unsupported key material %T", ErrInvalidKey, m)
+	}
+	return json.Marshal(j)
+}
+
+func (k *Key) UnmarshalJSON(b []byte) error {
+	var j jwk
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	var (
+		material any
+		err      error
+	)
+	switch j.Kty {
+	case KtyOct:
+		material, err = base64x.RawURLEncoding.Decode(j.K, true)
+	case KtyRSA:
+		material, err = j.rsaKey()
+	case KtyEC:
+		material, err = j.ecKey()
+	case KtyOKP:
+		material, err = j.okpKey()
+	default:
+		err = fmt.Errorf("unsupported kty %q", j.Kty)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	*k = Key{ID: j.Kid, Alg: j.Alg, Use: j.Use, material: material}
+	if k.Alg == "" {
+		k.Alg = defaultAlg(material)
+	}
+	return k.check()
+}
+
+func (j *jwk) rsaKey() (any, error) {
+	n, err := base64x.Base64UrlUint.Decode(j.N)
+	if err != nil || n == nil {
+		return nil, errors.New("invalid RSA modulus")
+	}
+	e, err := base64x.Base64UrlUint.Decode(j.E)
+	if err != nil || e == nil || !e.IsInt64() {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	pub := rsa.PublicKey{N: n, E: int(e.Int64())}
+	if j.D == "" {
+		return &pub, nil
+	}
+	d, err := base64x.Base64UrlUint.Decode(j.D)
+	if err != nil {
+		return nil, errors.New("invalid RSA private exponent")
+	}
+	p, err1 := base64x.Base64UrlUint.Decode(j.P)
+	q, err2 := base64x.Base64UrlUint.Decode(j.Q)
+	if err1 != nil || err2 != nil || p == nil || q == nil {
+		return nil, errors.New("RSA private key requires primes p and q")
+	}
+	priv := &rsa.PrivateKey{PublicKey: pub, D: d, Primes: []*big.Int{p, q}}
+	if err := priv.Validate(); err != nil {
+		return nil, err
+	}
+	priv.Precompute()
+	return priv, nil
+}
+
+func (j *jwk) ecKey() (any, error) {
+	if j.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+	}
+	x, err1 := base64x.RawURLEncoding.Decode(j.X, true)
+	y, err2 := base64x.RawURLEncoding.Decode(j.Y, true)
+	if err1 != nil || err2 != nil || len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("invalid EC coordinates")
+	}
+	pub := ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, errors.New("EC point is not on curve")
+	}
+	if j.D == "" {
+		return &pub, nil
+	}
+	d, err := base64x.RawURLEncoding.Decode(j.D, true)
+	if err != nil || len(d) != 32 {
+		return nil, errors.New("invalid EC private key")
+	}
+	return &ecdsa.PrivateKey{PublicKey: pub, D: new(big.Int).SetBytes(d)}, nil
+}
+
+func (j *jwk) okpKey() (any, error) {
+	if j.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+	}
+	if j.D != "" {
+		seed, err := base64x.RawURLEncoding.Decode(j.D, true)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("invalid Ed25519 private key")
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	x, err := base64x.RawURLEncoding.Decode(j.X, true)
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 public key")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+// Thumbprint computes the RFC 7638 SHA-256 thumbprint of the key.
+func (k *Key) Thumbprint() ([]byte, error) {
+	pub := k.Public()
+	if pub == nil {
+		pub = k
+	}
+	bs, err := pub.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var j jwk
+	if err := json.Unmarshal(bs, &j); err != nil {
+		return nil, err
+	}
+	// required members only, in lexicographic order
+	var s string
+	switch j.Kty {
+	case KtyOct:
+		s = fmt.Sprintf(`{"k":%q,"kty":%q}`, j.K, j.Kty)
+	case KtyRSA:
+		s = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, j.E, j.Kty, j.N)
+	case KtyEC:
+		s = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, j.Crv, j.Kty, j.X, j.Y)
+	case KtyOKP:
+		s = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, j.Crv, j.Kty, j.X)
+	}
+	sum := sha256.Sum256([]byte(s))
+	return sum[:], nil
+}
+
+// KeySet is a JSON Web Key Set document, see https://www.rfc-editor.org/rfc/rfc7517#section-5.
+type KeySet struct {
+	Keys []*Key `json:"keys"`
+}
+
+// UnmarshalJSON decodes the keys of the set leniently: the keys which are invalid, or of an
+// unsupported type or algorithm, are skipped, as issuers publish keys of other kinds along with
+// the ones used to sign. A Key is decoded strictly on its own.
+func (s *KeySet) UnmarshalJSON(b []byte) error {
+	var doc struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	s.Keys = make([]*Key, 0, len(doc.Keys))
+	for _, raw := range doc.Keys {
+		k := new(Key)
+		if err := json.Unmarshal(raw, k); err != nil {
+			continue
+		}
+		s.Keys = append(s.Keys, k)
+	}
+	return nil
+}
+
+// Lookup finds the key with the given id.
+func (s *KeySet) Lookup(kid string) (*Key, bool) {
+	for _, k := range s.Keys {
+		if k.ID == kid {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+func defaultAlg(material any) string {
+	switch material.(type) {
+	case []byte:
+		return HS256
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return RS256
+	case *ecdsa.PrivateKey, *ecdsa.PublicKey:
+		return ES256
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return EdDSA
+	}
+	return ""
+}
+
+func fixedBytes(i *big.Int, size int) []byte {
+	b := make([]byte, size)
+	return i.FillBytes(b)
+}
@@ -0,0 +1,158 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/chain-products-org/goal/ciphers/base64x"
+)
+
+// Supported JWS algorithms, see https://www.rfc-editor.org/rfc/rfc7518#section-3.1 and
+// https://www.rfc-editor.org/rfc/rfc8037#section-3.1.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+// Header is the protected header of a JWS.
+type Header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// JWS is a parsed, but not yet verified, JWS in compact serialization.
+type JWS struct {
+	Header    Header
+	Payload   []byte
+	Signature []byte
+
+	signingInput string
+}
+
+// Sign signs payload with key and returns the compact serialization of the JWS.
+func Sign(payload []byte, key *Key) (string, error) {
+	if !key.IsPrivate() {
+		return "", fmt.Errorf("%w: key %s can not sign", ErrInvalidKey, key.ID)
+	}
+	hb, err := json.Marshal(Header{Alg: key.Alg, Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	input := base64x.RawURLEncoding.Encode(hb) + "." + base64x.RawURLEncoding.Encode(payload)
+	sig, err := sign(key, []byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + base64x.RawURLEncoding.Encode(sig), nil
+}
+
+// ParseJWS splits a compact serialized JWS into its parts without verifying the signature.
+func ParseJWS(token string) (*JWS, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	hb, err := base64x.RawURLEncoding.Decode(parts[0], true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	var h Header
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	payload, err := base64x.RawURLEncoding.Decode(parts[1], true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrMalformed, err)
+	}
+	sig, err := base64x.RawURLEncoding.Decode(parts[2], true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrMalformed, err)
+	}
+	return &JWS{Header: h, Payload: payload, Signature: sig, signingInput: parts[0] + "." + parts[1]}, nil
+}
+
+// Verify checks the signature with the given key. The algorithm of the key must match the one
+// in the header, which prevents algorithm confusion attacks such as "none" or HS256 signed with
+// an RSA public key.
+func (j *JWS) Verify(key *Key) error {
+	if key.Alg != j.Header.Alg {
+		return fmt.Errorf("%w: header alg %q does not match key alg %q", ErrInvalidSignature, j.Header.Alg, key.Alg)
+	}
+	return verify(key, []byte(j.signingInput), j.Signature)
+}
+
+func sign(key *Key, input []byte) ([]byte, error) {
+	switch m := key.material.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, m)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256(input)
+		return rsa.SignPKCS1v15(rand.Reader, m, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(input)
+		r, s, err := ecdsa.Sign(rand.Reader, m, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses the fixed length R || S form instead of ASN.1
+		return append(fixedBytes(r, 32), fixedBytes(s, 32)...), nil
+	case ed25519.PrivateKey:
+		return ed25519.Sign(m, input), nil
+	}
+	return nil, fmt.Errorf("%w: key %s can not sign", ErrInvalidKey, key.ID)
+}
+
+func verify(key *Key, input []byte, sig []byte) error {
+	var ok bool
+	switch m := key.material.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, m)
+		mac.Write(input)
+		ok = hmac.Equal(sig, mac.Sum(nil))
+	case *rsa.PrivateKey:
+		ok = verifyRSA(&m.PublicKey, input, sig)
+	case *rsa.PublicKey:
+		ok = verifyRSA(m, input, sig)
+	case *ecdsa.PrivateKey:
+		ok = verifyEC(&m.PublicKey, input, sig)
+	case *ecdsa.PublicKey:
+		ok = verifyEC(m, input, sig)
+	case ed25519.PrivateKey:
+		ok = ed25519.Verify(m.Public().(ed25519.PublicKey), input, sig)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(m, input, sig)
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func verifyRSA(pub *rsa.PublicKey, input []byte, sig []byte) bool {
+	digest := sha256.Sum256(input)
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+}
+
+func verifyEC(pub *ecdsa.PublicKey, input []byte, sig []byte) bool {
+	if len(sig) != 64 {
+		return false
+	}
+	digest := sha256.Sum256(input)
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	return ecdsa.Verify(pub, digest[:], r, s)
+}
@@ -0,0 +1,169 @@
+package jwtx
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrMalformed        = errors.New("jwt: malformed token")
+	ErrUnsupportedAlg   = errors.New("jwt: unsupported algorithm")
+	ErrInvalidKey       = errors.New("jwt: invalid key")
+	ErrKeyNotFound      = errors.New("jwt: key not found")
+	ErrInvalidSignature = errors.New("jwt: invalid signature")
+	ErrExpired          = errors.New("jwt: token is expired")
+	ErrNotValidYet      = errors.New("jwt: token is not valid yet")
+	ErrInvalidIssuer    = errors.New("jwt: invalid issuer")
+	ErrInvalidAudience  = errors.New("jwt: invalid audience")
+)
+
+// NumericDate is a JSON numeric date value, the number of seconds since the epoch.
+type NumericDate int64
+
+// NewNumericDate converts t to a NumericDate.
+func NewNumericDate(t time.Time) NumericDate {
+	return NumericDate(t.Unix())
+}
+
+// Time converts the NumericDate to time.Time.
+func (d NumericDate) Time() time.Time {
+	return time.Unix(int64(d), 0)
+}
+
+// Audience is the "aud" claim which can be either a single string or an array of strings.
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	*a = ss
+	return nil
+}
+
+// Contains reports whether aud is one of the audiences.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims holds the registered claims of a JWT, and any other claims in Extra.
+type Claims struct {
+	Issuer    string      `json:"iss,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Audience  Audience    `json:"aud,omitempty"`
+	ExpiresAt NumericDate `json:"exp,omitempty"`
+	NotBefore NumericDate `json:"nbf,omitempty"`
+	IssuedAt  NumericDate `json:"iat,omitempty"`
+	ID        string      `json:"jti,omitempty"`
+
+	// Extra holds private claims, they are flattened into the payload.
+	Extra map[string]any `json:"-"`
+
+	raw []byte
+}
+
+type registeredClaims Claims
+
+var registeredNames = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti"}
+
+func (c Claims) MarshalJSON() ([]byte, error) {
+	bs, err := json.Marshal(registeredClaims(c))
+	if err != nil || len(c.Extra) == 0 {
+		return bs, err
+	}
+	m := make(map[string]any, len(c.Extra)+len(registeredNames))
+	for k, v := range c.Extra {
+		m[k] = v
+	}
+	// registered claims take precedence over extra ones with the same name
+	if err := json.Unmarshal(bs, &m); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func (c *Claims) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*registeredClaims)(c)); err != nil {
+		return err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	for _, name := range registeredNames {
+		delete(m, name)
+	}
+	if len(m) > 0 {
+		c.Extra = m
+	}
+	c.raw = append([]byte(nil), b...)
+	return nil
+}
+
+// Get returns the private claim with the given name.
+func (c *Claims) Get(name string) (any, bool) {
+	v, ok := c.Extra[name]
+	return v, ok
+}
+
+// Set sets a private claim.
+func (c *Claims) Set(name string, v any) *Claims {
+	if c.Extra == nil {
+		c.Extra = make(map[string]any)
+	}
+	c.Extra[name] = v
+	return c
+}
+
+// Decode unmarshals the raw payload of a verified token into v, which is useful for application
+// defined claims structs.
+func (c *Claims) Decode(v any) error {
+	if c.raw == nil {
+		bs, err := c.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(bs, v)
+	}
+	return json.Unmarshal(c.raw, v)
+}
+
+// Validate checks the time based claims at now with the given leeway, and the issuer and
+// audience if they are not empty.
+func (c *Claims) Validate(now time.Time, leeway time.Duration, issuer string, audience string) error {
+	if c.ExpiresAt != 0 && !now.Add(-leeway).Before(c.ExpiresAt.Time()) {
+		return ErrExpired
+	}
+	if c.NotBefore != 0 && now.Add(leeway).Before(c.NotBefore.Time()) {
+		return ErrNotValidYet
+	}
+	if c.IssuedAt != 0 && now.Add(leeway).Before(c.IssuedAt.Time()) {
+		return ErrNotValidYet
+	}
+	if issuer != "" && c.Issuer != issuer {
+		return ErrInvalidIssuer
+	}
+	if audience != "" && !c.Audience.Contains(audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
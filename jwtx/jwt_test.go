@@ -0,0 +1,264 @@
+package jwtx_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/ciphers/base64x"
+	"github.com/chain-products-org/goal/jwtx"
+	"github.com/stretchr/testify/assert"
+)
+
+func testKeys(t *testing.T) []*jwtx.Key {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	return []*jwtx.Key{
+		jwtx.MustNewKey("hs", jwtx.HS256, []byte("a-very-secret-key")),
+		jwtx.MustNewKey("rs", jwtx.RS256, rsaKey),
+		jwtx.MustNewKey("es", jwtx.ES256, ecKey),
+		jwtx.MustNewKey("ed", jwtx.EdDSA, edKey),
+	}
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	for _, key := range testKeys(t) {
+		t.Run(key.Alg, func(t *testing.T) {
+			s := jwtx.NewService(jwtx.NewKeyRing(key, 0), jwtx.WithIssuer("goal"), jwtx.WithAudience("api"))
+			claims := jwtx.Claims{Subject: "1001"}
+			claims.Set("role", "admin")
+			token, err := s.Issue(claims)
+			assert.Nil(t, err)
+
+			got, err := s.Verify(context.Background(), token)
+			assert.Nil(t, err)
+			assert.Equal(t, "1001", got.Subject)
+			assert.Equal(t, "goal", got.Issuer)
+			assert.True(t, got.Audience.Contains("api"))
+			role, _ := got.Get("role")
+			assert.Equal(t, "admin", role)
+
+			var custom struct {
+				Role string `json:"role"`
+			}
+			assert.Nil(t, got.Decode(&custom))
+			assert.Equal(t, "admin", custom.Role)
+
+			// tampered signature
+			_, err = s.Verify(context.Background(), token[:len(token)-2]+"AA")
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	key := jwtx.MustNewKey("hs", jwtx.HS256, []byte("secret"))
+	now := time.Now()
+	issuer := jwtx.NewService(jwtx.NewKeyRing(key, 0), jwtx.WithTTL(time.Minute), jwtx.WithIssuer("goal"))
+	token, err := issuer.Issue(jwtx.Claims{Subject: "u"})
+	assert.Nil(t, err)
+
+	later := jwtx.NewService(jwtx.NewKeyRing(key, 0), jwtx.WithClock(func() time.Time { return now.Add(2 * time.Minute) }))
+	_, err = later.Verify(context.Background(), token)
+	assert.True(t, errors.Is(err, jwtx.ErrExpired))
+
+	other := jwtx.NewService(jwtx.NewKeyRing(key, 0), jwtx.WithIssuer("other"))
+	_, err = other.Verify(context.Background(), token)
+	assert.True(t, errors.Is(err, jwtx.ErrInvalidIssuer))
+
+	aud := jwtx.NewService(jwtx.NewKeyRing(key, 0), jwtx.WithAudience("api"))
+	_, err = aud.Verify(context.Background(), token)
+	assert.True(t, errors.Is(err, jwtx.ErrInvalidAudience))
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	keys := testKeys(t)
+	rsKey := keys[1]
+	// an HS256 token signed with the RSA public key as secret must be rejected
+	pub, err := json.Marshal(rsKey.Public())
+	assert.Nil(t, err)
+	forged, err := jwtx.Sign([]byte(`{"sub":"attacker"}`), jwtx.MustNewKey("rs", jwtx.HS256, pub))
+	assert.Nil(t, err)
+
+	s := jwtx.NewService(jwtx.NewKeyRing(rsKey, 0))
+	_, err = s.Verify(context.Background(), forged)
+	assert.True(t, errors.Is(err, jwtx.ErrInvalidSignature))
+
+	_, err = s.Verify(context.Background(), "eyJhbGciOiJub25lIn0.eyJzdWIiOiJ4In0.")
+	assert.True(t, errors.Is(err, jwtx.ErrUnsupportedAlg))
+}
+
+func TestKeyRotation(t *testing.T) {
+	keys := testKeys(t)
+	ring := jwtx.NewKeyRing(keys[1], 2)
+	s := jwtx.NewService(ring)
+	old, err := s.Issue(jwtx.Claims{Subject: "u"})
+	assert.Nil(t, err)
+
+	ring.Rotate(keys[2])
+	assert.Equal(t, "es", ring.Active().ID)
+	_, err = s.Verify(context.Background(), old)
+	assert.Nil(t, err, "tokens signed by the previous key are still valid")
+
+	ring.Rotate(keys[3])
+	_, err = s.Verify(context.Background(), old)
+	assert.True(t, errors.Is(err, jwtx.ErrKeyNotFound), "the oldest key is pushed out")
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	keys := testKeys(t)
+	ring := jwtx.NewKeyRing(keys[1], 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(ring.JWKS())
+	}))
+	defer srv.Close()
+
+	remote := jwtx.NewRemoteKeySet(srv.URL)
+	remote.MinRefreshInterval = time.Nanosecond
+	issuer := jwtx.NewService(ring)
+	verifier := jwtx.NewService(remote)
+
+	token, err := issuer.Issue(jwtx.Claims{Subject: "u"})
+	assert.Nil(t, err)
+	_, err = verifier.Verify(context.Background(), token)
+	assert.Nil(t, err)
+
+	// a rotated key is fetched on demand
+	ring.Rotate(keys[3])
+	token, err = issuer.Issue(jwtx.Claims{Subject: "u"})
+	assert.Nil(t, err)
+	_, err = verifier.Verify(context.Background(), token)
+	assert.Nil(t, err)
+}
+
+func TestRemoteKeySet_Lenient(t *testing.T) {
+	keys := testKeys(t)
+	pub, err := json.Marshal(keys[3].Public())
+	assert.Nil(t, err)
+	unknown := []byte(`{"kty":"OKP","crv":"X25519","kid":"x","x":"hSDwCYkwp1R0i33ctD73Wg2_Og0mOBr066SpjqqbTmo"}`)
+	unsupported := []byte(`{"kty":"oct","kid":"p","alg":"HS512","k":"c2VjcmV0"}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[` + string(unknown) + `,` + string(unsupported) + `,` + string(pub) + `]}`))
+	}))
+	defer srv.Close()
+
+	// a key is decoded strictly on its own
+	var k jwtx.Key
+	assert.NotNil(t, json.Unmarshal(unknown, &k))
+	assert.NotNil(t, json.Unmarshal(unsupported, &k))
+
+	token, err := jwtx.NewService(jwtx.NewKeyRing(keys[3], 0)).Issue(jwtx.Claims{Subject: "u"})
+	assert.Nil(t, err)
+	_, err = jwtx.NewService(jwtx.NewRemoteKeySet(srv.URL)).Verify(context.Background(), token)
+	assert.Nil(t, err, "the unsupported keys are skipped")
+}
+
+func TestRemoteKeySet_RefreshFailed(t *testing.T) {
+	keys := testKeys(t)
+	ring := jwtx.NewKeyRing(keys[1], 0)
+	var (
+		fetches atomic.Int32
+		fail    atomic.Bool
+	)
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.URL.Query().Get("slow") != "" {
+			<-block
+		}
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(ring.JWKS())
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	remote := jwtx.NewRemoteKeySet(srv.URL)
+	remote.RefreshInterval = time.Nanosecond
+	remote.MinRefreshInterval = time.Hour
+	_, err := remote.Key(ctx, "rs")
+	assert.Nil(t, err)
+
+	// the cached key is used when the refresh fails, and the failed refresh is not retried
+	// before MinRefreshInterval
+	fail.Store(true)
+	remote.MinRefreshInterval = time.Nanosecond
+	_, err = remote.Key(ctx, "rs")
+	assert.Nil(t, err)
+	remote.MinRefreshInterval = time.Hour
+	for i := 0; i < 3; i++ {
+		_, err = remote.Key(ctx, "unknown")
+		assert.True(t, errors.Is(err, jwtx.ErrKeyNotFound))
+		_, err = remote.Key(ctx, "rs")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// the cached keys are looked up while a slow refresh is in flight
+	fail.Store(false)
+	remote.URL = srv.URL + "?slow=1"
+	remote.MinRefreshInterval = time.Nanosecond
+	done := make(chan error)
+	go func() {
+		_, err := remote.Key(ctx, "es")
+		done <- err
+	}()
+	assert.Eventually(t, func() bool { return fetches.Load() == 3 }, time.Second, time.Millisecond)
+	_, err = remote.Key(ctx, "rs")
+	assert.Nil(t, err)
+	ring.Rotate(keys[2])
+	close(block)
+	assert.Nil(t, <-done)
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	for _, key := range testKeys(t) {
+		bs, err := json.Marshal(key)
+		assert.Nil(t, err)
+		var parsed jwtx.Key
+		assert.Nil(t, json.Unmarshal(bs, &parsed))
+		assert.Equal(t, key.ID, parsed.ID)
+		assert.Equal(t, key.Alg, parsed.Alg)
+		assert.True(t, parsed.IsPrivate())
+
+		token, err := jwtx.Sign([]byte(`{}`), &parsed)
+		assert.Nil(t, err)
+		jws, err := jwtx.ParseJWS(token)
+		assert.Nil(t, err)
+		verifyKey := key.Public()
+		if verifyKey == nil {
+			verifyKey = key
+		}
+		assert.Nil(t, jws.Verify(verifyKey))
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	// example from RFC 7638 section 3.1
+	var key jwtx.Key
+	err := json.Unmarshal([]byte(`{"kty":"RSA","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw","e":"AQAB","alg":"RS256","kid":"2011-04-29"}`), &key)
+	assert.Nil(t, err)
+	tp, err := key.Thumbprint()
+	assert.Nil(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", base64Url(tp))
+}
+
+func base64Url(b []byte) string {
+	return base64x.RawURLEncoding.Encode(b)
+}
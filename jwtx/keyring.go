@@ -0,0 +1,231 @@
+package jwtx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeyProvider provides verification keys by key id.
+type KeyProvider interface {
+	Key(ctx context.Context, kid string) (*Key, error)
+}
+
+// KeyRing holds the active signing key and the previous keys that are still accepted for
+// verification, which makes it possible to rotate keys without invalidating issued tokens.
+type KeyRing struct {
+	mu      sync.RWMutex
+	active  *Key
+	keys    []*Key
+	maxKeys int
+}
+
+// NewKeyRing creates a KeyRing signing with active. At most maxKeys keys, including the active
+// one, are kept for verification; maxKeys <= 0 means no limit.
+func NewKeyRing(active *Key, maxKeys int) *KeyRing {
+	return &KeyRing{active: active, keys: []*Key{active}, maxKeys: maxKeys}
+}
+
+// Active returns the current signing key.
+func (r *KeyRing) Active() *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active
+}
+
+// Rotate makes next the active signing key. The previous keys remain valid for verification
+// until they are retired or pushed out by maxKeys.
+func (r *KeyRing) Rotate(next *Key) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := []*Key{next}
+	for _, k := range r.keys {
+		if k.ID != next.ID {
+			keys = append(keys, k)
+		}
+	}
+	if r.maxKeys > 0 && len(keys) > r.maxKeys {
+		keys = keys[:r.maxKeys]
+	}
+	r.active = next
+	r.keys = keys
+}
+
+// Retire removes the key with the given id from verification. The active key can not be retired.
+func (r *KeyRing) Retire(kid string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active.ID == kid {
+		return false
+	}
+	for i, k := range r.keys {
+		if k.ID == kid {
+			r.keys = append(r.keys[:i], r.keys[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Key implements KeyProvider.
+func (r *KeyRing) Key(_ context.Context, kid string) (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.ID == kid {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+}
+
+// JWKS returns the public keys of the ring as a JWKS document, ready to be served to the
+// verifying parties. Symmetric keys are never published.
+func (r *KeyRing) JWKS() *KeySet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	set := &KeySet{Keys: make([]*Key, 0, len(r.keys))}
+	for _, k := range r.keys {
+		if pub := k.Public(); pub != nil {
+			set.Keys = append(set.Keys, pub)
+		}
+	}
+	return set
+}
+
+// RemoteKeySet is a KeyProvider backed by a JWKS document served over HTTP. The document is
+// cached and refreshed periodically, and also on demand when a token refers to an unknown key
+// id, which is how the issuer's key rotation is picked up. The document is fetched once at a
+// time without blocking the lookups of the cached keys, and the cached keys are kept when a
+// refresh fails.
+type RemoteKeySet struct {
+	URL string
+	// Client is used to fetch the document, http.DefaultClient by default.
+	Client *http.Client
+	// RefreshInterval is the max age of the cached document, 1 hour by default.
+	RefreshInterval time.Duration
+	// MinRefreshInterval limits the fetches, failed or not, for unknown key ids and after
+	// failures, 1 minute by default.
+	MinRefreshInterval time.Duration
+
+	mu          sync.Mutex
+	set         *KeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	err         error         // the error of the last fetch
+	refreshing  chan struct{} // closed when the fetch in flight is done
+}
+
+// NewRemoteKeySet creates a RemoteKeySet for the given JWKS url.
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{URL: url}
+}
+
+// Key implements KeyProvider.
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (*Key, error) {
+	s.mu.Lock()
+	var (
+		cached *Key
+		found  bool
+	)
+	if s.set != nil {
+		cached, found = s.set.Lookup(kid)
+	}
+	stale := time.Since(s.fetchedAt) > s.refreshInterval()
+	due := time.Since(s.attemptedAt) > s.minRefreshInterval()
+	inFlight := s.refreshing
+	s.mu.Unlock()
+
+	switch {
+	case found && (!stale || inFlight != nil || !due):
+		// a stale key is used while another caller refreshes, or after a failed refresh
+		return cached, nil
+	case inFlight != nil:
+		select {
+		case <-inFlight:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	case due:
+		s.refresh(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.set != nil {
+		if k, ok := s.set.Lookup(kid); ok {
+			return k, nil
+		}
+	}
+	if s.err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrKeyNotFound, kid, s.err)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+}
+
+// refresh fetches the document, or waits for the fetch in flight.
+func (s *RemoteKeySet) refresh(ctx context.Context) {
+	s.mu.Lock()
+	if ch := s.refreshing; ch != nil {
+		s.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+		}
+		return
+	}
+	ch := make(chan struct{})
+	s.refreshing, s.attemptedAt = ch, time.Now()
+	s.mu.Unlock()
+
+	set, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	if err == nil {
+		s.set, s.fetchedAt = set, s.attemptedAt
+	}
+	s.err, s.refreshing = err, nil
+	s.mu.Unlock()
+	close(ch)
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) (*KeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks from %s error: %w", s.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks from %s error: %s", s.URL, resp.Status)
+	}
+	var set KeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode jwks from %s error: %w", s.URL, err)
+	}
+	return &set, nil
+}
+
+func (s *RemoteKeySet) refreshInterval() time.Duration {
+	if s.RefreshInterval > 0 {
+		return s.RefreshInterval
+	}
+	return time.Hour
+}
+
+func (s *RemoteKeySet) minRefreshInterval() time.Duration {
+	if s.MinRefreshInterval > 0 {
+		return s.MinRefreshInterval
+	}
+	return time.Minute
+}
@@ -0,0 +1,132 @@
+package jwtx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chain-products-org/goal/uuid"
+)
+
+// Signer provides the key to sign tokens with, KeyRing implements it.
+type Signer interface {
+	Active() *Key
+}
+
+// Option configures a Service.
+type Option func(s *Service)
+
+// WithIssuer sets the "iss" claim of issued tokens, and requires it when verifying.
+func WithIssuer(iss string) Option {
+	return func(s *Service) {
+		s.issuer = iss
+	}
+}
+
+// WithAudience sets the "aud" claim of issued tokens, and requires it when verifying.
+func WithAudience(aud string) Option {
+	return func(s *Service) {
+		s.audience = aud
+	}
+}
+
+// WithTTL sets the lifetime of issued tokens, 2 hours by default.
+func WithTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.ttl = ttl
+	}
+}
+
+// WithLeeway sets the allowed clock skew when validating time based claims.
+func WithLeeway(leeway time.Duration) Option {
+	return func(s *Service) {
+		s.leeway = leeway
+	}
+}
+
+// WithClock replaces time.Now, mainly for testing.
+func WithClock(now func() time.Time) Option {
+	return func(s *Service) {
+		s.now = now
+	}
+}
+
+// Service issues and verifies JWTs.
+type Service struct {
+	keys     KeyProvider
+	issuer   string
+	audience string
+	ttl      time.Duration
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewService creates a Service that verifies tokens with keys. If keys also implements Signer,
+// such as a KeyRing, the service is able to issue tokens too.
+func NewService(keys KeyProvider, opts ...Option) *Service {
+	s := &Service{keys: keys, ttl: 2 * time.Hour, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Issue signs the given claims with the active key. The "iat", "exp", "jti", "iss" and "aud"
+// claims are filled in when they are not set.
+func (s *Service) Issue(claims Claims) (string, error) {
+	signer, ok := s.keys.(Signer)
+	if !ok {
+		return "", errors.New("jwt: the key provider can not sign")
+	}
+	now := s.now()
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = NewNumericDate(now)
+	}
+	if claims.ExpiresAt == 0 && s.ttl > 0 {
+		claims.ExpiresAt = NewNumericDate(now.Add(s.ttl))
+	}
+	if claims.ID == "" {
+		claims.ID = uuid.UUID()
+	}
+	if claims.Issuer == "" {
+		claims.Issuer = s.issuer
+	}
+	if len(claims.Audience) == 0 && s.audience != "" {
+		claims.Audience = Audience{s.audience}
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return Sign(payload, signer.Active())
+}
+
+// Verify checks the signature and the claims of token, and returns the claims if it is valid.
+func (s *Service) Verify(ctx context.Context, token string) (*Claims, error) {
+	jws, err := ParseJWS(token)
+	if err != nil {
+		return nil, err
+	}
+	switch jws.Header.Alg {
+	case HS256, RS256, ES256, EdDSA:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlg, jws.Header.Alg)
+	}
+	key, err := s.keys.Key(ctx, jws.Header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := jws.Verify(key); err != nil {
+		return nil, err
+	}
+	var claims Claims
+	if err := json.Unmarshal(jws.Payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrMalformed, err)
+	}
+	if err := claims.Validate(s.now(), s.leeway, s.issuer, s.audience); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
@@ -0,0 +1,144 @@
+package logx
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDHeader   = "X-Request-Id"
+	TraceParentHeader = "traceparent" // W3C trace context
+
+	requestIDField = "requestId"
+	traceIDField   = "traceId"
+	userIDField    = "userId"
+)
+
+type loggerKey struct{}
+
+type idsKey struct{}
+
+// ids are the request scoped ids logged as fields.
+type ids struct {
+	request, trace, user string
+}
+
+// IntoContext returns a copy of ctx carrying logger, bound with the ids of ctx.
+func IntoContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger.bind(idsOf(ctx)))
+}
+
+// FromContext returns the logger of ctx, or Default bound with the ids of ctx. A *gin.Context is
+// supported, see Logger.NewContext.
+func FromContext(ctx context.Context) *Logger {
+	return FromContextOr(ctx, Default)
+}
+
+// FromContextOr returns the logger of ctx, or fallback bound with the ids of ctx.
+func FromContextOr(ctx context.Context, fallback *Logger) *Logger {
+	if ctx == nil {
+		return fallback
+	}
+	if l, ok := requestContext(ctx).Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	if gc, ok := ctx.(*gin.Context); ok && gc != nil {
+		if l, ok := gc.Value(LoggerKey).(*Logger); ok {
+			return l
+		}
+	}
+	return fallback.bind(idsOf(ctx))
+}
+
+// WithRequestID returns a copy of ctx carrying the request id, which is added to its logger.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return withIDs(ctx, func(ids *ids) { ids.request = id })
+}
+
+// WithTraceID returns a copy of ctx carrying the trace id, which is added to its logger.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return withIDs(ctx, func(ids *ids) { ids.trace = id })
+}
+
+// WithUserID returns a copy of ctx carrying the user id, which is added to its logger.
+func WithUserID(ctx context.Context, id string) context.Context {
+	return withIDs(ctx, func(ids *ids) { ids.user = id })
+}
+
+// RequestID returns the request id of ctx, of a *gin.Context it defaults to the X-Request-Id
+// header.
+func RequestID(ctx context.Context) string {
+	return idsOf(ctx).request
+}
+
+// TraceID returns the trace id of ctx, of a *gin.Context it defaults to the trace id of the
+// traceparent header.
+func TraceID(ctx context.Context) string {
+	return idsOf(ctx).trace
+}
+
+// UserID returns the user id of ctx.
+func UserID(ctx context.Context) string {
+	return idsOf(ctx).user
+}
+
+func withIDs(ctx context.Context, set func(ids *ids)) context.Context {
+	cur, _ := ctx.Value(idsKey{}).(ids)
+	set(&cur)
+	ctx = context.WithValue(ctx, idsKey{}, cur)
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		ctx = context.WithValue(ctx, loggerKey{}, l.bind(cur))
+	}
+	return ctx
+}
+
+func idsOf(ctx context.Context) ids {
+	cur, _ := requestContext(ctx).Value(idsKey{}).(ids)
+	if gc, ok := ctx.(*gin.Context); ok && gc != nil && gc.Request != nil {
+		if cur.request == "" {
+			cur.request = gc.GetHeader(RequestIDHeader)
+		}
+		if cur.trace == "" {
+			// version-traceid-parentid-flags
+			if parts := strings.Split(gc.GetHeader(TraceParentHeader), "-"); len(parts) == 4 {
+				cur.trace = parts[1]
+			}
+		}
+	}
+	return cur
+}
+
+// requestContext returns the request context of a *gin.Context, as its Value does not fall back
+// to it by default.
+func requestContext(ctx context.Context) context.Context {
+	if gc, ok := ctx.(*gin.Context); ok {
+		if gc == nil || gc.Request == nil {
+			return context.Background()
+		}
+		return gc.Request.Context()
+	}
+	return ctx
+}
+
+// bind returns l with the fields of the ids which are not bound yet.
+func (l *Logger) bind(cur ids) *Logger {
+	var args []any
+	bound := l.ids
+	add := func(field, id string, b *string) {
+		if id != "" && id != *b {
+			args = append(args, field, id)
+			*b = id
+		}
+	}
+	add(requestIDField, cur.request, &bound.request)
+	add(traceIDField, cur.trace, &bound.trace)
+	add(userIDField, cur.user, &bound.user)
+	if len(args) == 0 {
+		return l
+	}
+	logger := l.derive(l.SugaredLogger.With(args...))
+	logger.ids = bound
+	return logger
+}
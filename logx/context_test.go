@@ -0,0 +1,59 @@
+package logx_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chain-products-org/goal/logx"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	logger, read := newTestLog(t, &logx.Zap{Level: "info"})
+	assert.Same(t, logx.Default, logx.FromContext(context.Background()))
+
+	ctx := logx.IntoContext(context.Background(), logger)
+	ctx = logx.WithRequestID(ctx, "req-1")
+	ctx = logx.WithUserID(ctx, "user-1")
+	assert.Equal(t, "req-1", logx.RequestID(ctx))
+	logx.FromContext(ctx).Info("first")
+	// the ids are not bound twice
+	ctx = logx.IntoContext(ctx, logx.FromContext(ctx))
+	logx.FromContext(ctx).Info("second")
+	for _, msg := range []string{"first", "second"} {
+		line := strings.Join(lines(read("info"), msg), "")
+		assert.Equal(t, 1, strings.Count(line, `"requestId": "req-1"`), line)
+		assert.Equal(t, 1, strings.Count(line, `"userId": "user-1"`), line)
+	}
+
+	// the ids set before the logger
+	ctx = logx.WithTraceID(context.Background(), "trace-1")
+	logx.FromContextOr(ctx, logger).Info("third")
+	logx.FromContext(logx.IntoContext(ctx, logger)).Info("fourth")
+	assert.Len(t, lines(read("info"), `third	{"traceId": "trace-1"}`), 1)
+	assert.Len(t, lines(read("info"), `fourth	{"traceId": "trace-1"}`), 1)
+}
+
+func TestGinContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger, read := newTestLog(t, &logx.Zap{Level: "info"})
+	gc, _ := gin.CreateTestContext(httptest.NewRecorder())
+	gc.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	gc.Request.Header.Set(logx.RequestIDHeader, "req-2")
+	gc.Request.Header.Set(logx.TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	logger.NewContext(gc, "path", "/")
+	logger.WithContext(gc).Info("gin")
+	// the handlers can pass the request context on
+	logx.FromContext(gc.Request.Context()).Info("request")
+	for _, msg := range []string{"gin", "request"} {
+		line := strings.Join(lines(read("info"), msg), "")
+		assert.Contains(t, line, `"requestId": "req-2"`)
+		assert.Contains(t, line, `"traceId": "4bf92f3577b34da6a3ce929d0e0e4736"`)
+		assert.Contains(t, line, `"path": "/"`)
+	}
+}
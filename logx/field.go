@@ -0,0 +1,45 @@
+package logx
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Err returns a field of err keyed "error", like zap.Error, but an error marshaling itself as a
+// zap object, like errorx.Error, is logged with its fields, e.g. its code, status and details,
+// even if it is wrapped:
+//
+//	logger.Desugar().Error("pay failed", logx.Err(err))
+//	logger.Errorw("pay failed", logx.Err(err))
+func Err(err error) zap.Field {
+	return NamedErr("error", err)
+}
+
+// NamedErr is Err keyed key.
+func NamedErr(key string, err error) zap.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+	var m zapcore.ObjectMarshaler
+	if !errors.As(err, &m) {
+		return zap.NamedError(key, err)
+	}
+	_, direct := err.(zapcore.ObjectMarshaler)
+	return zap.Object(key, errObject{err: err, m: m, wrapped: !direct})
+}
+
+// errObject logs the fields of m in the chain of err, and the message of err if it is wrapped.
+type errObject struct {
+	err     error
+	m       zapcore.ObjectMarshaler
+	wrapped bool
+}
+
+func (o errObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if o.wrapped {
+		enc.AddString("error", o.err.Error())
+	}
+	return o.m.MarshalLogObject(enc)
+}
@@ -1,28 +1,64 @@
 package logx
 
 import (
-	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
-	"go.uber.org/zap/zapcore"
+	"io"
 	"os"
 	"path"
 	"time"
+
+	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+	"go.uber.org/zap/zapcore"
 )
 
 var FileRotatelogs = new(fileRotatelogs)
 
 type fileRotatelogs struct{}
 
-// GetWriteSyncer 获取 zapcore.WriteSyncer
-func (r *fileRotatelogs) GetWriteSyncer(c *Zap, level string) (zapcore.WriteSyncer, error) {
-	fileWriter, err := rotatelogs.New(
-		path.Join(c.Director, "%Y-%m-%d", level+".log"),
-		rotatelogs.WithClock(rotatelogs.Local),                      // 日志时间所在的时区
-		rotatelogs.WithMaxAge(time.Duration(c.MaxAge)*24*time.Hour), // 日志留存时间
-		rotatelogs.WithRotationTime(time.Hour*24),                   // 日志滚动时间间隔，默认一天
-		rotatelogs.WithRotationCount(15),                            // 最大日志文件数量
+// GetWriteSyncer 获取 zapcore.WriteSyncer，name 为日志文件名（级别，或合并日志的 all）
+//
+// MaxDiskSize 在这里是单个文件的预算，NewLog 创建的 Logger 则由所有级别的文件共享
+func (r *fileRotatelogs) GetWriteSyncer(c *Zap, name string) (zapcore.WriteSyncer, error) {
+	ws, _, err := r.newWriteSyncer(c, name, nil)
+	return ws, err
+}
+
+// newWriteSyncer 返回 zapcore.WriteSyncer 以及需要在 Logger.Close 时关闭的文件 writer，
+// budget 不为 nil 时由多个文件共享磁盘预算
+func (r *fileRotatelogs) newWriteSyncer(c *Zap, name string, budget *DiskBudget) (zapcore.WriteSyncer, io.Closer, error) {
+	var (
+		fileWriter io.WriteCloser
+		err        error
 	)
+	if c.Rotator == RotatorSize {
+		rc := RotateConfig{
+			Filename: path.Join(c.Director, name+".log"),
+			MaxSize:  int64(c.MaxSize) << 20,
+			MaxAge:   time.Duration(c.MaxAge) * 24 * time.Hour,
+			Compress: c.Compress,
+			Budget:   budget,
+		}
+		if budget == nil {
+			rc.MaxTotalSize = int64(c.MaxDiskSize) << 20
+		}
+		fileWriter, err = NewRotateWriter(rc)
+	} else {
+		opts := []rotatelogs.Option{
+			rotatelogs.WithClock(rotatelogs.Local),      // 日志时间所在的时区
+			rotatelogs.WithRotationTime(time.Hour * 24), // 日志滚动时间间隔，默认一天
+		}
+		// 留存时间与最大日志文件数量不能同时设置
+		if c.MaxAge > 0 {
+			opts = append(opts, rotatelogs.WithMaxAge(time.Duration(c.MaxAge)*24*time.Hour))
+		} else {
+			opts = append(opts, rotatelogs.WithRotationCount(c.rotationCount()))
+		}
+		fileWriter, err = rotatelogs.New(path.Join(c.Director, "%Y-%m-%d", name+".log"), opts...)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 	if c.LogInConsole {
-		return zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout), zapcore.AddSync(fileWriter)), err
+		return zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout), zapcore.AddSync(fileWriter)), fileWriter, nil
 	}
-	return zapcore.AddSync(fileWriter), err
+	return zapcore.AddSync(fileWriter), fileWriter, nil
 }
@@ -0,0 +1,246 @@
+package logx
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelHandlerPath is the conventional path to mount Levels as a http.Handler.
+const LevelHandlerPath = "/debug/loglevel"
+
+// Levels are the levels of a logger and of its module loggers, which can be changed at runtime.
+// The modules without a level of their own follow the level of the logger.
+type Levels struct {
+	root zap.AtomicLevel
+
+	mu      sync.RWMutex
+	modules map[string]*moduleLevel
+}
+
+func newLevels(l zapcore.Level) *Levels {
+	return &Levels{root: zap.NewAtomicLevelAt(l), modules: map[string]*moduleLevel{}}
+}
+
+// Level returns the level of the logger.
+func (ls *Levels) Level() zapcore.Level {
+	return ls.root.Level()
+}
+
+// SetLevel sets the level of the logger.
+func (ls *Levels) SetLevel(l zapcore.Level) {
+	ls.root.SetLevel(l)
+}
+
+// ModuleLevel returns the level of module, and whether it has a level of its own.
+func (ls *Levels) ModuleLevel(module string) (zapcore.Level, bool) {
+	m := ls.module(module)
+	if m.set.Load() {
+		return m.level.Level(), true
+	}
+	return ls.root.Level(), false
+}
+
+// SetModuleLevel sets the level of module.
+func (ls *Levels) SetModuleLevel(module string, l zapcore.Level) {
+	m := ls.module(module)
+	m.level.SetLevel(l)
+	m.set.Store(true)
+}
+
+// ResetModuleLevel makes module follow the level of the logger again.
+func (ls *Levels) ResetModuleLevel(module string) {
+	ls.module(module).set.Store(false)
+}
+
+// Apply sets the levels of c, the modules absent from c.Modules follow the level of the logger.
+func (ls *Levels) Apply(c *Zap) error {
+	root, err := ParseLevel(c.Level)
+	if err != nil {
+		return err
+	}
+	modules := make(map[string]zapcore.Level, len(c.Modules))
+	for name, s := range c.Modules {
+		if modules[name], err = ParseLevel(s); err != nil {
+			return fmt.Errorf("level of module %s: %w", name, err)
+		}
+	}
+	ls.SetLevel(root)
+	ls.mu.RLock()
+	names := make([]string, 0, len(ls.modules))
+	for name := range ls.modules {
+		names = append(names, name)
+	}
+	ls.mu.RUnlock()
+	for _, name := range names {
+		if _, ok := modules[name]; !ok {
+			ls.ResetModuleLevel(name)
+		}
+	}
+	for name, l := range modules {
+		ls.SetModuleLevel(name, l)
+	}
+	return nil
+}
+
+// ReloadOnSIGHUP applies the levels of the config returned by load on SIGHUP, until stop is
+// called. A failed reload is reported by onError, if not nil, and the levels are kept.
+func (ls *Levels) ReloadOnSIGHUP(load func() (*Zap, error), onError func(err error)) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ch:
+				c, err := load()
+				if err == nil {
+					err = ls.Apply(c)
+				}
+				if err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+func (ls *Levels) module(name string) *moduleLevel {
+	ls.mu.RLock()
+	m, ok := ls.modules[name]
+	ls.mu.RUnlock()
+	if ok {
+		return m
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if m, ok = ls.modules[name]; !ok {
+		m = &moduleLevel{root: ls.root, level: zap.NewAtomicLevel()}
+		ls.modules[name] = m
+	}
+	return m
+}
+
+type levelsPayload struct {
+	Module  string            `json:"module,omitempty"`
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+// ServeHTTP serves the levels: GET returns the levels, PUT sets the level of the logger, or of
+// a module, by a JSON body like {"level":"debug"} or {"module":"db","level":"debug"}, or by the
+// same query params. An empty level of a module resets it.
+func (ls *Levels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req levelsPayload
+		if r.URL.Query().Has("level") {
+			req.Module, req.Level = r.URL.Query().Get("module"), r.URL.Query().Get("level")
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeLevelError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.Module != "" && req.Level == "" {
+			ls.ResetModuleLevel(req.Module)
+			break
+		}
+		l, err := ParseLevel(req.Level)
+		if err != nil {
+			writeLevelError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.Module == "" {
+			ls.SetLevel(l)
+		} else {
+			ls.SetModuleLevel(req.Module, l)
+		}
+	default:
+		writeLevelError(w, http.StatusMethodNotAllowed, fmt.Errorf("only GET and PUT are supported"))
+		return
+	}
+
+	resp := levelsPayload{Level: ls.Level().String(), Modules: map[string]string{}}
+	ls.mu.RLock()
+	names := make([]string, 0, len(ls.modules))
+	for name := range ls.modules {
+		names = append(names, name)
+	}
+	ls.mu.RUnlock()
+	sort.Strings(names)
+	for _, name := range names {
+		if l, ok := ls.ModuleLevel(name); ok {
+			resp.Modules[name] = l.String()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func writeLevelError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// ParseLevel parses a level name, case-insensitively, an empty name is debug.
+func ParseLevel(s string) (zapcore.Level, error) {
+	if s == "" {
+		return zapcore.DebugLevel, nil
+	}
+	return zapcore.ParseLevel(strings.ToLower(s))
+}
+
+// moduleLevel is the level of a module, which follows root unless set.
+type moduleLevel struct {
+	root  zap.AtomicLevel
+	level zap.AtomicLevel
+	set   atomic.Bool
+}
+
+func (m *moduleLevel) Enabled(l zapcore.Level) bool {
+	if m.set.Load() {
+		return m.level.Enabled(l)
+	}
+	return m.root.Enabled(l)
+}
+
+// levelCore filters the entries of a core by a level enabler.
+type levelCore struct {
+	zapcore.Core
+	enabler zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(l zapcore.Level) bool {
+	return c.enabler.Enabled(l)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), enabler: c.enabler}
+}
+
+func (c *levelCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enabler.Enabled(e.Level) {
+		return ce
+	}
+	return c.Core.Check(e, ce)
+}
@@ -0,0 +1,120 @@
+package logx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/logx"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func newTestLog(t *testing.T, c *logx.Zap) (*logx.Logger, func(level string) string) {
+	c.Director = t.TempDir()
+	logger := logx.NewLog(c)
+	// read the files of every day the logger wrote, as a test may cross midnight
+	read := func(level string) string {
+		files, _ := filepath.Glob(filepath.Join(c.Director, "*", level+".log"))
+		var sb strings.Builder
+		for _, f := range files {
+			bs, _ := os.ReadFile(f)
+			sb.Write(bs)
+		}
+		return sb.String()
+	}
+	return logger, read
+}
+
+func TestLevels(t *testing.T) {
+	logger, read := newTestLog(t, &logx.Zap{Level: "info", Modules: map[string]string{"db": "debug"}})
+	levels := logger.Levels()
+	db, cache := logger.Module("db"), logger.Module("cache")
+
+	logger.Debug("root debug 1")
+	db.Debug("db debug 1")
+	cache.Debug("cache debug 1")
+	assert.Equal(t, "", strings.Join(lines(read("debug"), "root", "cache"), ","))
+	assert.Len(t, lines(read("debug"), "db debug 1"), 1)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		levels.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	w := serve(http.MethodPut, logx.LevelHandlerPath, `{"level":"debug"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"debug","modules":{"db":"debug"}}`, w.Body.String())
+	w = serve(http.MethodPut, logx.LevelHandlerPath+"?module=db&level=error", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, logx.LevelHandlerPath, `{"level":"loud"}`).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodPost, logx.LevelHandlerPath, "").Code)
+	w = serve(http.MethodGet, logx.LevelHandlerPath, "")
+	assert.JSONEq(t, `{"level":"debug","modules":{"db":"error"}}`, w.Body.String())
+
+	logger.Debug("root debug 2")
+	db.Debug("db debug 2")
+	db.With("k", "v").Info("db info 2")
+	cache.Debug("cache debug 2")
+	assert.Len(t, lines(read("debug"), "root debug 2"), 1)
+	assert.Len(t, lines(read("debug"), "cache debug 2"), 1)
+	assert.Len(t, lines(read("debug"), "db debug 2"), 0)
+	assert.Len(t, lines(read("info"), "db info 2"), 0)
+
+	// reset the module
+	serve(http.MethodPut, logx.LevelHandlerPath, `{"module":"db"}`)
+	_, ok := levels.ModuleLevel("db")
+	assert.False(t, ok)
+	db.Debug("db debug 3")
+	assert.Len(t, lines(read("debug"), "db debug 3"), 1)
+}
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	logger, _ := newTestLog(t, &logx.Zap{Level: "info"})
+	levels := logger.Levels()
+	stop := levels.ReloadOnSIGHUP(func() (*logx.Zap, error) {
+		return &logx.Zap{Level: "warn", Modules: map[string]string{"db": "debug"}}, nil
+	}, func(err error) {
+		t.Error(err)
+	})
+	defer stop()
+
+	p, err := os.FindProcess(os.Getpid())
+	assert.Nil(t, err)
+	if err = p.Signal(syscall.SIGHUP); err != nil {
+		t.Skip("SIGHUP is not supported:", err)
+	}
+	assert.Eventually(t, func() bool {
+		l, ok := levels.ModuleLevel("db")
+		return levels.Level() == zapcore.WarnLevel && ok && l == zapcore.DebugLevel
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSampling(t *testing.T) {
+	logger, read := newTestLog(t, &logx.Zap{Level: "info", Sampling: &logx.Sampling{Initial: 2, Thereafter: 0}})
+	for i := 0; i < 5; i++ {
+		logger.Info("noisy")
+	}
+	logger.Info("quiet")
+	assert.Len(t, lines(read("info"), "noisy"), 2)
+	assert.Len(t, lines(read("info"), "quiet"), 1)
+}
+
+// lines returns the lines of s containing any of subs.
+func lines(s string, subs ...string) []string {
+	var matched []string
+	for _, line := range strings.Split(s, "\n") {
+		for _, sub := range subs {
+			if strings.Contains(line, sub) {
+				matched = append(matched, line)
+				break
+			}
+		}
+	}
+	return matched
+}
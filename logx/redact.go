@@ -0,0 +1,484 @@
+package logx
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/chain-products-org/goal/stringx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Mask replaces the redacted values.
+const Mask = "******"
+
+// defaultSensitive are the suffixes of the sensitive field names, normalized by normalizeName.
+var defaultSensitive = []string{
+	"password", "passwd", "secret", "token", "privatekey", "apikey", "authorization", "cookie",
+}
+
+var (
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]*`)
+	base58Pattern = regexp.MustCompile(`\b(?:[1-9A-HJ-NP-Za-km-z]{64,88}|[5KLc9][1-9A-HJ-NP-Za-km-z]{50,51})\b`)
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	// "key": "value" of JSON, and key=value of queries and forms
+	jsonPairPattern  = regexp.MustCompile(`"([A-Za-z0-9_.-]+)"(\s*:\s*)"((?:[^"\\]|\\.)*)"`)
+	queryPairPattern = regexp.MustCompile(`\b([A-Za-z0-9_.-]+)=([^&\s"]+)`)
+)
+
+// Redact configures the redaction of logs.
+type Redact struct {
+	// Fields are the names of the sensitive fields besides the defaults, like password, secret,
+	// token and private key. The names match case-insensitively the end of a field name ignoring
+	// '_', '-' and '.', "secret" matches clientSecret and api_secret.
+	Fields []string `mapstructure:"fields" yaml:"fields"`
+	// Base58Keys masks the base58 strings of the length of private keys, like the 64 byte keys of
+	// solana and the WIF keys of bitcoin. It is off by default, as the solana transaction
+	// signatures are of the same length.
+	Base58Keys bool `mapstructure:"base58Keys" yaml:"base58Keys"`
+}
+
+// Redactor masks the sensitive data of logs: the values of the sensitive field names, of the
+// struct fields tagged `log:"secret"`, and the JWTs and email addresses in strings, and the base58
+// private keys if MaskBase58Keys.
+type Redactor struct {
+	sensitive []string
+	base58    bool
+}
+
+// NewRedactor returns a Redactor of the default sensitive field names and fields.
+func NewRedactor(fields ...string) *Redactor {
+	r := &Redactor{sensitive: append([]string(nil), defaultSensitive...)}
+	for _, f := range fields {
+		r.sensitive = append(r.sensitive, normalizeName(f))
+	}
+	return r
+}
+
+// MaskBase58Keys masks the base58 private keys in strings, and the solana transaction signatures
+// alike, and returns r.
+func (r *Redactor) MaskBase58Keys() *Redactor {
+	r.base58 = true
+	return r
+}
+
+func (c *Redact) redactor() *Redactor {
+	if c == nil {
+		return nil
+	}
+	r := NewRedactor(c.Fields...)
+	r.base58 = c.Base58Keys
+	return r
+}
+
+func normalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '-', '.', ' ':
+			return -1
+		}
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, name)
+}
+
+// Sensitive reports whether the values of the field name are masked.
+func (r *Redactor) Sensitive(name string) bool {
+	name = normalizeName(name)
+	for _, s := range r.sensitive {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// String masks the JWTs, base58 private keys if MaskBase58Keys, email addresses, and the values
+// of sensitive keys of JSON and queries in s.
+func (r *Redactor) String(s string) string {
+	if s == "" {
+		return s
+	}
+	s = jsonPairPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := jsonPairPattern.FindStringSubmatch(m)
+		if !r.Sensitive(sub[1]) {
+			return m
+		}
+		return `"` + sub[1] + `"` + sub[2] + `"` + Mask + `"`
+	})
+	s = queryPairPattern.ReplaceAllStringFunc(s, func(m string) string {
+		key, _, _ := strings.Cut(m, "=")
+		if !r.Sensitive(key) {
+			return m
+		}
+		return key + "=" + Mask
+	})
+	s = jwtPattern.ReplaceAllString(s, "eyJ"+Mask)
+	if r.base58 {
+		s = base58Pattern.ReplaceAllString(s, Mask)
+	}
+	return emailPattern.ReplaceAllStringFunc(s, stringx.BlurEmail)
+}
+
+// Value returns v with the sensitive data masked, by a copy of the same type if anything is
+// masked: the strings of sensitive fields are replaced by Mask, and the other strings are masked
+// by String.
+func (r *Redactor) Value(v any) any {
+	if v == nil {
+		return nil
+	}
+	if masked, ok := r.value(reflect.ValueOf(v), 0); ok && masked.CanInterface() {
+		return masked.Interface()
+	}
+	return v
+}
+
+const maxRedactDepth = 8
+
+// value returns a masked copy of v, and true, or v and false if nothing is masked.
+func (r *Redactor) value(v reflect.Value, depth int) (reflect.Value, bool) {
+	if depth > maxRedactDepth {
+		return v, false
+	}
+	switch v.Kind() {
+	case reflect.String:
+		s := r.String(v.String())
+		if s == v.String() {
+			return v, false
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.SetString(s)
+		return c, true
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return v, false
+		}
+		elem, ok := r.value(v.Elem(), depth+1)
+		if !ok {
+			return v, false
+		}
+		if v.Kind() == reflect.Pointer {
+			p := reflect.New(v.Type().Elem())
+			p.Elem().Set(elem)
+			return p, true
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(elem)
+		return c, true
+	case reflect.Struct:
+		var c reflect.Value
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			masked, ok := v.Field(i), false
+			if f.Tag.Get("log") == "secret" || r.Sensitive(f.Name) {
+				if !masked.IsZero() {
+					masked, ok = maskValue(masked), true
+				}
+			} else {
+				masked, ok = r.value(masked, depth+1)
+			}
+			if !ok {
+				continue
+			}
+			if !c.IsValid() {
+				c = reflect.New(t).Elem()
+				c.Set(v)
+			}
+			c.Field(i).Set(masked)
+		}
+		return c, c.IsValid()
+	case reflect.Map:
+		if v.IsNil() {
+			return v, false
+		}
+		var c reflect.Value
+		iter := v.MapRange()
+		for iter.Next() {
+			k, masked, ok := iter.Key(), iter.Value(), false
+			if k.Kind() == reflect.String && r.Sensitive(k.String()) {
+				if !masked.IsZero() {
+					masked, ok = maskValue(masked), true
+				}
+			} else {
+				masked, ok = r.value(masked, depth+1)
+			}
+			if !ok {
+				continue
+			}
+			if !c.IsValid() {
+				c = reflect.MakeMapWithSize(v.Type(), v.Len())
+				all := v.MapRange()
+				for all.Next() {
+					c.SetMapIndex(all.Key(), all.Value())
+				}
+			}
+			c.SetMapIndex(k, masked)
+		}
+		return c, c.IsValid()
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8) {
+			return v, false
+		}
+		var c reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			masked, ok := r.value(v.Index(i), depth+1)
+			if !ok {
+				continue
+			}
+			if !c.IsValid() {
+				if v.Kind() == reflect.Slice {
+					c = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+					reflect.Copy(c, v)
+				} else {
+					c = reflect.New(v.Type()).Elem()
+					c.Set(v)
+				}
+			}
+			c.Index(i).Set(masked)
+		}
+		return c, c.IsValid()
+	default:
+		return v, false
+	}
+}
+
+// maskValue returns the mask of the non-zero v, Mask for a string, or else the zero value.
+func maskValue(v reflect.Value) reflect.Value {
+	switch {
+	case v.Kind() == reflect.String:
+		return reflect.ValueOf(Mask).Convert(v.Type())
+	case v.Kind() == reflect.Interface && v.Elem().Kind() == reflect.String:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(reflect.ValueOf(Mask))
+		return c
+	default:
+		return reflect.Zero(v.Type())
+	}
+}
+
+// args returns args with the sensitive data masked, for the sugared methods.
+func (r *Redactor) args(args []any) []any {
+	if r == nil || len(args) == 0 {
+		return args
+	}
+	masked := make([]any, len(args))
+	for i, a := range args {
+		masked[i] = r.Value(a)
+	}
+	return masked
+}
+
+// with returns the key-value pairs and fields of With with the sensitive data masked, as the
+// encoder only masks the strings of the fields added by With.
+func (r *Redactor) with(args []any) []any {
+	if r == nil || len(args) == 0 {
+		return args
+	}
+	masked := make([]any, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch a := args[i].(type) {
+		case zapcore.Field:
+			masked = append(masked, r.field(a))
+		case string:
+			if i+1 == len(args) {
+				// a dangling key, reported by zap
+				masked = append(masked, a)
+				continue
+			}
+			v := args[i+1]
+			if r.Sensitive(a) {
+				v = Mask
+			} else {
+				v = r.Value(v)
+			}
+			masked = append(masked, a, v)
+			i++
+		default:
+			// an invalid key, the pair is reported and dropped by zap
+			masked = append(masked, a)
+			if i+1 < len(args) {
+				masked = append(masked, args[i+1])
+				i++
+			}
+		}
+	}
+	return masked
+}
+
+// field returns f with the sensitive data masked.
+func (r *Redactor) field(f zapcore.Field) zapcore.Field {
+	if f.Key != "" && r.Sensitive(f.Key) {
+		return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: Mask}
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = r.String(f.String)
+	case zapcore.ByteStringType:
+		f.Interface = []byte(r.String(string(f.Interface.([]byte))))
+	case zapcore.ReflectType:
+		f.Interface = r.Value(f.Interface)
+	case zapcore.StringerType:
+		if s, ok := f.Interface.(interface{ String() string }); ok {
+			f = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: r.String(s.String())}
+		}
+	case zapcore.ObjectMarshalerType:
+		f.Interface = redactMarshaler{ObjectMarshaler: f.Interface.(zapcore.ObjectMarshaler), r: r}
+	}
+	return f
+}
+
+// redactEncoder masks the messages and fields of an encoder.
+type redactEncoder struct {
+	zapcore.Encoder
+	r *Redactor
+}
+
+// NewRedactEncoder returns an encoder masking the messages and fields of enc by r.
+func NewRedactEncoder(enc zapcore.Encoder, r *Redactor) zapcore.Encoder {
+	return &redactEncoder{Encoder: enc, r: r}
+}
+
+func (e *redactEncoder) Clone() zapcore.Encoder {
+	return &redactEncoder{Encoder: e.Encoder.Clone(), r: e.r}
+}
+
+func (e *redactEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	ent.Message = e.r.String(ent.Message)
+	masked := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		masked[i] = e.r.field(f)
+	}
+	return e.Encoder.EncodeEntry(ent, masked)
+}
+
+// the fields added by With
+
+func (e *redactEncoder) AddString(key, value string) {
+	redactObjectEncoder{e.Encoder, e.r}.AddString(key, value)
+}
+
+func (e *redactEncoder) AddByteString(key string, value []byte) {
+	redactObjectEncoder{e.Encoder, e.r}.AddByteString(key, value)
+}
+
+func (e *redactEncoder) AddReflected(key string, value any) error {
+	return redactObjectEncoder{e.Encoder, e.r}.AddReflected(key, value)
+}
+
+func (e *redactEncoder) AddObject(key string, value zapcore.ObjectMarshaler) error {
+	return redactObjectEncoder{e.Encoder, e.r}.AddObject(key, value)
+}
+
+// redactObjectEncoder masks the fields added to an object encoder.
+type redactObjectEncoder struct {
+	zapcore.ObjectEncoder
+	r *Redactor
+}
+
+func (e redactObjectEncoder) AddString(key, value string) {
+	if e.r.Sensitive(key) {
+		e.ObjectEncoder.AddString(key, Mask)
+		return
+	}
+	e.ObjectEncoder.AddString(key, e.r.String(value))
+}
+
+func (e redactObjectEncoder) AddByteString(key string, value []byte) {
+	if e.r.Sensitive(key) {
+		e.ObjectEncoder.AddString(key, Mask)
+		return
+	}
+	e.ObjectEncoder.AddByteString(key, []byte(e.r.String(string(value))))
+}
+
+func (e redactObjectEncoder) AddReflected(key string, value any) error {
+	if e.r.Sensitive(key) {
+		e.ObjectEncoder.AddString(key, Mask)
+		return nil
+	}
+	return e.ObjectEncoder.AddReflected(key, e.r.Value(value))
+}
+
+func (e redactObjectEncoder) AddObject(key string, value zapcore.ObjectMarshaler) error {
+	if e.r.Sensitive(key) {
+		e.ObjectEncoder.AddString(key, Mask)
+		return nil
+	}
+	return e.ObjectEncoder.AddObject(key, redactMarshaler{ObjectMarshaler: value, r: e.r})
+}
+
+// redactMarshaler masks the fields of an object marshaler.
+type redactMarshaler struct {
+	zapcore.ObjectMarshaler
+	r *Redactor
+}
+
+func (m redactMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return m.ObjectMarshaler.MarshalLogObject(redactObjectEncoder{ObjectEncoder: enc, r: m.r})
+}
+
+// With returns a Logger with the key-value pairs or fields added to its context, masked like
+// the fields of the entries.
+func (l *Logger) With(args ...any) *Logger {
+	return l.derive(l.SugaredLogger.With(l.redactor.with(args)...))
+}
+
+// The sugared methods formatting their args, which are masked before the formatting as the
+// encoder only sees the formatted message, e.g. a struct printed by %+v.
+
+func (l *Logger) sugar() *zap.SugaredLogger {
+	if l.skipped == nil {
+		return l.SugaredLogger.WithOptions(zap.AddCallerSkip(1))
+	}
+	return l.skipped
+}
+
+func (l *Logger) Debug(args ...any) { l.sugar().Debug(l.redactor.args(args)...) }
+
+func (l *Logger) Info(args ...any) { l.sugar().Info(l.redactor.args(args)...) }
+
+func (l *Logger) Warn(args ...any) { l.sugar().Warn(l.redactor.args(args)...) }
+
+func (l *Logger) Error(args ...any) { l.sugar().Error(l.redactor.args(args)...) }
+
+func (l *Logger) DPanic(args ...any) { l.sugar().DPanic(l.redactor.args(args)...) }
+
+func (l *Logger) Panic(args ...any) { l.sugar().Panic(l.redactor.args(args)...) }
+
+func (l *Logger) Fatal(args ...any) { l.sugar().Fatal(l.redactor.args(args)...) }
+
+func (l *Logger) Debugf(template string, args ...any) {
+	l.sugar().Debugf(template, l.redactor.args(args)...)
+}
+
+func (l *Logger) Infof(template string, args ...any) {
+	l.sugar().Infof(template, l.redactor.args(args)...)
+}
+
+func (l *Logger) Warnf(template string, args ...any) {
+	l.sugar().Warnf(template, l.redactor.args(args)...)
+}
+
+func (l *Logger) Errorf(template string, args ...any) {
+	l.sugar().Errorf(template, l.redactor.args(args)...)
+}
+
+func (l *Logger) DPanicf(template string, args ...any) {
+	l.sugar().DPanicf(template, l.redactor.args(args)...)
+}
+
+func (l *Logger) Panicf(template string, args ...any) {
+	l.sugar().Panicf(template, l.redactor.args(args)...)
+}
+
+func (l *Logger) Fatalf(template string, args ...any) {
+	l.sugar().Fatalf(template, l.redactor.args(args)...)
+}
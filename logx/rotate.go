@@ -0,0 +1,410 @@
+package logx
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000"
+
+// RotateConfig configures a RotateWriter.
+type RotateConfig struct {
+	// Filename is the path of the active file, the rotated files are named after it with the time
+	// of rotation, e.g. info.log is rotated to info-20240102T150405.000.log.
+	Filename string
+	// MaxSize is the size in bytes after which the file is rotated, 0 rotates daily only.
+	MaxSize int64
+	// MaxAge is the time the rotated files are kept, 0 keeps them regardless of age.
+	MaxAge time.Duration
+	// MaxTotalSize is the disk budget in bytes of the active and rotated files, the oldest rotated
+	// files are removed beyond it, 0 is unlimited.
+	MaxTotalSize int64
+	// Budget is a disk budget shared with other writers, e.g. of the files of the other levels.
+	Budget *DiskBudget
+	// Compress gzips the rotated files.
+	Compress bool
+	// Clock returns the current time, time.Now if nil.
+	Clock func() time.Time
+}
+
+// RotateWriter is a zapcore.WriteSyncer writing to a file, which is rotated daily and by size.
+// The rotated files are compressed and removed by retention in the background.
+type RotateWriter struct {
+	c   RotateConfig
+	dir string
+	// the name of the active file without the extension, and the extension
+	base, ext string
+
+	mu   sync.Mutex
+	file *os.File // nil if it failed to reopen, which is retried by the next write
+	size int64
+	done bool // closed by Close
+	day  string
+	last string // the time of the last backup, to make the names unique
+
+	mill   chan struct{}
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewRotateWriter opens, or creates, the file of c, and returns a RotateWriter of it.
+func NewRotateWriter(c RotateConfig) (*RotateWriter, error) {
+	if c.Clock == nil {
+		c.Clock = time.Now
+	}
+	ext := filepath.Ext(c.Filename)
+	w := &RotateWriter{
+		c:      c,
+		dir:    filepath.Dir(c.Filename),
+		base:   strings.TrimSuffix(filepath.Base(c.Filename), ext),
+		ext:    ext,
+		mill:   make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	if err := os.MkdirAll(w.dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	if c.Budget != nil {
+		c.Budget.add(w)
+	}
+	w.wg.Add(1)
+	go w.runMill()
+	// compress or remove the files left by a previous run
+	w.triggerMill()
+	return w, nil
+}
+
+func (w *RotateWriter) open() error {
+	f, err := os.OpenFile(w.c.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	w.file, w.size = f, info.Size()
+	// a file written on a previous day is rotated on the first write
+	w.day = info.ModTime().In(w.c.Clock().Location()).Format(time.DateOnly)
+	if info.Size() == 0 {
+		w.day = w.c.Clock().Format(time.DateOnly)
+	}
+	return nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return 0, os.ErrClosed
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	now := w.c.Clock()
+	if w.size > 0 && (now.Format(time.DateOnly) != w.day || (w.c.MaxSize > 0 && w.size+int64(len(p)) > w.c.MaxSize)) {
+		if err := w.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file now.
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return os.ErrClosed
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	return w.rotate(w.c.Clock())
+}
+
+// rotate renames the file to a backup, and opens a new one. The file is left nil if it fails to
+// reopen, to be retried by the next write.
+func (w *RotateWriter) rotate(now time.Time) error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return err
+	}
+	stamp := now.Format(backupTimeFormat)
+	if stamp <= w.last {
+		// rotated twice in the same millisecond, or the clock went back
+		t, _ := time.ParseInLocation(backupTimeFormat, w.last, now.Location())
+		stamp = t.Add(time.Millisecond).Format(backupTimeFormat)
+	}
+	w.last = stamp
+	if err := os.Rename(w.c.Filename, filepath.Join(w.dir, w.base+"-"+stamp+w.ext)); err != nil {
+		// keep writing to the file, it is rotated by a later write
+		if oerr := w.open(); oerr != nil {
+			return errors.Join(err, oerr)
+		}
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.day = now.Format(time.DateOnly)
+	w.triggerMill()
+	return nil
+}
+
+func (w *RotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close closes the file, and waits for the background compression and removal.
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if !w.done {
+		w.done = true
+		if w.file != nil {
+			err = w.file.Close()
+			w.file = nil
+		}
+		close(w.closed)
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+	if w.c.Budget != nil {
+		w.c.Budget.remove(w)
+	}
+	return err
+}
+
+func (w *RotateWriter) triggerMill() {
+	select {
+	case w.mill <- struct{}{}:
+	default:
+	}
+}
+
+func (w *RotateWriter) runMill() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.mill:
+			w.millOnce()
+		case <-w.closed:
+			// finish the pending work
+			select {
+			case <-w.mill:
+				w.millOnce()
+			default:
+			}
+			return
+		}
+	}
+}
+
+type backup struct {
+	path string
+	time string
+	size int64
+}
+
+// millOnce compresses the rotated files, and removes them by age and total size, the errors
+// are printed as there is no logger to log them.
+func (w *RotateWriter) millOnce() {
+	if b := w.c.Budget; b != nil {
+		// the files of the budget are not compressed while they are removed
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		defer b.enforce()
+	}
+	backups, err := w.backups()
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		fmt.Printf("list rotated logs of %s: %v\n", w.c.Filename, err)
+		return
+	}
+	if w.c.Compress {
+		for i, b := range backups {
+			if strings.HasSuffix(b.path, ".gz") {
+				continue
+			}
+			size, err := gzipFile(b.path)
+			if err != nil {
+				fmt.Printf("compress rotated log %s: %v\n", b.path, err)
+				continue
+			}
+			backups[i].path, backups[i].size = b.path+".gz", size
+		}
+	}
+
+	remove := func(b backup) {
+		if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("remove rotated log %s: %v\n", b.path, err)
+		}
+	}
+	// the newest first
+	sort.Slice(backups, func(i, j int) bool { return backups[i].time > backups[j].time })
+	if w.c.MaxAge > 0 {
+		cutoff := w.c.Clock().Add(-w.c.MaxAge).Format(backupTimeFormat)
+		for len(backups) > 0 && backups[len(backups)-1].time < cutoff {
+			remove(backups[len(backups)-1])
+			backups = backups[:len(backups)-1]
+		}
+	}
+	if w.c.MaxTotalSize > 0 {
+		trim(backups, w.activeSize(), w.c.MaxTotalSize)
+	}
+}
+
+func (w *RotateWriter) activeSize() int64 {
+	if info, err := os.Stat(w.c.Filename); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+// trim removes the oldest of backups, sorted the newest first, beyond the budget of maxSize
+// bytes, total is the size of the active files.
+func trim(backups []backup, total, maxSize int64) {
+	for i, b := range backups {
+		total += b.size
+		if total > maxSize {
+			for _, old := range backups[i:] {
+				if err := os.Remove(old.path); err != nil && !os.IsNotExist(err) {
+					fmt.Printf("remove rotated log %s: %v\n", old.path, err)
+				}
+			}
+			return
+		}
+	}
+}
+
+// DiskBudget is the disk budget of the active and rotated files of the RotateWriters sharing
+// it, the oldest rotated files of them are removed beyond it.
+type DiskBudget struct {
+	maxSize int64
+
+	mu      sync.Mutex
+	writers map[*RotateWriter]struct{}
+}
+
+// NewDiskBudget returns a DiskBudget of maxSize bytes.
+func NewDiskBudget(maxSize int64) *DiskBudget {
+	return &DiskBudget{maxSize: maxSize, writers: map[*RotateWriter]struct{}{}}
+}
+
+func (b *DiskBudget) add(w *RotateWriter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writers[w] = struct{}{}
+}
+
+func (b *DiskBudget) remove(w *RotateWriter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.writers, w)
+}
+
+// enforce removes the oldest rotated files of the writers beyond the budget, b.mu is held.
+func (b *DiskBudget) enforce() {
+	var (
+		all   []backup
+		total int64
+	)
+	for w := range b.writers {
+		backups, err := w.backups()
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Printf("list rotated logs of %s: %v\n", w.c.Filename, err)
+			continue
+		}
+		all = append(all, backups...)
+		total += w.activeSize()
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].time > all[j].time })
+	trim(all, total, b.maxSize)
+}
+
+// backups returns the rotated files of w.
+func (w *RotateWriter) backups() ([]backup, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []backup
+	prefix := w.base + "-"
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".gz")
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, w.ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), w.ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(w.dir, e.Name()), time: stamp, size: info.Size()})
+	}
+	return backups, nil
+}
+
+// gzipFile compresses path to path.gz and removes it, it returns the compressed size.
+func gzipFile(path string) (int64, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return 0, err
+	}
+	info, err := os.Stat(path + ".gz")
+	if err != nil {
+		return 0, err
+	}
+	_ = src.Close()
+	return info.Size(), os.Remove(path)
+}
@@ -0,0 +1,237 @@
+package logx_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/logx"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotateWriter(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}
+	w, err := logx.NewRotateWriter(logx.RotateConfig{
+		Filename: filepath.Join(dir, "info.log"),
+		MaxSize:  10,
+		Compress: true,
+		Clock:    clock.Now,
+	})
+	assert.Nil(t, err)
+
+	// rotated by size
+	_, _ = w.Write([]byte("0123456\n"))
+	_, _ = w.Write([]byte("abc\n"))
+	clock.Add(time.Second)
+	// rotated by day
+	clock.Add(24 * time.Hour)
+	_, _ = w.Write([]byte("next day\n"))
+	assert.Nil(t, w.Close())
+
+	assert.Equal(t, []string{
+		"info-20240102T100000.000.log.gz",
+		"info-20240103T100001.000.log.gz",
+		"info.log",
+	}, listDir(t, dir))
+	assert.Equal(t, "0123456\n", readGzip(t, filepath.Join(dir, "info-20240102T100000.000.log.gz")))
+	assert.Equal(t, "abc\n", readGzip(t, filepath.Join(dir, "info-20240103T100001.000.log.gz")))
+	bs, _ := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.Equal(t, "next day\n", string(bs))
+
+	// a file of a previous day is rotated on the first write after restart
+	clock.Add(24 * time.Hour)
+	assert.Nil(t, os.Chtimes(filepath.Join(dir, "info.log"), clock.Now().Add(-24*time.Hour), clock.Now().Add(-24*time.Hour)))
+	w, err = logx.NewRotateWriter(logx.RotateConfig{Filename: filepath.Join(dir, "info.log"), Clock: clock.Now})
+	assert.Nil(t, err)
+	_, _ = w.Write([]byte("restarted\n"))
+	assert.Nil(t, w.Close())
+	assert.Len(t, listDir(t, dir), 4)
+}
+
+func TestRotateWriter_Retention(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}
+	w, err := logx.NewRotateWriter(logx.RotateConfig{
+		Filename:     filepath.Join(dir, "error.log"),
+		MaxSize:      100,
+		MaxTotalSize: 250,
+		MaxAge:       time.Hour,
+		Clock:        clock.Now,
+	})
+	assert.Nil(t, err)
+	line := []byte(strings.Repeat("x", 99) + "\n")
+	for i := 0; i < 5; i++ {
+		_, _ = w.Write(line)
+		clock.Add(time.Minute)
+	}
+	assert.Nil(t, w.Close())
+	// the active file and the newest backup fit the budget of 250 bytes
+	assert.Equal(t, []string{"error-20240102T100400.000.log", "error.log"}, listDir(t, dir))
+
+	// removed by age
+	clock.Add(2 * time.Hour)
+	w, err = logx.NewRotateWriter(logx.RotateConfig{Filename: filepath.Join(dir, "error.log"), MaxAge: time.Hour, Clock: clock.Now})
+	assert.Nil(t, err)
+	assert.Nil(t, w.Rotate())
+	assert.Nil(t, w.Close())
+	assert.Equal(t, []string{"error-20240102T120500.000.log", "error.log"}, listDir(t, dir))
+}
+
+func TestCombinedSizeRotator(t *testing.T) {
+	c := &logx.Zap{Level: "debug", Director: t.TempDir(), Rotator: logx.RotatorSize, Combined: true, MaxAge: 7}
+	logger := logx.NewLog(c)
+	logger.Debug("debug entry")
+	logger.Error("error entry")
+	assert.Equal(t, []string{"all.log"}, listDir(t, c.Director))
+	bs, _ := os.ReadFile(filepath.Join(c.Director, "all.log"))
+	assert.Contains(t, string(bs), "debug entry")
+	assert.Contains(t, string(bs), "error entry")
+
+	// MaxAge and the rotation count of the daily rotator do not conflict
+	c = &logx.Zap{Level: "info", Director: t.TempDir(), MaxAge: 7}
+	logx.NewLog(c).Info("info entry")
+	files, _ := filepath.Glob(filepath.Join(c.Director, "*", "info.log"))
+	if assert.Len(t, files, 1) {
+		bs, _ = os.ReadFile(files[0])
+		assert.Contains(t, string(bs), "info entry")
+	}
+}
+
+func TestRotateWriter_RenameFailed(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}
+	w, err := logx.NewRotateWriter(logx.RotateConfig{Filename: filepath.Join(dir, "info.log"), MaxSize: 10, Clock: clock.Now})
+	assert.Nil(t, err)
+	// the name of the backup is taken by a directory
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "info-20240102T100000.000.log", "x"), 0o755))
+
+	_, _ = w.Write([]byte("0123456\n"))
+	_, err = w.Write([]byte("abc\n"))
+	assert.NotNil(t, err)
+	// the file is reopened, and rotated by the next write
+	clock.Add(time.Second)
+	_, err = w.Write([]byte("def\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	bs, _ := os.ReadFile(filepath.Join(dir, "info-20240102T100001.000.log"))
+	assert.Equal(t, "0123456\n", string(bs))
+	bs, _ = os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.Equal(t, "def\n", string(bs))
+}
+
+func TestRotateWriter_ReopenFailed(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}
+	w, err := logx.NewRotateWriter(logx.RotateConfig{Filename: filepath.Join(dir, "info.log"), MaxSize: 10, Clock: clock.Now})
+	assert.Nil(t, err)
+	_, err = w.Write([]byte("0123456\n"))
+	assert.Nil(t, err)
+
+	// neither renamed nor reopened
+	assert.Nil(t, os.RemoveAll(dir))
+	_, err = w.Write([]byte("abc\n"))
+	assert.NotNil(t, err)
+	_, err = w.Write([]byte("abc\n"))
+	assert.NotNil(t, err)
+	// the file is reopened by the next write
+	assert.Nil(t, os.MkdirAll(dir, 0o755))
+	_, err = w.Write([]byte("def\n"))
+	assert.Nil(t, err)
+	bs, _ := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.Equal(t, "def\n", string(bs))
+
+	// closed without a file
+	assert.Nil(t, os.RemoveAll(dir))
+	_, err = w.Write([]byte("0123456789\n"))
+	assert.NotNil(t, err)
+	assert.Nil(t, w.Close())
+	_, err = w.Write([]byte("ghi\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+	assert.Nil(t, w.Close())
+}
+
+func TestDiskBudget(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}
+	budget := logx.NewDiskBudget(450)
+	var writers []*logx.RotateWriter
+	for _, name := range []string{"info", "error"} {
+		w, err := logx.NewRotateWriter(logx.RotateConfig{Filename: filepath.Join(dir, name+".log"), MaxSize: 100, Budget: budget, Clock: clock.Now})
+		assert.Nil(t, err)
+		writers = append(writers, w)
+	}
+	line := []byte(strings.Repeat("x", 99) + "\n")
+	for i := 0; i < 3; i++ {
+		for _, w := range writers {
+			_, _ = w.Write(line)
+			clock.Add(time.Minute)
+		}
+	}
+	for _, w := range writers {
+		assert.Nil(t, w.Close())
+	}
+	// the active files and the 2 newest backups of both levels fit the budget of 450 bytes
+	assert.Equal(t, []string{
+		"error-20240102T100500.000.log",
+		"error.log",
+		"info-20240102T100400.000.log",
+		"info.log",
+	}, listDir(t, dir))
+}
+
+func TestLogger_Close(t *testing.T) {
+	c := &logx.Zap{Level: "info", Director: t.TempDir(), Rotator: logx.RotatorSize, Combined: true}
+	logger := logx.NewLog(c)
+	logger.Info("before close")
+	assert.Nil(t, logger.Close())
+	logger.Info("after close")
+	bs, _ := os.ReadFile(filepath.Join(c.Director, "all.log"))
+	assert.Contains(t, string(bs), "before close")
+	assert.NotContains(t, string(bs), "after close")
+}
+
+func readGzip(t *testing.T, path string) string {
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	assert.Nil(t, err)
+	bs, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	return string(bs)
+}
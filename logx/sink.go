@@ -0,0 +1,282 @@
+package logx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	SinkSyslog = "syslog" // RFC 5424 over udp or tcp
+	SinkHTTP   = "http"   // JSON batches posted to a URL
+	SinkKafka  = "kafka"  // messages written by a KafkaWriter
+)
+
+// SinkConfig configures a sink shipping the logs to a remote service.
+type SinkConfig struct {
+	Type  string `mapstructure:"type" yaml:"type"`   // SinkSyslog, SinkHTTP or SinkKafka
+	Level string `mapstructure:"level" yaml:"level"` // 最低级别，默认跟随日志级别
+	// Address is the address of the syslog server like udp://127.0.0.1:514 or
+	// tcp://127.0.0.1:601, or the URL of the HTTP sink
+	Address string `mapstructure:"address" yaml:"address"`
+	// AppName is the syslog app name, the program name by default
+	AppName string `mapstructure:"app-name" yaml:"app-name"`
+	// Facility is the syslog facility, local0 (16) if 0
+	Facility int `mapstructure:"facility" yaml:"facility"`
+	// Headers are added to the HTTP requests, e.g. Authorization
+	Headers map[string]string `mapstructure:"headers" yaml:"headers"`
+	// Format is the HTTP body, a JSON array of the entries by default, or "ndjson"
+	Format string `mapstructure:"format" yaml:"format"`
+	// Topic and Writer are the topic and the producer of the Kafka sink, the Writer is set in code
+	Topic  string      `mapstructure:"topic" yaml:"topic"`
+	Writer KafkaWriter `mapstructure:"-" yaml:"-"`
+
+	BufferSize    int `mapstructure:"buffer-size" yaml:"buffer-size"`       // 缓冲条数，默认1024，满则丢弃
+	BatchSize     int `mapstructure:"batch-size" yaml:"batch-size"`         // 每批条数，默认100
+	FlushInterval int `mapstructure:"flush-interval" yaml:"flush-interval"` // 秒，默认1秒
+	Timeout       int `mapstructure:"timeout" yaml:"timeout"`               // 每批发送超时，秒，默认5秒
+}
+
+func orDefault(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+// Record is an encoded log entry.
+type Record struct {
+	Level      zapcore.Level
+	Time       time.Time
+	LoggerName string
+	Data       []byte // the entry encoded as JSON, without line ending
+}
+
+// Shipper ships the batches of records of a Sink to a remote service.
+type Shipper interface {
+	Ship(ctx context.Context, records []Record) error
+	Close() error
+}
+
+// SinkStats are the counters of a Sink.
+type SinkStats struct {
+	Sent    uint64 // the records shipped
+	Dropped uint64 // the records dropped as the buffer was full, or the sink closed
+	Failed  uint64 // the records of the failed shipments
+}
+
+// Sink buffers records, and ships them in batches in the background. The buffer is bounded, the
+// records are dropped when it is full, so that logging never blocks on a slow service.
+type Sink struct {
+	shipper   Shipper
+	batchSize int
+	interval  time.Duration
+	timeout   time.Duration
+
+	queue   chan Record
+	flushes chan chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+
+	sent, dropped, failed atomic.Uint64
+}
+
+// NewSink returns a started Sink shipping by shipper, configured by the buffer, batch, interval
+// and timeout of c.
+func NewSink(shipper Shipper, c SinkConfig) *Sink {
+	s := &Sink{
+		shipper:   shipper,
+		batchSize: orDefault(c.BatchSize, 100),
+		interval:  time.Duration(orDefault(c.FlushInterval, 1)) * time.Second,
+		timeout:   time.Duration(orDefault(c.Timeout, 5)) * time.Second,
+		queue:     make(chan Record, orDefault(c.BufferSize, 1024)),
+		flushes:   make(chan chan struct{}),
+		closed:    make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// Stats returns the counters of s.
+func (s *Sink) Stats() SinkStats {
+	return SinkStats{Sent: s.sent.Load(), Dropped: s.dropped.Load(), Failed: s.failed.Load()}
+}
+
+// Enqueue buffers r, or drops it if the buffer is full or s is closed.
+func (s *Sink) Enqueue(r Record) {
+	select {
+	case <-s.closed:
+		s.dropped.Add(1)
+		return
+	default:
+	}
+	select {
+	case s.queue <- r:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Flush ships the buffered records, and waits for the shipment.
+func (s *Sink) Flush() {
+	done := make(chan struct{})
+	select {
+	case s.flushes <- done:
+		<-done
+	case <-s.closed:
+	}
+}
+
+// Close ships the buffered records, and closes the shipper. The records logged after are dropped.
+func (s *Sink) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.closed)
+		s.wg.Wait()
+		err = s.shipper.Close()
+	})
+	return err
+}
+
+func (s *Sink) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	batch := make([]Record, 0, s.batchSize)
+	ship := func() {
+		if len(batch) > 0 {
+			s.ship(batch)
+			batch = batch[:0]
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case r := <-s.queue:
+				if batch = append(batch, r); len(batch) >= s.batchSize {
+					ship()
+				}
+			default:
+				ship()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case r := <-s.queue:
+			if batch = append(batch, r); len(batch) >= s.batchSize {
+				ship()
+			}
+		case <-ticker.C:
+			ship()
+		case done := <-s.flushes:
+			drain()
+			close(done)
+		case <-s.closed:
+			drain()
+			return
+		}
+	}
+}
+
+// ship ships batch, the errors are printed as the logger can not log them.
+func (s *Sink) ship(batch []Record) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	if err := s.shipper.Ship(ctx, batch); err != nil {
+		s.failed.Add(uint64(len(batch)))
+		fmt.Printf("ship %d logs: %v\n", len(batch), err)
+		return
+	}
+	s.sent.Add(uint64(len(batch)))
+}
+
+// sinkCore encodes the entries to records of a sink.
+type sinkCore struct {
+	zapcore.LevelEnabler
+	enc  zapcore.Encoder
+	sink *Sink
+}
+
+// NewSinkCore returns a core encoding the entries enabled by enabler to the records of sink.
+func NewSinkCore(sink *Sink, enc zapcore.Encoder, enabler zapcore.LevelEnabler) zapcore.Core {
+	return &sinkCore{LevelEnabler: enabler, enc: enc, sink: sink}
+}
+
+func (c *sinkCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &sinkCore{LevelEnabler: c.LevelEnabler, enc: enc, sink: c.sink}
+}
+
+func (c *sinkCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *sinkCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	data := append([]byte(nil), bytes.TrimRight(buf.Bytes(), "\r\n")...)
+	buf.Free()
+	c.sink.Enqueue(Record{Level: ent.Level, Time: ent.Time, LoggerName: ent.LoggerName, Data: data})
+	return nil
+}
+
+func (c *sinkCore) Sync() error {
+	c.sink.Flush()
+	return nil
+}
+
+// newSink returns the sink of c, and its core.
+func (z *zapDef) newSink(c SinkConfig) (*Sink, zapcore.Core, error) {
+	var (
+		shipper Shipper
+		err     error
+	)
+	switch c.Type {
+	case SinkSyslog:
+		shipper, err = NewSyslogShipper(c.Address, c.AppName, c.Facility)
+	case SinkHTTP:
+		shipper, err = NewHTTPShipper(c.Address, c.Format, c.Headers)
+	case SinkKafka:
+		if c.Writer == nil {
+			return nil, nil, fmt.Errorf("kafka sink of topic %s has no writer", c.Topic)
+		}
+		shipper = NewKafkaShipper(c.Writer, c.Topic)
+	default:
+		return nil, nil, fmt.Errorf("unknown sink type %q", c.Type)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var enabler zapcore.LevelEnabler = zapcore.DebugLevel
+	if c.Level != "" {
+		if enabler, err = ParseLevel(c.Level); err != nil {
+			return nil, nil, err
+		}
+	}
+	encConf := z.GetEncoderConfig()
+	encConf.EncodeLevel = zapcore.LowercaseLevelEncoder
+	encConf.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	var enc zapcore.Encoder = zapcore.NewJSONEncoder(encConf)
+	if z.redactor != nil {
+		enc = NewRedactEncoder(enc, z.redactor)
+	}
+	sink := NewSink(shipper, c)
+	return sink, NewSinkCore(sink, enc, enabler), nil
+}
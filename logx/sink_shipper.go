@@ -0,0 +1,224 @@
+package logx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SyslogShipper ships records to a syslog server by RFC 5424, a message per datagram over udp,
+// and octet counting framed by RFC 6587 over tcp.
+type SyslogShipper struct {
+	network, addr string
+	facility      int
+	header        string // HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogShipper returns a SyslogShipper of the address like udp://127.0.0.1:514, the app name
+// defaults to the program name, and the facility 0 to local0.
+func NewSyslogShipper(address, appName string, facility int) (*SyslogShipper, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("syslog address %q needs the udp or tcp scheme", address)
+	}
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+	if facility <= 0 {
+		facility = 16
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "-"
+	}
+	return &SyslogShipper{
+		network:  u.Scheme,
+		addr:     u.Host,
+		facility: facility,
+		header:   host + " " + appName + " " + strconv.Itoa(os.Getpid()) + " - -",
+	}, nil
+}
+
+// syslogSeverity returns the syslog severity of l.
+func syslogSeverity(l zapcore.Level) int {
+	switch l {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.DPanicLevel:
+		return 2
+	case zapcore.PanicLevel:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (s *SyslogShipper) format(r Record) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s ", s.facility*8+syslogSeverity(r.Level),
+		r.Time.Format("2006-01-02T15:04:05.000000Z07:00"), s.header)
+	b.Write(r.Data)
+	return b.Bytes()
+}
+
+func (s *SyslogShipper) Ship(ctx context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.conn.SetWriteDeadline(deadline)
+	}
+	for _, r := range records {
+		msg := s.format(r)
+		if s.network == "tcp" {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			// redial on the next shipment
+			_ = s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SyslogShipper) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// HTTPShipper posts the batches of records to a URL, as a JSON array, or as newline delimited
+// JSON by the format "ndjson".
+type HTTPShipper struct {
+	URL     string
+	NDJSON  bool
+	Headers map[string]string
+	Client  *http.Client
+}
+
+func NewHTTPShipper(rawURL, format string, headers map[string]string) (*HTTPShipper, error) {
+	if _, err := url.ParseRequestURI(rawURL); err != nil {
+		return nil, err
+	}
+	return &HTTPShipper{URL: rawURL, NDJSON: format == "ndjson", Headers: headers, Client: http.DefaultClient}, nil
+}
+
+func (s *HTTPShipper) Ship(ctx context.Context, records []Record) error {
+	var body bytes.Buffer
+	contentType := "application/json"
+	if s.NDJSON {
+		contentType = "application/x-ndjson"
+		for _, r := range records {
+			body.Write(r.Data)
+			body.WriteByte('\n')
+		}
+	} else {
+		body.WriteByte('[')
+		for i, r := range records {
+			if i > 0 {
+				body.WriteByte(',')
+			}
+			body.Write(r.Data)
+		}
+		body.WriteByte(']')
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("post logs to %s: %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+func (s *HTTPShipper) Close() error {
+	return nil
+}
+
+// KafkaMessage is a message of a KafkaWriter.
+type KafkaMessage struct {
+	Topic string
+	Key   []byte
+	Value []byte
+	Time  time.Time
+}
+
+// KafkaWriter produces messages to Kafka, an adapter of a Kafka client like the Writer of
+// segmentio/kafka-go.
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...KafkaMessage) error
+}
+
+// KafkaShipper writes the records to a topic by a KafkaWriter, keyed by the logger name. The
+// writer is owned by the caller, and is not closed.
+type KafkaShipper struct {
+	writer KafkaWriter
+	topic  string
+}
+
+func NewKafkaShipper(writer KafkaWriter, topic string) *KafkaShipper {
+	return &KafkaShipper{writer: writer, topic: topic}
+}
+
+func (s *KafkaShipper) Ship(ctx context.Context, records []Record) error {
+	msgs := make([]KafkaMessage, len(records))
+	for i, r := range records {
+		msgs[i] = KafkaMessage{Topic: s.topic, Value: r.Data, Time: r.Time}
+		if r.LoggerName != "" {
+			msgs[i].Key = []byte(r.LoggerName)
+		}
+	}
+	return s.writer.WriteMessages(ctx, msgs...)
+}
+
+func (s *KafkaShipper) Close() error {
+	return nil
+}
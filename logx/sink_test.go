@@ -0,0 +1,214 @@
+package logx_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/logx"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+var syslogPattern = regexp.MustCompile(`^<(\d+)>1 \S+ \S+ myapp \d+ - - (\{.*\})$`)
+
+func TestSink_SyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pc.Close()
+
+	logger, _ := newTestLog(t, &logx.Zap{Sinks: []logx.SinkConfig{{
+		Type: logx.SinkSyslog, Address: "udp://" + pc.LocalAddr().String(), AppName: "myapp", Level: "info",
+	}}})
+	logger.Debug("skipped")
+	logger.Warnw("disk full", "free", 0)
+	assert.NoError(t, logger.Close())
+
+	buf := make([]byte, 2048)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	m := syslogPattern.FindStringSubmatch(string(buf[:n]))
+	if assert.NotNil(t, m, string(buf[:n])) {
+		assert.Equal(t, strconv.Itoa(16*8+4), m[1])
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(m[2]), &entry))
+		assert.Equal(t, "disk full", entry["msg"])
+		assert.Equal(t, "warn", entry["level"])
+		assert.EqualValues(t, 0, entry["free"])
+	}
+	assert.Equal(t, logx.SinkStats{Sent: 1}, logger.Sinks()[0].Stats())
+}
+
+func TestSink_SyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ln.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var msgs []string
+		r := bufio.NewReader(conn)
+		for {
+			// octet counting: MSG-LEN SP SYSLOG-MSG
+			size, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(size))
+			msg := make([]byte, n)
+			if _, err = io.ReadFull(r, msg); err != nil {
+				break
+			}
+			msgs = append(msgs, string(msg))
+		}
+		received <- msgs
+	}()
+
+	logger, _ := newTestLog(t, &logx.Zap{Sinks: []logx.SinkConfig{{
+		Type: logx.SinkSyslog, Address: "tcp://" + ln.Addr().String(), AppName: "myapp",
+	}}})
+	logger.Info("line 1\nline 2")
+	logger.Error("boom")
+	assert.NoError(t, logger.Close())
+
+	select {
+	case msgs := <-received:
+		if assert.Len(t, msgs, 2) {
+			assert.Equal(t, strconv.Itoa(16*8+6), syslogPattern.FindStringSubmatch(msgs[0])[1])
+			assert.Contains(t, msgs[0], `"msg":"line 1\nline 2"`)
+			assert.Equal(t, strconv.Itoa(16*8+3), syslogPattern.FindStringSubmatch(msgs[1])[1])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no syslog messages")
+	}
+}
+
+func TestSink_HTTP(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]map[string]any
+		fail    = true
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		if fail {
+			fail = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		batches = append(batches, batch)
+	}))
+	defer srv.Close()
+
+	logger, _ := newTestLog(t, &logx.Zap{Sinks: []logx.SinkConfig{{
+		Type: logx.SinkHTTP, Address: srv.URL, BatchSize: 2, Headers: map[string]string{"Authorization": "Bearer key"},
+	}}})
+	logger.Info("failed")
+	assert.NoError(t, logger.Sync())
+	for i := 0; i < 3; i++ {
+		logger.Module("db").Infof("entry %d", i)
+	}
+	assert.NoError(t, logger.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, batches, 2) {
+		assert.Len(t, batches[0], 2)
+		assert.Equal(t, "entry 2", batches[1][0]["msg"])
+		assert.Equal(t, "db", batches[1][0]["log"])
+	}
+	assert.Equal(t, logx.SinkStats{Sent: 3, Failed: 1}, logger.Sinks()[0].Stats())
+}
+
+type fakeKafka struct {
+	mu      sync.Mutex
+	msgs    []logx.KafkaMessage
+	writing chan struct{} // signaled by the blocked writes
+	release chan struct{} // blocks the writes until closed, if not nil
+}
+
+func (k *fakeKafka) WriteMessages(ctx context.Context, msgs ...logx.KafkaMessage) error {
+	if k.release != nil {
+		k.writing <- struct{}{}
+		select {
+		case <-k.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.msgs = append(k.msgs, msgs...)
+	return nil
+}
+
+func TestSink_Kafka(t *testing.T) {
+	kafka := &fakeKafka{}
+	logger, _ := newTestLog(t, &logx.Zap{Sinks: []logx.SinkConfig{{Type: logx.SinkKafka, Topic: "logs", Writer: kafka}}})
+	logger.Module("api").Info("hello")
+	assert.NoError(t, logger.Close())
+
+	if assert.Len(t, kafka.msgs, 1) {
+		assert.Equal(t, "logs", kafka.msgs[0].Topic)
+		assert.Equal(t, "api", string(kafka.msgs[0].Key))
+		assert.Contains(t, string(kafka.msgs[0].Value), `"msg":"hello"`)
+	}
+	// closed
+	logger.Info("dropped")
+	assert.Equal(t, logx.SinkStats{Sent: 1, Dropped: 1}, logger.Sinks()[0].Stats())
+}
+
+func TestSink_Dropped(t *testing.T) {
+	kafka := &fakeKafka{writing: make(chan struct{}, 4), release: make(chan struct{})}
+	sink := logx.NewSink(logx.NewKafkaShipper(kafka, "logs"), logx.SinkConfig{BufferSize: 2, BatchSize: 1})
+	core := logx.NewSinkCore(sink, zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"}), zapcore.InfoLevel)
+
+	entry := zapcore.Entry{Level: zapcore.InfoLevel, Message: "m"}
+	// the first is being shipped, the next 2 are buffered, and the last is dropped
+	assert.NoError(t, core.Write(entry, nil))
+	<-kafka.writing
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, core.Write(entry, nil))
+	}
+	assert.Equal(t, logx.SinkStats{Dropped: 1}, sink.Stats())
+	close(kafka.release)
+	assert.NoError(t, sink.Close())
+
+	assert.Equal(t, logx.SinkStats{Sent: 3, Dropped: 1}, sink.Stats())
+	assert.Len(t, kafka.msgs, 3)
+}
+
+func TestSink_Invalid(t *testing.T) {
+	logger, _ := newTestLog(t, &logx.Zap{Sinks: []logx.SinkConfig{
+		{Type: "carrier-pigeon"},
+		{Type: logx.SinkSyslog, Address: "http://127.0.0.1:514"},
+		{Type: logx.SinkKafka},
+	}})
+	assert.Empty(t, logger.Sinks())
+	assert.NoError(t, logger.Close())
+}
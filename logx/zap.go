@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"io"
 	"os"
 	"time"
 )
@@ -15,11 +16,21 @@ const LoggerKey = "zapLogger"
 
 type Logger struct {
 	*zap.SugaredLogger
+	skipped  *zap.SugaredLogger // SugaredLogger skipping the frame of the redacting methods
+	levels   *Levels
+	ids      ids // the ids bound by context
+	redactor *Redactor
+	sinks    []*Sink
+	files    []io.Closer // the writers of the log files
 }
 
 // NewLog 获取 zap.Logger
 func NewLog(c *Zap) *Logger {
-	zapObj = zapDef{c: c}
+	z := zapDef{c: c, redactor: c.Redact.redactor()}
+	if c.Rotator == RotatorSize && c.MaxDiskSize > 0 {
+		// 所有级别的文件共享磁盘预算
+		z.budget = NewDiskBudget(int64(c.MaxDiskSize) << 20)
+	}
 
 	// 如果日志文件夹没有，则创建
 	if ok, _ := PathExists(c.Director); !ok {
@@ -27,29 +38,116 @@ func NewLog(c *Zap) *Logger {
 		_ = os.Mkdir(c.Director, os.ModePerm)
 	}
 
-	cores := zapObj.GetZapCores()
-	logger := zap.New(zapcore.NewTee(cores...))
+	levels := newLevels(c.TransportLevel())
+	for name, s := range c.Modules {
+		l, err := ParseLevel(s)
+		if err != nil {
+			fmt.Printf("invalid level of log module %v: %v\n", name, err)
+			continue
+		}
+		levels.SetModuleLevel(name, l)
+	}
+	cores := z.GetZapCores()
+	var sinks []*Sink
+	for _, sc := range c.Sinks {
+		sink, core, err := z.newSink(sc)
+		if err != nil {
+			fmt.Printf("create %v log sink failed: %v\n", sc.Type, err)
+			continue
+		}
+		sinks = append(sinks, sink)
+		cores = append(cores, core)
+	}
+	core := zapcore.NewTee(cores...)
+	if s := c.Sampling; s != nil {
+		core = zapcore.NewSamplerWithOptions(core, s.tick(), s.Initial, s.Thereafter)
+	}
+	logger := zap.New(&levelCore{Core: core, enabler: levels.root})
 
 	if c.ShowLine {
 		logger = logger.WithOptions(zap.AddCaller())
 	}
-	return &Logger{SugaredLogger: logger.Sugar()}
+	return (&Logger{levels: levels, redactor: z.redactor, sinks: sinks, files: z.files}).derive(logger.Sugar())
+}
+
+// derive returns a Logger of s, with the levels, ids and redactor of l.
+func (l *Logger) derive(s *zap.SugaredLogger) *Logger {
+	return &Logger{
+		SugaredLogger: s,
+		skipped:       s.WithOptions(zap.AddCallerSkip(1)),
+		levels:        l.levels,
+		ids:           l.ids,
+		redactor:      l.redactor,
+		sinks:         l.sinks,
+		files:         l.files,
+	}
+}
+
+// Levels returns the levels of the logger and of its modules, nil if the logger is not built by
+// NewLog.
+func (l *Logger) Levels() *Levels {
+	return l.levels
+}
+
+// Sinks returns the sinks of the logger, in the order of the Sinks of Zap.
+func (l *Logger) Sinks() []*Sink {
+	return l.sinks
+}
+
+// Close closes the sinks of the logger after shipping their buffered logs, and the log files, the
+// logs are dropped after. It is called on shutdown, by the root logger only, as the loggers derived
+// by Module, With and the context share them.
+func (l *Logger) Close() error {
+	var err error
+	for _, s := range l.sinks {
+		if cerr := s.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for _, f := range l.files {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
 }
 
-var zapObj zapDef
+// Module returns the named logger of module, whose level can be set apart from the logger by
+// Levels().SetModuleLevel, or the Modules of Zap.
+func (l *Logger) Module(name string) *Logger {
+	if l.levels == nil {
+		return l.derive(l.Named(name))
+	}
+	m := l.levels.module(name)
+	logger := l.Desugar().Named(name).WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		if lc, ok := c.(*levelCore); ok {
+			c = lc.Core
+		}
+		return &levelCore{Core: c, enabler: m}
+	}))
+	return l.derive(logger.Sugar())
+}
 
 type zapDef struct {
-	c *Zap
+	c        *Zap
+	redactor *Redactor
+	budget   *DiskBudget // the disk budget shared by the files of RotatorSize
+	files    []io.Closer
 }
 
 // GetEncoder 获取 zapcore.Encoder
 func (z *zapDef) GetEncoder() zapcore.Encoder {
+	var enc zapcore.Encoder
 	switch z.c.Format {
 	case ZapFormatJson:
-		return zapcore.NewJSONEncoder(z.GetEncoderConfig())
+		enc = zapcore.NewJSONEncoder(z.GetEncoderConfig())
 	default:
-		return zapcore.NewConsoleEncoder(z.GetEncoderConfig())
+		enc = zapcore.NewConsoleEncoder(z.GetEncoderConfig())
+	}
+	if z.redactor != nil {
+		enc = NewRedactEncoder(enc, z.redactor)
 	}
+	return enc
 }
 
 // GetEncoderConfig 获取zapcore.EncoderConfig
@@ -72,11 +170,16 @@ func (z *zapDef) GetEncoderConfig() zapcore.EncoderConfig {
 
 // GetEncoderCore 获取Encoder的 zapcore.Core
 func (z *zapDef) GetEncoderCore(l zapcore.Level, level zap.LevelEnablerFunc) zapcore.Core {
-	writer, err := FileRotatelogs.GetWriteSyncer(z.c, l.String()) // 使用file-rotatelogs进行日志分割
+	return z.newCore(l.String(), level)
+}
+
+func (z *zapDef) newCore(name string, level zapcore.LevelEnabler) zapcore.Core {
+	writer, file, err := FileRotatelogs.newWriteSyncer(z.c, name, z.budget) // 日志分割
 	if err != nil {
 		fmt.Printf("Get Write Syncer Failed err:%v", err.Error())
 		return nil
 	}
+	z.files = append(z.files, file)
 	return zapcore.NewCore(z.GetEncoder(), writer, level)
 }
 
@@ -89,11 +192,21 @@ func (z *zapDef) CustomTimeEncoder(t time.Time, encoder zapcore.PrimitiveArrayEn
 	encoder.AppendString(prefix + t.Format("2006/01/02 15:04:05.000"))
 }
 
-// GetZapCores 根据配置文件的Level获取 []zapcore.Core
+// GetZapCores 获取每个级别的 []zapcore.Core，级别由 Levels 控制
 func (z *zapDef) GetZapCores() []zapcore.Core {
+	if z.c.Combined {
+		// 级别由 Levels 过滤
+		core := z.newCore("all", zapcore.DebugLevel)
+		if core == nil {
+			return nil
+		}
+		return []zapcore.Core{core}
+	}
 	cores := make([]zapcore.Core, 0, 7)
-	for level := z.c.TransportLevel(); level <= zapcore.FatalLevel; level++ {
-		cores = append(cores, z.GetEncoderCore(level, z.GetLevelPriority(level)))
+	for level := zapcore.DebugLevel; level <= zapcore.FatalLevel; level++ {
+		if core := z.GetEncoderCore(level, z.GetLevelPriority(level)); core != nil {
+			cores = append(cores, core)
+		}
 	}
 	return cores
 }
@@ -136,20 +249,21 @@ func (z *zapDef) GetLevelPriority(level zapcore.Level) zap.LevelEnablerFunc {
 	}
 }
 
-// NewContext 给指定的context添加字段
+// NewContext 给指定的context添加字段，是 IntoContext 的 gin 适配
 func (l *Logger) NewContext(ctx *gin.Context, fields ...any) {
-	ctx.Set(LoggerKey, l.WithContext(ctx).With(fields...))
+	logger := l.WithContext(ctx)
+	logger = logger.With(fields...)
+	if ctx.Request == nil {
+		ctx.Set(LoggerKey, logger)
+		return
+	}
+	ctx.Request = ctx.Request.WithContext(IntoContext(ctx.Request.Context(), logger))
 }
 
-// WithContext 从指定的context返回一个zap实例
+// WithContext 从指定的context返回一个zap实例，是 FromContextOr 的 gin 适配
 func (l *Logger) WithContext(ctx *gin.Context) *Logger {
 	if ctx == nil {
 		return l
 	}
-	zl, _ := ctx.Get(LoggerKey)
-	ctxLogger, ok := zl.(*zap.SugaredLogger)
-	if ok {
-		return &Logger{ctxLogger}
-	}
-	return l
+	return FromContextOr(ctx, l)
 }
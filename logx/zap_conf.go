@@ -3,6 +3,7 @@ package logx
 import (
 	"go.uber.org/zap/zapcore"
 	"strings"
+	"time"
 )
 
 const (
@@ -13,6 +14,9 @@ const (
 	ZapEncodeLevelLowerColor = "lowerColor"
 	ZapEncodeLevelCap        = "cap"
 	ZapEncodeLevelCapColor   = "capColor"
+
+	RotatorDaily = "daily" // file-rotatelogs，每天一个目录
+	RotatorSize  = "size"  // 按天和大小滚动，支持压缩和磁盘预算
 )
 
 type Zap struct {
@@ -22,9 +26,52 @@ type Zap struct {
 	Director      string `mapstructure:"director" yaml:"director"`             // 日志文件夹
 	EncodeLevel   string `mapstructure:"encode-level" yaml:"encode-level"`     // 编码级
 	StacktraceKey string `mapstructure:"stacktrace-key" yaml:"stacktrace-key"` // 栈名
-	MaxAge        int    `mapstructure:"max-age" yaml:"max-age"`               // 日志留存时间
+	MaxAge        int    `mapstructure:"max-age" yaml:"max-age"`               // 日志留存时间，天
 	ShowLine      bool   `mapstructure:"show-line" yaml:"show-line"`           // 显示行
 	LogInConsole  bool   `mapstructure:"log-in-console" yaml:"log-in-console"` // 输出控制台
+	// Rotator is the rotation backend, RotatorDaily by default
+	Rotator string `mapstructure:"rotator" yaml:"rotator"`
+	// RotationCount is the max number of files of RotatorDaily if MaxAge is 0, 15 by default
+	RotationCount int `mapstructure:"rotation-count" yaml:"rotation-count"`
+	// MaxSize is the size in MB after which RotatorSize rotates a file, 0 rotates daily only
+	MaxSize int `mapstructure:"max-size" yaml:"max-size"`
+	// MaxDiskSize is the disk budget in MB of the files of RotatorSize, shared by the files of all
+	// the levels, 0 is unlimited
+	MaxDiskSize int `mapstructure:"max-disk-size" yaml:"max-disk-size"`
+	// Compress gzips the rotated files of RotatorSize
+	Compress bool `mapstructure:"compress" yaml:"compress"`
+	// Combined writes all levels to one file named all.log, instead of a file per level
+	Combined bool `mapstructure:"combined" yaml:"combined"`
+	// Modules are the levels of the module loggers, by module name
+	Modules map[string]string `mapstructure:"modules" yaml:"modules"`
+	// Sampling limits the entries of the same level and message, nil to log all
+	Sampling *Sampling `mapstructure:"sampling" yaml:"sampling"`
+	// Redact masks the sensitive data of the messages and fields, nil to log them as is
+	Redact *Redact `mapstructure:"redact" yaml:"redact"`
+	// Sinks ship the logs to remote services besides the files, see Logger.Close
+	Sinks []SinkConfig `mapstructure:"sinks" yaml:"sinks"`
+}
+
+// Sampling logs the first Initial entries of the same level and message per Tick, and then
+// every Thereafter-th entry, 0 drops them all.
+type Sampling struct {
+	Initial    int `mapstructure:"initial" yaml:"initial"`
+	Thereafter int `mapstructure:"thereafter" yaml:"thereafter"`
+	Tick       int `mapstructure:"tick" yaml:"tick"` // 秒，默认1秒
+}
+
+func (s *Sampling) tick() time.Duration {
+	if s.Tick <= 0 {
+		return time.Second
+	}
+	return time.Duration(s.Tick) * time.Second
+}
+
+func (z *Zap) rotationCount() uint {
+	if z.RotationCount <= 0 {
+		return 15
+	}
+	return uint(z.RotationCount)
 }
 
 // ZapEncodeLevel 根据 EncodeLevel 返回 zapcore.LevelEncoder
@@ -54,7 +101,7 @@ func (z *Zap) TransportLevel() zapcore.Level {
 	case "warn":
 		return zapcore.WarnLevel
 	case "error":
-		return zapcore.WarnLevel
+		return zapcore.ErrorLevel
 	case "dpanic":
 		return zapcore.DPanicLevel
 	case "panic":
@@ -0,0 +1,152 @@
+package mailx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	NoDomainDotError = errors.New("domain needs a dot")
+	DisposableError  = errors.New("disposable domain")
+	NoMXError        = errors.New("domain accepts no mail")
+)
+
+// AddressError is an invalid address of a field of a mail.
+type AddressError struct {
+	Field   string // To, Cc or Bcc
+	Address string
+	Err     error
+}
+
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("invalid %s address %q: %v", e.Field, e.Address, e.Err)
+}
+
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+// ParseAddress parses an RFC 5322 address, with or without a display name, e.g.
+// "Bob <bob@example.com>". An internationalized domain is converted to punycode, and the domain
+// needs a dot, so that addresses like "root@localhost" are rejected.
+func ParseAddress(addr string) (*mail.Address, error) {
+	a, err := mail.ParseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	at := strings.LastIndexByte(a.Address, '@')
+	local, domain := a.Address[:at], a.Address[at+1:]
+	domain, err = idna.ToASCII(strings.ToLower(domain))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(strings.Trim(domain, "."), ".") {
+		return nil, NoDomainDotError
+	}
+	a.Address = local + "@" + domain
+	return a, nil
+}
+
+// ValidMailAddress reports whether addr is a valid address by ParseAddress.
+func ValidMailAddress(addr string) bool {
+	_, err := ParseAddress(addr)
+	return err == nil
+}
+
+// ValidatorOption configures a Validator.
+type ValidatorOption func(v *Validator)
+
+// WithDisposable blocks the addresses of the domains, and their sub domains.
+func WithDisposable(domains ...string) ValidatorOption {
+	return func(v *Validator) {
+		for _, d := range domains {
+			if d, err := idna.ToASCII(strings.ToLower(d)); err == nil {
+				v.disposable[d] = struct{}{}
+			}
+		}
+	}
+}
+
+// WithMXLookup checks that the domains accept mails by lookup, e.g. net.DefaultResolver.LookupMX.
+func WithMXLookup(lookup func(ctx context.Context, domain string) ([]*net.MX, error)) ValidatorOption {
+	return func(v *Validator) {
+		v.lookupMX = lookup
+	}
+}
+
+// Validator validates addresses beyond the syntax.
+type Validator struct {
+	disposable map[string]struct{}
+	lookupMX   func(ctx context.Context, domain string) ([]*net.MX, error)
+}
+
+func NewValidator(opts ...ValidatorOption) *Validator {
+	v := &Validator{disposable: map[string]struct{}{}}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Validate validates addr, and returns it parsed.
+func (v *Validator) Validate(ctx context.Context, addr string) (*mail.Address, error) {
+	a, err := ParseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	domain := a.Address[strings.LastIndexByte(a.Address, '@')+1:]
+	for d := domain; d != ""; {
+		if _, ok := v.disposable[d]; ok {
+			return nil, DisposableError
+		}
+		_, d, _ = strings.Cut(d, ".")
+	}
+	if v.lookupMX != nil {
+		mxs, err := v.lookupMX(ctx, domain)
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, NoMXError
+		}
+		if err != nil {
+			return nil, err
+		}
+		// a null MX, RFC 7505
+		if len(mxs) == 0 || (len(mxs) == 1 && mxs[0].Host == ".") {
+			return nil, NoMXError
+		}
+	}
+	return a, nil
+}
+
+// CheckParam checks param, and validates its To, Cc and Bcc addresses, the returned error joins
+// an AddressError of every bad address.
+func (v *Validator) CheckParam(ctx context.Context, param Param) error {
+	if err := param.checkContent(); err != nil {
+		return err
+	}
+	return param.checkAddresses(func(addr string) error {
+		_, err := v.Validate(ctx, addr)
+		return err
+	})
+}
+
+func (p Param) checkAddresses(validate func(addr string) error) error {
+	var errs []error
+	for _, f := range []struct {
+		name  string
+		addrs []string
+	}{{"To", p.To}, {"Cc", p.Cc}, {"Bcc", p.Bcc}} {
+		for _, addr := range f.addrs {
+			if err := validate(addr); err != nil {
+				errs = append(errs, &AddressError{Field: f.name, Address: addr, Err: err})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
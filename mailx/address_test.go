@@ -0,0 +1,62 @@
+package mailx_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/chain-products-org/goal/mailx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddress(t *testing.T) {
+	a, err := mailx.ParseAddress("张三 <user@例子.中国>")
+	assert.Nil(t, err)
+	assert.Equal(t, "张三", a.Name)
+	assert.Equal(t, "user@xn--fsqu00a.xn--fiqs8s", a.Address)
+
+	_, err = mailx.ParseAddress("root@localhost")
+	assert.ErrorIs(t, err, mailx.NoDomainDotError)
+}
+
+func TestValidator(t *testing.T) {
+	ctx := context.Background()
+	v := mailx.NewValidator(
+		mailx.WithDisposable("mailinator.com"),
+		mailx.WithMXLookup(func(ctx context.Context, domain string) ([]*net.MX, error) {
+			switch domain {
+			case "nomail.com":
+				return []*net.MX{{Host: ".", Pref: 0}}, nil
+			case "missing.com":
+				return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+			}
+			return []*net.MX{{Host: "mx." + domain, Pref: 10}}, nil
+		}),
+	)
+
+	_, err := v.Validate(ctx, "bob@test.com")
+	assert.Nil(t, err)
+	_, err = v.Validate(ctx, "bob@eu.mailinator.com")
+	assert.ErrorIs(t, err, mailx.DisposableError)
+	_, err = v.Validate(ctx, "bob@nomail.com")
+	assert.ErrorIs(t, err, mailx.NoMXError)
+	_, err = v.Validate(ctx, "bob@missing.com")
+	assert.ErrorIs(t, err, mailx.NoMXError)
+
+	err = v.CheckParam(ctx, mailx.Param{
+		To:      []string{"bob@test.com", "bad"},
+		Cc:      []string{"bob@mailinator.com"},
+		Bcc:     []string{"bob@nomail.com"},
+		Subject: "hi",
+		Body:    "hi",
+	})
+	assert.ErrorIs(t, err, mailx.DisposableError)
+	assert.ErrorIs(t, err, mailx.NoMXError)
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	assert.Len(t, errs, 3)
+	var addrErr *mailx.AddressError
+	assert.True(t, errors.As(errs[0], &addrErr))
+	assert.Equal(t, "To", addrErr.Field)
+	assert.Equal(t, "bad", addrErr.Address)
+}
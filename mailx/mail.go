@@ -10,24 +10,23 @@ import (
 	"github.com/chain-products-org/goal/redisx"
 	"io"
 	"net/http"
-	"regexp"
 	"time"
 
 	"gopkg.in/gomail.v2"
 )
 
 type Conf struct {
-	Server        string `yaml:"server"`
-	SupportDomain string `yaml:"supportDomain"`
+	Server        string `mapstructure:"server" yaml:"server"`
+	SupportDomain string `mapstructure:"supportDomain" yaml:"supportDomain"`
 }
 
 // Server 邮件服务配置
 type Server struct {
-	Host     string
-	Port     int
-	From     string
-	UserName string
-	Password string
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	From     string `mapstructure:"from"`
+	UserName string `mapstructure:"userName"`
+	Password string `mapstructure:"password" log:"secret"`
 }
 
 func (c Server) CheckValid() error {
@@ -49,27 +48,100 @@ type ISender interface {
 type Param struct {
 	To      []string
 	Subject string
-	Body    string
+	Body    string // HTML body
 	Cc      []string
 	Bcc     []string
+	// TextBody is the plain text body, sent as the alternative of Body if both are set.
+	TextBody string
+	// Headers are the custom headers, e.g. List-Unsubscribe.
+	Headers map[string]string
+	// Attachments are the attachments and inline images.
+	Attachments []Attachment
 }
 
+// Attachment is a file attached to a mail, read from Reader when the mail is sent.
+type Attachment struct {
+	Name   string
+	Reader io.Reader
+	// ContentType is detected from the extension of Name if empty.
+	ContentType string
+	// Inline embeds the file, e.g. an image referenced by <img src="cid:Name"> in the HTML body.
+	Inline bool
+}
+
+// CheckValid checks the content of the mail, and the syntax of its addresses, the returned error
+// joins an AddressError of every bad address.
 func (p Param) CheckValid() error {
+	if err := p.checkContent(); err != nil {
+		return err
+	}
+	return p.checkAddresses(func(addr string) error {
+		_, err := ParseAddress(addr)
+		return err
+	})
+}
+
+func (p Param) checkContent() error {
 	if p.To == nil || len(p.To) == 0 {
 		return errors.New("need send to address")
 	}
 	if p.Subject == "" {
 		return errors.New("need subject")
 	}
-	if p.Body == "" {
+	if p.Body == "" && p.TextBody == "" {
 		return errors.New("need body")
 	}
-	// if !ValidMailAddress(p.To) {
-	//	return errors.New("invalid to address")
-	// }
+	for _, a := range p.Attachments {
+		if a.Name == "" || a.Reader == nil {
+			return errors.New("need attachment name and reader")
+		}
+	}
 	return nil
 }
 
+// message builds the mail of param sent from from.
+func (p Param) message(from string) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", from)
+	m.SetHeader("To", p.To...)
+	if len(p.Cc) > 0 {
+		m.SetHeader("Cc", p.Cc...)
+	}
+	if len(p.Bcc) > 0 {
+		m.SetHeader("Bcc", p.Bcc...)
+	}
+	m.SetHeader("Subject", p.Subject)
+	for k, v := range p.Headers {
+		m.SetHeader(k, v)
+	}
+	switch {
+	case p.TextBody == "":
+		m.SetBody("text/html", p.Body)
+	case p.Body == "":
+		m.SetBody("text/plain", p.TextBody)
+	default:
+		// the last part is preferred by the clients
+		m.SetBody("text/plain", p.TextBody)
+		m.AddAlternative("text/html", p.Body)
+	}
+	for _, a := range p.Attachments {
+		r := a.Reader
+		settings := []gomail.FileSetting{gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := io.Copy(w, r)
+			return err
+		})}
+		if a.ContentType != "" {
+			settings = append(settings, gomail.SetHeader(map[string][]string{"Content-Type": {a.ContentType}}))
+		}
+		if a.Inline {
+			m.Embed(a.Name, settings...)
+		} else {
+			m.Attach(a.Name, settings...)
+		}
+	}
+	return m
+}
+
 func NewSender(log *logx.Logger, conf Conf) *Sender {
 	return &Sender{Log: log, conf: conf}
 }
@@ -90,13 +162,7 @@ func (s *Sender) Send(server Server, param Param) error {
 		return err
 	}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", server.UserName)
-	m.SetHeader("To", param.To...)
-	m.SetHeader("Cc", param.Cc...)
-	m.SetHeader("Bcc", param.Bcc...)
-	m.SetHeader("Subject", param.Subject)
-	m.SetBody("text/html", param.Body)
+	m := param.message(server.UserName)
 
 	d := gomail.NewDialer(server.Host, server.Port, server.UserName, server.Password)
 	// d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
@@ -106,12 +172,6 @@ func (s *Sender) Send(server Server, param Param) error {
 	return nil
 }
 
-var mailAddrRegex, _ = regexp.Compile("^(\\w|\\.|-)+@(\\w|-)+\\.(\\w|-)+(\\w|-|\\.)*$")
-
-func ValidMailAddress(addr string) bool {
-	return mailAddrRegex.Match([]byte(addr))
-}
-
 type SendReq struct {
 	Appid   string         `json:"appid"`
 	Code    string         `json:"code"`
@@ -120,6 +180,10 @@ type SendReq struct {
 	Params  map[string]any `json:"params"`
 }
 
+// SendVerifyCode sends a verify code to to through the mail gateway.
+//
+// Deprecated: it stores the code in plain text under the bare address, never limits the
+// attempts and swallows the errors, use otp.Service with an otp.Gateway instead.
 func (s *Sender) SendVerifyCode(redis redisx.Client, to string, Type string) {
 	verifyCode := random.Numeric(6)
 	s.Log.Infof("email: %v, verify code: %v", to, verifyCode)
@@ -24,9 +24,16 @@ func TestValidMailAddress(t *testing.T) {
 
 		{"t_123com", false},
 		{"t_123test.com", false},
-		{"t^123@test.com", false},
-		{"t*123@test.com", false},
+		// valid atext of RFC 5322
+		{"t^123@test.com", true},
+		{"t*123@test.com", true},
 		{"t@123@test.com", false},
+		{"Bob <bob@test.com>", true},
+		{"\"bob smith\"@test.com", true},
+		{"用户@例子.中国", true},
+		{"root@localhost", false},
+		{"bob@test..com", false},
+		{"bob <bob@test.com", false},
 	}
 	for _, tc := range cases {
 		if ValidMailAddress(tc.addr) != tc.valid {
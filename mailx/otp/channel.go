@@ -0,0 +1,115 @@
+package otp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/chain-products-org/goal/logx"
+	"github.com/chain-products-org/goal/mailx"
+)
+
+// Channel delivers the codes to the receivers.
+type Channel interface {
+	Deliver(ctx context.Context, to, code string) error
+}
+
+// ChannelFunc is a func Channel.
+type ChannelFunc func(ctx context.Context, to, code string) error
+
+func (f ChannelFunc) Deliver(ctx context.Context, to, code string) error {
+	return f(ctx, to, code)
+}
+
+// SMTP returns a Channel sending emails through sender and server, the body is rendered by body
+// from the code.
+func SMTP(sender mailx.ISender, server mailx.Server, subject string, body func(code string) string) Channel {
+	return ChannelFunc(func(ctx context.Context, to, code string) error {
+		return sender.Send(server, mailx.Param{To: []string{to}, Subject: subject, Body: body(code)})
+	})
+}
+
+// Gateway is a Channel sending emails through the HTTP mail gateway, a POST of mailx.SendReq to
+// URL/send, the code is passed as the "code" param of Template.
+type Gateway struct {
+	URL      string
+	Appid    string
+	Template string
+	Subject  string
+	// Client is http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// NewGateway returns a Gateway of the gateway at url, e.g. mailx.Conf.Server.
+func NewGateway(url, appid, template, subject string) *Gateway {
+	return &Gateway{URL: url, Appid: appid, Template: template, Subject: subject}
+}
+
+func (g *Gateway) Deliver(ctx context.Context, to, code string) error {
+	body, err := json.Marshal(&mailx.SendReq{
+		Appid:   g.Appid,
+		Code:    g.Template,
+		Subject: g.Subject,
+		To:      []string{to},
+		Params:  map[string]any{"code": code},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(g.URL, "/")+"/send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		bs, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("mail gateway: %s: %s", resp.Status, bs)
+	}
+	return nil
+}
+
+// LogSink is a Channel logging the codes instead of sending them, for tests and local
+// development, the last code of each receiver is kept.
+type LogSink struct {
+	// Log is logx.Default if nil.
+	Log *logx.Logger
+
+	mu    sync.Mutex
+	codes map[string]string
+}
+
+func (s *LogSink) Deliver(ctx context.Context, to, code string) error {
+	log := s.Log
+	if log == nil {
+		log = logx.Default
+	}
+	log.Infof("otp: code of %s: %s", to, code)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.codes == nil {
+		s.codes = map[string]string{}
+	}
+	s.codes[to] = code
+	return nil
+}
+
+// Last returns the last code delivered to to.
+func (s *LogSink) Last(to string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.codes[to]
+}
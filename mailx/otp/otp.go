@@ -0,0 +1,182 @@
+// Package otp sends one-time verification codes and verifies them.
+//
+//	svc := otp.New(rc, "signup", otp.NewGateway(conf.Server, "app", "email_verify_code_en", "Your Verify Code"))
+//	err := svc.Send(ctx, "user@mail.com")
+//	...
+//	err = svc.Verify(ctx, "user@mail.com", code)
+package otp
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/random"
+	"github.com/chain-products-org/goal/redisx"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	CooldownError    = errorx.Prefer429("verification code sent too frequently")
+	InvalidCodeError = errorx.Prefer400("invalid or expired verification code")
+	AttemptsError    = errorx.Prefer429("too many verification attempts")
+)
+
+var (
+	// Count an attempt, KEYS: code; ARGV: max attempts. Returns nil if there is no code, -1 if the
+	// attempts exceed max, otherwise the hash of the code.
+	attemptScript = redis.NewScript(`if redis.call("EXISTS", KEYS[1]) == 0 then
+    return false
+end
+local n = redis.call("HINCRBY", KEYS[1], "n", 1)
+if n > tonumber(ARGV[1]) then
+    redis.call("DEL", KEYS[1])
+    return -1
+end
+return redis.call("HGET", KEYS[1], "h")`)
+
+	// Delete the code if its hash is ARGV[1], KEYS: code
+	consumeScript = redis.NewScript(`if redis.call("HGET", KEYS[1], "h") == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// Option configures a Service.
+type Option func(s *Service)
+
+// WithLength sets the number of digits of the codes, 6 by default.
+func WithLength(n int) Option {
+	return func(s *Service) {
+		s.length = n
+	}
+}
+
+// WithTTL sets the time a code is valid, 10 minutes by default.
+func WithTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.ttl = ttl
+	}
+}
+
+// WithCooldown sets the min interval of sending codes to the same receiver, 1 minute by default.
+func WithCooldown(d time.Duration) Option {
+	return func(s *Service) {
+		s.cooldown = d
+	}
+}
+
+// WithMaxAttempts sets the max number of verifications of a code, after which it is revoked,
+// 5 by default.
+func WithMaxAttempts(n int) Option {
+	return func(s *Service) {
+		s.maxAttempts = n
+	}
+}
+
+// Service sends verification codes through a Channel, and verifies them. The codes are stored
+// hashed in redis under the keys of the namespace, so that the codes of different purposes,
+// e.g. signup and password reset, do not mix.
+type Service struct {
+	rc          redisx.Client
+	namespace   string
+	channel     Channel
+	length      int
+	ttl         time.Duration
+	cooldown    time.Duration
+	maxAttempts int
+}
+
+// New returns a Service of namespace, which delivers the codes through channel.
+func New(rc redisx.Client, namespace string, channel Channel, opts ...Option) *Service {
+	s := &Service{
+		rc:          rc,
+		namespace:   namespace,
+		channel:     channel,
+		length:      6,
+		ttl:         10 * time.Minute,
+		cooldown:    time.Minute,
+		maxAttempts: 5,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// keys returns the keys of the code and the cooldown of to, in the same slot.
+func (s *Service) keys(to string) (code, cooldown string) {
+	base := "otp:" + s.namespace + ":{" + to + "}"
+	return base + ":code", base + ":cooldown"
+}
+
+func (s *Service) hash(to, code string) string {
+	sum := sha256.Sum256([]byte(s.namespace + "\x00" + to + "\x00" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Send generates a code for to and delivers it, replacing the previous code. It returns
+// CooldownError if a code was sent to to within the cooldown.
+func (s *Service) Send(ctx context.Context, to string) error {
+	codeKey, cooldownKey := s.keys(to)
+	if s.cooldown > 0 {
+		ok, err := s.rc.SetNX(ctx, cooldownKey, 1, s.cooldown).Result()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return CooldownError
+		}
+	}
+	code := random.Numeric(s.length)
+	_, err := s.rc.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, codeKey)
+		p.HSet(ctx, codeKey, "h", s.hash(to, code), "n", 0)
+		p.PExpire(ctx, codeKey, s.ttl)
+		return nil
+	})
+	if err == nil {
+		err = s.channel.Deliver(ctx, to, code)
+	}
+	if err != nil {
+		// allow to send again at once
+		_ = s.rc.Del(ctx, cooldownKey).Err()
+		return err
+	}
+	return nil
+}
+
+// Verify verifies the code of to, and consumes it if valid. It returns InvalidCodeError if the
+// code is wrong or expired, and AttemptsError if it was verified too many times, after which a
+// new code must be sent.
+func (s *Service) Verify(ctx context.Context, to, code string) error {
+	codeKey, _ := s.keys(to)
+	v, err := attemptScript.Run(ctx, s.rc, []string{codeKey}, s.maxAttempts).Result()
+	if errors.Is(err, redis.Nil) {
+		return InvalidCodeError
+	}
+	if err != nil {
+		return err
+	}
+	h, ok := v.(string)
+	if !ok {
+		return AttemptsError
+	}
+	given := s.hash(to, code)
+	if subtle.ConstantTimeCompare([]byte(given), []byte(h)) != 1 {
+		return InvalidCodeError
+	}
+	n, err := consumeScript.Run(ctx, s.rc, []string{codeKey}, h).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// consumed by a concurrent verification, or replaced by a new code
+		return InvalidCodeError
+	}
+	return nil
+}
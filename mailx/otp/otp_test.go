@@ -0,0 +1,85 @@
+package otp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/mailx"
+	"github.com/chain-products-org/goal/mailx/otp"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	sink := &otp.LogSink{Log: testx.NewLog()}
+	svc := otp.New(testx.NewRedisCluster(), "signup", sink, otp.WithMaxAttempts(3))
+	to := "user@mail.com"
+
+	assert.ErrorIs(t, svc.Verify(ctx, to, "123456"), otp.InvalidCodeError)
+	assert.Nil(t, svc.Send(ctx, to))
+	assert.ErrorIs(t, svc.Send(ctx, to), otp.CooldownError)
+	code := sink.Last(to)
+	assert.Len(t, code, 6)
+
+	// the code is consumed
+	assert.ErrorIs(t, svc.Verify(ctx, "other@mail.com", code), otp.InvalidCodeError)
+	assert.Nil(t, svc.Verify(ctx, to, code))
+	assert.ErrorIs(t, svc.Verify(ctx, to, code), otp.InvalidCodeError)
+
+	// the code is revoked after max attempts
+	svc = otp.New(testx.NewMiniRedis(), "reset", sink, otp.WithMaxAttempts(2), otp.WithCooldown(0))
+	assert.Nil(t, svc.Send(ctx, to))
+	code = sink.Last(to)
+	wrong := "x" + code[1:]
+	assert.ErrorIs(t, svc.Verify(ctx, to, wrong), otp.InvalidCodeError)
+	assert.ErrorIs(t, svc.Verify(ctx, to, wrong), otp.InvalidCodeError)
+	assert.ErrorIs(t, svc.Verify(ctx, to, code), otp.AttemptsError)
+	assert.ErrorIs(t, svc.Verify(ctx, to, code), otp.InvalidCodeError)
+
+	// a failed delivery does not start the cooldown
+	failed := otp.New(testx.NewMiniRedis(), "signup", otp.ChannelFunc(func(ctx context.Context, to, code string) error {
+		return errors.New("down")
+	}))
+	assert.NotNil(t, failed.Send(ctx, to))
+	assert.NotErrorIs(t, failed.Send(ctx, to), otp.CooldownError)
+}
+
+func TestErrorCodes(t *testing.T) {
+	for err, code := range map[error]int{
+		otp.CooldownError:    http.StatusTooManyRequests,
+		otp.AttemptsError:    http.StatusTooManyRequests,
+		otp.InvalidCodeError: http.StatusBadRequest,
+	} {
+		var preferred *errorx.PreferredError
+		if assert.True(t, errors.As(err, &preferred), err.Error()) {
+			assert.Equal(t, code, preferred.Code(), err.Error())
+		}
+	}
+}
+
+func TestGateway(t *testing.T) {
+	var got mailx.SendReq
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/send" {
+			http.NotFound(w, r)
+			return
+		}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	g := otp.NewGateway(srv.URL, "app", "email_verify_code_en", "Your Verify Code")
+	assert.Nil(t, g.Deliver(context.Background(), "user@mail.com", "123456"))
+	assert.Equal(t, "app", got.Appid)
+	assert.Equal(t, []string{"user@mail.com"}, got.To)
+	assert.Equal(t, "123456", got.Params["code"])
+
+	g.URL = srv.URL + "/missing/"
+	assert.NotNil(t, g.Deliver(context.Background(), "user@mail.com", "123456"))
+}
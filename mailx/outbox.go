@@ -0,0 +1,467 @@
+package mailx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chain-products-org/goal/logx"
+	"github.com/chain-products-org/goal/queue"
+	"github.com/chain-products-org/goal/redisx"
+	"github.com/chain-products-org/goal/uuid"
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/gomail.v2"
+)
+
+var MailNotFoundError = errors.New("mail not found")
+
+// Move the due mails from the delayed set to the queue, KEYS: delayed, queue; ARGV: now, limit
+var promoteScript = redis.NewScript(`local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, v in ipairs(due) do
+    redis.call("ZREM", KEYS[1], v)
+    redis.call("LPUSH", KEYS[2], v)
+end
+return #due`)
+
+// Pop a mail from the queue and lease it until the deadline, KEYS: queue, processing;
+// ARGV: deadline
+var leaseScript = redis.NewScript(`local v = redis.call("RPOP", KEYS[1])
+if not v then
+    return false
+end
+redis.call("ZADD", KEYS[2], ARGV[1], v)
+return v`)
+
+// MailState is the delivery state of a mail in an Outbox.
+type MailState string
+
+const (
+	MailQueued   MailState = "queued"
+	MailRetrying MailState = "retrying"
+	MailSent     MailState = "sent"
+	MailDead     MailState = "dead"
+)
+
+// MailStatus is the delivery status of a mail in an Outbox.
+type MailStatus struct {
+	ID        string    `json:"id"`
+	State     MailState `json:"state"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// OutboxOption configures an Outbox.
+type OutboxOption func(o *Outbox)
+
+// WithWorkers sets the number of workers, each holding a SMTP connection, 4 by default.
+func WithWorkers(n int) OutboxOption {
+	return func(o *Outbox) {
+		o.workers = n
+	}
+}
+
+// WithMaxAttempts sets the max number of attempts to send a mail, after which it is moved to
+// the dead letters, 5 by default.
+func WithMaxAttempts(n int) OutboxOption {
+	return func(o *Outbox) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay before the retry of a mail failed attempts times, exponential from
+// 1 second up to 10 minutes by default.
+func WithBackoff(backoff func(attempts int) time.Duration) OutboxOption {
+	return func(o *Outbox) {
+		o.backoff = backoff
+	}
+}
+
+// WithStatusTTL sets the time the status of a mail is kept, 7 days by default.
+func WithStatusTTL(ttl time.Duration) OutboxOption {
+	return func(o *Outbox) {
+		o.statusTTL = ttl
+	}
+}
+
+// WithIdleTimeout sets the time after which an unused SMTP connection is closed, 30 seconds
+// by default.
+func WithIdleTimeout(d time.Duration) OutboxOption {
+	return func(o *Outbox) {
+		o.idleTimeout = d
+	}
+}
+
+// WithLeaseTimeout sets the time a worker has to send a mail, after which the mail is requeued
+// as the worker is taken as crashed, 5 minutes by default. It should be longer than a send.
+func WithLeaseTimeout(d time.Duration) OutboxOption {
+	return func(o *Outbox) {
+		o.lease = d
+	}
+}
+
+// WithPollInterval sets the interval of polling the queue when it is empty, 1 second by default.
+func WithPollInterval(d time.Duration) OutboxOption {
+	return func(o *Outbox) {
+		o.poll = d
+	}
+}
+
+// Outbox sends mails asynchronously. Mails are enqueued on a redis queue, and sent by a pool of
+// workers reusing their SMTP connections. A failed mail is retried with backoff, and moved to
+// the dead letters after the max attempts. A mail is leased by the worker sending it, and
+// requeued if the worker crashes before the lease times out, so it is sent at least once.
+type Outbox struct {
+	log    *logx.Logger
+	rc     redisx.Client
+	server Server
+	dialer *gomail.Dialer
+
+	queue   queue.Queue
+	queued  string
+	delayed string
+	leased  string
+	dead    string
+	status  string
+
+	workers     int
+	maxAttempts int
+	backoff     func(attempts int) time.Duration
+	statusTTL   time.Duration
+	idleTimeout time.Duration
+	lease       time.Duration
+	poll        time.Duration
+
+	start  sync.Once
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewOutbox returns an Outbox of name sending through server, call Start to run the workers.
+// The instances of the same name share the queue.
+func NewOutbox(log *logx.Logger, rc redisx.Client, name string, server Server, opts ...OutboxOption) *Outbox {
+	base := "mailx:outbox:" + redisx.HashTag(name)
+	o := &Outbox{
+		log:         log,
+		rc:          rc,
+		server:      server,
+		dialer:      gomail.NewDialer(server.Host, server.Port, server.UserName, server.Password),
+		queue:       redisx.NewQueue(rc, base+":queue"),
+		queued:      base + ":queue",
+		delayed:     base + ":delayed",
+		leased:      base + ":leased",
+		dead:        base + ":dead",
+		status:      base + ":status:",
+		workers:     4,
+		maxAttempts: 5,
+		backoff: func(attempts int) time.Duration {
+			d := time.Second << (attempts - 1)
+			if d <= 0 || d > 10*time.Minute {
+				d = 10 * time.Minute
+			}
+			return d
+		},
+		statusTTL:   7 * 24 * time.Hour,
+		idleTimeout: 30 * time.Second,
+		lease:       5 * time.Minute,
+		poll:        time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+type outboxAttachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType,omitempty"`
+	Inline      bool   `json:"inline,omitempty"`
+	Data        []byte `json:"data"`
+}
+
+type outboxMail struct {
+	ID          string             `json:"id"`
+	To          []string           `json:"to"`
+	Cc          []string           `json:"cc,omitempty"`
+	Bcc         []string           `json:"bcc,omitempty"`
+	Subject     string             `json:"subject"`
+	Body        string             `json:"body,omitempty"`
+	TextBody    string             `json:"textBody,omitempty"`
+	Headers     map[string]string  `json:"headers,omitempty"`
+	Attachments []outboxAttachment `json:"attachments,omitempty"`
+	Attempts    int                `json:"attempts"`
+}
+
+func (m *outboxMail) param() Param {
+	p := Param{
+		To:       m.To,
+		Subject:  m.Subject,
+		Body:     m.Body,
+		Cc:       m.Cc,
+		Bcc:      m.Bcc,
+		TextBody: m.TextBody,
+		Headers:  m.Headers,
+	}
+	for _, a := range m.Attachments {
+		p.Attachments = append(p.Attachments, Attachment{
+			Name:        a.Name,
+			Reader:      bytes.NewReader(a.Data),
+			ContentType: a.ContentType,
+			Inline:      a.Inline,
+		})
+	}
+	return p
+}
+
+// Enqueue enqueues the mail of param, the attachments are read at once, and returns the id of
+// the mail to query its status.
+func (o *Outbox) Enqueue(ctx context.Context, param Param) (string, error) {
+	if err := param.CheckValid(); err != nil {
+		return "", err
+	}
+	m := &outboxMail{
+		ID:       uuid.UUID32(),
+		To:       param.To,
+		Cc:       param.Cc,
+		Bcc:      param.Bcc,
+		Subject:  param.Subject,
+		Body:     param.Body,
+		TextBody: param.TextBody,
+		Headers:  param.Headers,
+	}
+	for _, a := range param.Attachments {
+		data, err := io.ReadAll(a.Reader)
+		if err != nil {
+			return "", fmt.Errorf("read attachment %s: %w", a.Name, err)
+		}
+		m.Attachments = append(m.Attachments, outboxAttachment{Name: a.Name, ContentType: a.ContentType, Inline: a.Inline, Data: data})
+	}
+	bs, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	if err = o.setStatus(ctx, o.rc, m, MailQueued, nil); err != nil {
+		return "", err
+	}
+	if err = o.queue.Push(string(bs)); err != nil {
+		return "", err
+	}
+	return m.ID, nil
+}
+
+// Status returns the status of the mail of id, or MailNotFoundError if it is unknown or
+// expired.
+func (o *Outbox) Status(ctx context.Context, id string) (*MailStatus, error) {
+	bs, err := o.rc.Get(ctx, o.status+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, MailNotFoundError
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st MailStatus
+	if err = json.Unmarshal(bs, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// RetryDead moves the dead letters back to the queue with their attempts reset, and returns
+// the number of them.
+func (o *Outbox) RetryDead(ctx context.Context) (int, error) {
+	n := 0
+	for {
+		v, err := o.rc.RPop(ctx, o.dead).Result()
+		if errors.Is(err, redis.Nil) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		var m outboxMail
+		if err = json.Unmarshal([]byte(v), &m); err != nil {
+			o.log.Errorf("outbox: drop malformed dead letter: %v", err)
+			continue
+		}
+		m.Attempts = 0
+		if err = o.requeue(ctx, &m); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+func (o *Outbox) requeue(ctx context.Context, m *outboxMail) error {
+	bs, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err = o.setStatus(ctx, o.rc, m, MailQueued, nil); err != nil {
+		return err
+	}
+	return o.queue.Push(string(bs))
+}
+
+func (o *Outbox) setStatus(ctx context.Context, c redis.Cmdable, m *outboxMail, state MailState, sendErr error) error {
+	st := MailStatus{ID: m.ID, State: state, Attempts: m.Attempts, UpdatedAt: time.Now()}
+	if sendErr != nil {
+		st.LastError = sendErr.Error()
+	}
+	bs, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return c.Set(ctx, o.status+m.ID, bs, o.statusTTL).Err()
+}
+
+// Start starts the workers, once.
+func (o *Outbox) Start() {
+	o.start.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		o.cancel = cancel
+		o.wg.Add(o.workers + 1)
+		go func() {
+			defer o.wg.Done()
+			o.schedule(ctx)
+		}()
+		for i := 0; i < o.workers; i++ {
+			go func() {
+				defer o.wg.Done()
+				o.work(ctx)
+			}()
+		}
+	})
+}
+
+// Close stops the workers after their current mails, and closes their connections.
+func (o *Outbox) Close() error {
+	if o.cancel != nil {
+		o.cancel()
+	}
+	o.wg.Wait()
+	return nil
+}
+
+// schedule moves the due retries, and the mails whose leases time out, to the queue.
+func (o *Outbox) schedule(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		err := promoteScript.Run(ctx, o.rc, []string{o.delayed, o.queued}, now, 100).Err()
+		if err != nil && ctx.Err() == nil {
+			o.log.Errorf("outbox: schedule retries: %v", err)
+		}
+		n, err := promoteScript.Run(ctx, o.rc, []string{o.leased, o.queued}, now, 100).Int()
+		if err != nil && ctx.Err() == nil {
+			o.log.Errorf("outbox: requeue timed out mails: %v", err)
+		}
+		if n > 0 {
+			o.log.Warnf("outbox: requeued %d mails whose leases timed out", n)
+		}
+		sleep(ctx, o.poll)
+	}
+}
+
+func (o *Outbox) work(ctx context.Context) {
+	w := &outboxWorker{Outbox: o}
+	defer w.close()
+	for ctx.Err() == nil {
+		deadline := strconv.FormatInt(time.Now().Add(o.lease).UnixMilli(), 10)
+		v, err := leaseScript.Run(ctx, o.rc, []string{o.queued, o.leased}, deadline).Text()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+				o.log.Errorf("outbox: pop mail: %v", err)
+			}
+			if time.Since(w.used) >= o.idleTimeout {
+				w.close()
+			}
+			sleep(ctx, o.poll)
+			continue
+		}
+		w.deliver(v)
+	}
+}
+
+type outboxWorker struct {
+	*Outbox
+	sc   gomail.SendCloser
+	used time.Time
+}
+
+func (w *outboxWorker) deliver(v string) {
+	// the mail is leased, finish it even if the outbox is closing
+	ctx := context.Background()
+	var m outboxMail
+	if err := json.Unmarshal([]byte(v), &m); err != nil {
+		w.log.Errorf("outbox: drop malformed mail: %v", err)
+		if err = w.rc.ZRem(ctx, w.leased, v).Err(); err != nil {
+			w.log.Errorf("outbox: release malformed mail: %v", err)
+		}
+		return
+	}
+	m.Attempts++
+	sendErr := w.send(m.param())
+	bs, err := json.Marshal(&m)
+	if err == nil {
+		// release the lease along with the next state, so that the mail is in one place
+		_, err = w.rc.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.ZRem(ctx, w.leased, v)
+			switch {
+			case sendErr == nil:
+				return w.setStatus(ctx, p, &m, MailSent, nil)
+			case m.Attempts >= w.maxAttempts:
+				w.log.Errorf("outbox: mail %s to %v dead after %d attempts: %v", m.ID, m.To, m.Attempts, sendErr)
+				p.LPush(ctx, w.dead, bs)
+				return w.setStatus(ctx, p, &m, MailDead, sendErr)
+			default:
+				w.log.Warnf("outbox: mail %s to %v failed %d attempts: %v", m.ID, m.To, m.Attempts, sendErr)
+				due := float64(time.Now().Add(w.backoff(m.Attempts)).UnixMilli())
+				p.ZAdd(ctx, w.delayed, redis.Z{Score: due, Member: bs})
+				return w.setStatus(ctx, p, &m, MailRetrying, sendErr)
+			}
+		})
+	}
+	if err != nil {
+		w.log.Errorf("outbox: update mail %s: %v", m.ID, err)
+	}
+}
+
+// send sends the mail of p through the connection of the worker, which is dialed if absent, and
+// dropped after an error as the session state is unknown.
+func (w *outboxWorker) send(p Param) error {
+	if err := p.CheckValid(); err != nil {
+		return err
+	}
+	if w.sc == nil {
+		sc, err := w.dialer.Dial()
+		if err != nil {
+			return err
+		}
+		w.sc = sc
+	}
+	w.used = time.Now()
+	err := gomail.Send(w.sc, p.message(w.server.UserName))
+	if err != nil {
+		w.close()
+	}
+	return err
+}
+
+func (w *outboxWorker) close() {
+	if w.sc != nil {
+		_ = w.sc.Close()
+		w.sc = nil
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,91 @@
+package mailx_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/mailx"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	smtp := testx.NewSMTPServer()
+	defer smtp.Close()
+	server := mailx.Server{Host: smtp.Host(), Port: smtp.Port(), From: "noreply@mail.com", UserName: "noreply@mail.com", Password: "x"}
+	rc := testx.NewRedisCluster()
+	outbox := mailx.NewOutbox(testx.NewLog(), rc, "test", server,
+		mailx.WithWorkers(2),
+		mailx.WithMaxAttempts(2),
+		mailx.WithPollInterval(10*time.Millisecond),
+		mailx.WithBackoff(func(attempts int) time.Duration { return 20 * time.Millisecond }),
+	)
+	outbox.Start()
+	outbox.Start()
+	defer outbox.Close()
+
+	waitState := func(id string, state mailx.MailState) *mailx.MailStatus {
+		var st *mailx.MailStatus
+		assert.Eventually(t, func() bool {
+			var err error
+			st, err = outbox.Status(ctx, id)
+			return err == nil && st.State == state
+		}, 3*time.Second, 10*time.Millisecond, "%s: %+v", state, st)
+		return st
+	}
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id, err := outbox.Enqueue(ctx, mailx.Param{
+			To:          []string{"user@mail.com"},
+			Subject:     "hello",
+			TextBody:    "hi",
+			Attachments: []mailx.Attachment{{Name: "a.txt", Reader: strings.NewReader("attached")}},
+		})
+		assert.Nil(t, err)
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		st := waitState(id, mailx.MailSent)
+		assert.Equal(t, 1, st.Attempts)
+	}
+	assert.Len(t, smtp.Messages(), 5)
+	assert.Equal(t, []string{"user@mail.com"}, smtp.Messages()[0].To)
+	// the connections are reused
+	assert.LessOrEqual(t, smtp.Conns(), 2)
+
+	// retried once
+	smtp.FailNext(1)
+	id, err := outbox.Enqueue(ctx, mailx.Param{To: []string{"user@mail.com"}, Subject: "retry", Body: "<p>hi</p>"})
+	assert.Nil(t, err)
+	st := waitState(id, mailx.MailSent)
+	assert.Equal(t, 2, st.Attempts)
+
+	// dead after max attempts, and retried manually
+	smtp.FailNext(2)
+	id, err = outbox.Enqueue(ctx, mailx.Param{To: []string{"user@mail.com"}, Subject: "dead", Body: "<p>hi</p>"})
+	assert.Nil(t, err)
+	st = waitState(id, mailx.MailDead)
+	assert.Equal(t, 2, st.Attempts)
+	assert.Contains(t, st.LastError, "451")
+	n, err := outbox.RetryDead(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	waitState(id, mailx.MailSent)
+	assert.Len(t, smtp.Messages(), 7)
+
+	// a mail leased by a crashed worker is requeued after its lease times out
+	crashed := `{"id":"crashed","to":["user@mail.com"],"subject":"crashed","textBody":"hi","attempts":0}`
+	assert.Nil(t, rc.ZAdd(ctx, "mailx:outbox:{test}:leased", redis.Z{Score: 0, Member: crashed}).Err())
+	st = waitState("crashed", mailx.MailSent)
+	assert.Equal(t, 1, st.Attempts)
+	assert.Len(t, smtp.Messages(), 8)
+	assert.Equal(t, int64(0), rc.ZCard(ctx, "mailx:outbox:{test}:leased").Val())
+
+	_, err = outbox.Status(ctx, "missing")
+	assert.ErrorIs(t, err, mailx.MailNotFoundError)
+}
@@ -0,0 +1,273 @@
+package mailx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+
+	"gopkg.in/gomail.v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	layoutName   = "layout"
+	contentName  = "content"
+	subjectsFile = "subjects.yaml"
+)
+
+// Templates renders mails from the templates of a file system, usually an embed.FS:
+//
+//	layout.html      optional, wraps the html bodies by {{template "content" .}}
+//	layout.txt       optional, wraps the text bodies likewise
+//	welcome.html     html/template of the html body of the "welcome" mail
+//	welcome.txt      text/template of its text body, at least one of the bodies is required
+//	subjects.yaml    the subject templates of each mail by language:
+//	                 welcome:
+//	                   en: Welcome, {{.Name}}
+//	                   zh: 欢迎，{{.Name}}
+type Templates struct {
+	lang     string
+	html     map[string]*htmltemplate.Template
+	text     map[string]*texttemplate.Template
+	subjects map[string]map[string]*texttemplate.Template
+}
+
+// LoadTemplates loads the templates at the root of fsys, use fs.Sub for a sub directory. The
+// subjects fall back to lang if the language of a mail has none.
+func LoadTemplates(fsys fs.FS, lang string) (*Templates, error) {
+	t := &Templates{
+		lang:     lang,
+		html:     map[string]*htmltemplate.Template{},
+		text:     map[string]*texttemplate.Template{},
+		subjects: map[string]map[string]*texttemplate.Template{},
+	}
+	htmlLayout, err := readOptional(fsys, layoutName+".html")
+	if err != nil {
+		return nil, err
+	}
+	textLayout, err := readOptional(fsys, layoutName+".txt")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		name := strings.TrimSuffix(e.Name(), ext)
+		if e.IsDir() || name == layoutName || (ext != ".html" && ext != ".txt") {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if ext == ".html" {
+			tpl, err := parseWithLayout(htmltemplate.New(name), htmlLayout, string(content))
+			if err != nil {
+				return nil, fmt.Errorf("parse template %s: %w", e.Name(), err)
+			}
+			t.html[name] = tpl
+		} else {
+			tpl, err := parseWithLayout(texttemplate.New(name), textLayout, string(content))
+			if err != nil {
+				return nil, fmt.Errorf("parse template %s: %w", e.Name(), err)
+			}
+			t.text[name] = tpl
+		}
+	}
+
+	subjects, err := readOptional(fsys, subjectsFile)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]map[string]string
+	if err = yaml.Unmarshal([]byte(subjects), &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", subjectsFile, err)
+	}
+	for name, langs := range raw {
+		t.subjects[name] = map[string]*texttemplate.Template{}
+		for l, s := range langs {
+			tpl, err := texttemplate.New(name).Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("parse subject %s.%s: %w", name, l, err)
+			}
+			t.subjects[name][l] = tpl
+		}
+	}
+	return t, nil
+}
+
+// template is the common method set of html and text templates.
+type template[T any] interface {
+	*T
+	New(name string) *T
+	Parse(text string) (*T, error)
+}
+
+// parseWithLayout parses content as the "content" template of layout, or as the root template
+// if layout is empty.
+func parseWithLayout[T any, P template[T]](t P, layout, content string) (P, error) {
+	if layout == "" {
+		return t.Parse(content)
+	}
+	if _, err := t.Parse(layout); err != nil {
+		return nil, err
+	}
+	if _, err := P(t.New(contentName)).Parse(content); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func readOptional(fsys fs.FS, name string) (string, error) {
+	bs, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	return string(bs), err
+}
+
+// Content is a rendered mail.
+type Content struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Render renders the mail of name in lang with data.
+func (t *Templates) Render(name, lang string, data any) (Content, error) {
+	var c Content
+	html, hasHTML := t.html[name]
+	text, hasText := t.text[name]
+	if !hasHTML && !hasText {
+		return c, fmt.Errorf("mail template %s not found", name)
+	}
+	subject := t.subjects[name][lang]
+	if subject == nil {
+		subject = t.subjects[name][t.lang]
+	}
+	if subject == nil {
+		return c, fmt.Errorf("subject of mail template %s not found", name)
+	}
+
+	var buf bytes.Buffer
+	if err := subject.Execute(&buf, data); err != nil {
+		return c, err
+	}
+	c.Subject = buf.String()
+	if hasHTML {
+		buf.Reset()
+		if err := html.Execute(&buf, data); err != nil {
+			return c, err
+		}
+		c.HTML = buf.String()
+	}
+	if hasText {
+		buf.Reset()
+		if err := text.Execute(&buf, data); err != nil {
+			return c, err
+		}
+		c.Text = buf.String()
+	}
+	return c, nil
+}
+
+// Param renders the mail of name in lang with data, to be sent to to.
+func (t *Templates) Param(name, lang string, data any, to ...string) (Param, error) {
+	c, err := t.Render(name, lang, data)
+	if err != nil {
+		return Param{}, err
+	}
+	return Param{To: to, Subject: c.Subject, Body: c.HTML, TextBody: c.Text}, nil
+}
+
+// Recipient is a recipient of a batch, with its merge variables.
+type Recipient struct {
+	To   string
+	Lang string
+	Vars map[string]any
+}
+
+// Batch is a templated mail sent to each recipient separately.
+type Batch struct {
+	Template string
+	// Vars are the merge variables of all recipients, overridden by the ones of each recipient.
+	Vars        map[string]any
+	Recipients  []Recipient
+	Headers     map[string]string
+	Attachments []Attachment
+}
+
+// Params renders the mail of each recipient, the attachments are read once and shared.
+func (t *Templates) Params(b Batch) ([]Param, error) {
+	data := make([][]byte, len(b.Attachments))
+	for i, a := range b.Attachments {
+		bs, err := io.ReadAll(a.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("read attachment %s: %w", a.Name, err)
+		}
+		data[i] = bs
+	}
+	params := make([]Param, 0, len(b.Recipients))
+	for _, r := range b.Recipients {
+		vars := make(map[string]any, len(b.Vars)+len(r.Vars))
+		for k, v := range b.Vars {
+			vars[k] = v
+		}
+		for k, v := range r.Vars {
+			vars[k] = v
+		}
+		p, err := t.Param(b.Template, r.Lang, vars, r.To)
+		if err != nil {
+			return nil, fmt.Errorf("render mail to %s: %w", r.To, err)
+		}
+		p.Headers = b.Headers
+		for i, a := range b.Attachments {
+			a.Reader = bytes.NewReader(data[i])
+			p.Attachments = append(p.Attachments, a)
+		}
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+// SendBatch renders the mail of each recipient of b and sends them through one connection. The
+// errors of the recipients are joined, the mails of the others are still sent.
+func (s *Sender) SendBatch(server Server, t *Templates, b Batch) error {
+	if err := server.CheckValid(); err != nil {
+		return err
+	}
+	params, err := t.Params(b)
+	if err != nil {
+		return err
+	}
+	d := gomail.NewDialer(server.Host, server.Port, server.UserName, server.Password)
+	sc, err := d.Dial()
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+	return sendAll(sc, server.UserName, params)
+}
+
+func sendAll(sc gomail.Sender, from string, params []Param) error {
+	var errs []error
+	for _, p := range params {
+		if err := p.CheckValid(); err != nil {
+			errs = append(errs, fmt.Errorf("send mail to %v: %w", p.To, err))
+			continue
+		}
+		if err := gomail.Send(sc, p.message(from)); err != nil {
+			errs = append(errs, fmt.Errorf("send mail to %v: %w", p.To, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package mailx
+
+import (
+	"bytes"
+	"embed"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/gomail.v2"
+)
+
+//go:embed testdata/templates
+var templateFS embed.FS
+
+func loadTestTemplates(t *testing.T) *Templates {
+	sub, err := fs.Sub(templateFS, "testdata/templates")
+	assert.Nil(t, err)
+	tpl, err := LoadTemplates(sub, "en")
+	assert.Nil(t, err)
+	return tpl
+}
+
+func TestTemplates_Render(t *testing.T) {
+	tpl := loadTestTemplates(t)
+	data := map[string]any{"Name": "<Bob>", "App": "Goal"}
+
+	c, err := tpl.Render("welcome", "zh", data)
+	assert.Nil(t, err)
+	assert.Equal(t, "欢迎来到Goal，<Bob>", c.Subject)
+	assert.Equal(t, "<html><body><h1>Hi &lt;Bob&gt;</h1><img src=\"cid:logo.png\">\n<p>Team</p></body></html>\n", c.HTML)
+	assert.Equal(t, "Hi <Bob>, welcome to Goal.\n", c.Text)
+
+	// falls back to the default language
+	c, err = tpl.Render("welcome", "fr", data)
+	assert.Nil(t, err)
+	assert.Equal(t, "Welcome to Goal, <Bob>", c.Subject)
+
+	_, err = tpl.Render("missing", "en", data)
+	assert.NotNil(t, err)
+}
+
+func TestBatch(t *testing.T) {
+	tpl := loadTestTemplates(t)
+	params, err := tpl.Params(Batch{
+		Template: "welcome",
+		Vars:     map[string]any{"App": "Goal", "Name": "friend"},
+		Recipients: []Recipient{
+			{To: "a@mail.com", Vars: map[string]any{"Name": "A"}},
+			{To: "b@mail.com", Lang: "zh"},
+		},
+		Headers: map[string]string{"List-Unsubscribe": "<https://goal.dev/unsubscribe>"},
+		Attachments: []Attachment{
+			{Name: "logo.png", Reader: strings.NewReader("png"), Inline: true},
+			{Name: "terms.txt", Reader: strings.NewReader("terms")},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, params, 2)
+
+	var sent []string
+	sender := gomail.SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		var buf bytes.Buffer
+		_, err := msg.WriteTo(&buf)
+		sent = append(sent, buf.String())
+		return err
+	})
+	assert.Nil(t, sendAll(sender, "noreply@mail.com", params))
+	assert.Len(t, sent, 2)
+	for _, m := range sent {
+		assert.Contains(t, m, "List-Unsubscribe: <https://goal.dev/unsubscribe>")
+		assert.Contains(t, m, "multipart/alternative")
+		assert.Contains(t, m, "Content-ID: <logo.png>")
+		assert.Contains(t, m, `attachment; filename="terms.txt"`)
+		// base64 of "terms", every mail has the attachment
+		assert.Contains(t, m, "dGVybXM=")
+	}
+	assert.Contains(t, sent[0], "Hi A")
+	assert.Contains(t, sent[1], "Hi friend")
+	assert.Contains(t, sent[1], "=?UTF-8?")
+}
@@ -0,0 +1,67 @@
+package redisx
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/chain-products-org/goal/stringx"
+	"github.com/redis/go-redis/v9"
+)
+
+// Add a hit to the window and count the hits in it, KEYS: zset; ARGV: now ms, window ms, member
+var slidingWindowScript = redis.NewScript(`local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+if ARGV[3] ~= "" then
+    redis.call("ZADD", KEYS[1], now, ARGV[3])
+    redis.call("PEXPIRE", KEYS[1], window)
+end
+return redis.call("ZCARD", KEYS[1])`)
+
+// Counter counts the hits in a sliding time window, e.g. the retweets of the last hour, and the
+// unique items, e.g. the users who followed, approximately with a HyperLogLog.
+type Counter struct {
+	rc     Client
+	winKey string
+	hllKey string
+	window time.Duration
+}
+
+// NewCounter returns the counter of name with the sliding window, its keys are hash tagged by
+// name.
+func NewCounter(rc Client, name string, window time.Duration) *Counter {
+	key := HashTag(name)
+	return &Counter{rc: rc, winKey: key + ":window", hllKey: key + ":hll", window: window}
+}
+
+// Incr adds a hit, and returns the number of hits in the window.
+func (c *Counter) Incr(ctx context.Context) (int64, error) {
+	return c.slide(ctx, strconv.FormatInt(time.Now().UnixNano(), 36)+stringx.Randn(6))
+}
+
+// Count returns the number of hits in the window.
+func (c *Counter) Count(ctx context.Context) (int64, error) {
+	return c.slide(ctx, "")
+}
+
+func (c *Counter) slide(ctx context.Context, member string) (int64, error) {
+	return runScript(ctx, c.rc, slidingWindowScript, []string{c.winKey},
+		time.Now().UnixMilli(), c.window.Milliseconds(), member).Int64()
+}
+
+// AddUnique adds items to the unique count, and reports whether the count changed.
+func (c *Counter) AddUnique(ctx context.Context, items ...any) (bool, error) {
+	n, err := c.rc.PFAdd(ctx, c.hllKey, items...).Result()
+	return n == 1, err
+}
+
+// Unique returns the approximate number of unique items added, the standard error is 0.81%.
+func (c *Counter) Unique(ctx context.Context) (int64, error) {
+	return c.rc.PFCount(ctx, c.hllKey).Result()
+}
+
+// Reset removes the hits and the unique items.
+func (c *Counter) Reset(ctx context.Context) error {
+	return c.rc.Del(ctx, c.winKey, c.hllKey).Err()
+}
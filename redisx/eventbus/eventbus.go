@@ -0,0 +1,169 @@
+// Package eventbus publishes typed events to the subscribers of other services through redis.
+//
+//	bus := eventbus.New(eventbus.NewStreams(rc, "notifier"), logger)
+//	defer bus.Close()
+//
+//	var TweetPosted = eventbus.NewTopic[Tweet](bus, "tweet.posted")
+//
+//	_, err := eventbus.Subscribe(TweetPosted, func(ctx context.Context, t Tweet) error {
+//		return notify(ctx, t)
+//	})
+//	err = eventbus.Publish(ctx, TweetPosted, tweet)
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/chain-products-org/goal/errorx"
+	"github.com/chain-products-org/goal/logx"
+)
+
+var (
+	ClosedError   = errors.New("event bus closed")
+	PanickedError = errors.New("event handler panicked")
+)
+
+// Message is an encoded event delivered by a backend.
+type Message struct {
+	ID      string // empty for backends without ids
+	Topic   string
+	Payload []byte
+}
+
+// Backend transports the messages of topics.
+type Backend interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe calls handle for the messages of topic until the subscription is closed.
+	Subscribe(ctx context.Context, topic string, handle func(ctx context.Context, msg Message) error) (Subscription, error)
+}
+
+// Subscription is a subscription to a topic.
+type Subscription interface {
+	// Close stops receiving messages, and waits for the running handlers to return.
+	Close() error
+}
+
+// Bus is an event bus on a backend, it tracks the subscriptions to close them on shutdown.
+type Bus struct {
+	backend Backend
+	logger  *logx.Logger
+
+	mu     sync.Mutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+// New returns a Bus on backend, the errors and panics of handlers are logged by logger,
+// logx.Default if nil.
+func New(backend Backend, logger *logx.Logger) *Bus {
+	if logger == nil {
+		logger = logx.Default
+	}
+	return &Bus{backend: backend, logger: logger, subs: map[*subscription]struct{}{}}
+}
+
+// Close closes all subscriptions, and waits for their running handlers to return.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.subs = map[*subscription]struct{}{}
+	b.mu.Unlock()
+
+	var errs []error
+	for s := range subs {
+		errs = append(errs, s.Subscription.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Topic is a named topic of events of type T, which are encoded as JSON.
+type Topic[T any] struct {
+	bus  *Bus
+	name string
+}
+
+// NewTopic returns the topic of name on bus.
+func NewTopic[T any](bus *Bus, name string) *Topic[T] {
+	return &Topic[T]{bus: bus, name: name}
+}
+
+// Name returns the name of the topic.
+func (t *Topic[T]) Name() string {
+	return t.name
+}
+
+// Publish publishes event to the subscribers of topic.
+func Publish[T any](ctx context.Context, topic *Topic[T], event T) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event of %s: %w", topic.name, err)
+	}
+	return topic.bus.backend.Publish(ctx, topic.name, payload)
+}
+
+// Subscribe calls handler for the events of topic. Handlers are called one at a time per
+// subscription. If handler returns an error, or panics, it is logged, and durable backends
+// deliver the event again later.
+func Subscribe[T any](topic *Topic[T], handler func(ctx context.Context, event T) error) (Subscription, error) {
+	b := topic.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ClosedError
+	}
+	sub, err := b.backend.Subscribe(context.Background(), topic.name, func(ctx context.Context, msg Message) error {
+		var event T
+		if err := json.Unmarshal(msg.Payload, &event); err != nil {
+			// a malformed event is never handled successfully, so it is dropped
+			b.logger.Errorf("eventbus: drop malformed event %s of %s: %v", msg.ID, msg.Topic, err)
+			return nil
+		}
+		err := b.handle(ctx, msg, func(ctx context.Context) error {
+			return handler(ctx, event)
+		})
+		if err != nil {
+			b.logger.Errorf("eventbus: handle event %s of %s: %v", msg.ID, msg.Topic, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := &subscription{Subscription: sub, bus: b}
+	b.subs[s] = struct{}{}
+	return s, nil
+}
+
+// handle runs fn, a panic is recovered, logged with its stack, and returned as PanickedError.
+func (b *Bus) handle(ctx context.Context, msg Message, fn func(ctx context.Context) error) (err error) {
+	panicked := true
+	defer errorx.RecoverCtx(ctx, b.logger, func() {
+		if panicked {
+			err = fmt.Errorf("%w: event %s of %s", PanickedError, msg.ID, msg.Topic)
+		}
+	})
+	err = fn(ctx)
+	panicked = false
+	return err
+}
+
+type subscription struct {
+	Subscription
+	bus  *Bus
+	once sync.Once
+}
+
+func (s *subscription) Close() (err error) {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+		err = s.Subscription.Close()
+	})
+	return err
+}
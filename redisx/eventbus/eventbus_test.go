@@ -0,0 +1,151 @@
+package eventbus_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/redisx/eventbus"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/stretchr/testify/assert"
+)
+
+type Tweet struct {
+	ID   int64  `json:"id"`
+	Text string `json:"text"`
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	tweets []Tweet
+}
+
+func (r *recorder) add(t Tweet) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tweets = append(r.tweets, t)
+}
+
+func (r *recorder) ids() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]int64, len(r.tweets))
+	for i, t := range r.tweets {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+func TestPubSub(t *testing.T) {
+	rc := testx.NewMiniRedis()
+	bus := eventbus.New(eventbus.NewPubSub(rc, "events:"), testx.NewLog())
+	posted := eventbus.NewTopic[Tweet](bus, "tweet.posted")
+	ctx := context.Background()
+
+	rec := &recorder{}
+	_, err := eventbus.Subscribe(posted, func(ctx context.Context, tw Tweet) error {
+		if tw.ID == 2 {
+			panic("boom")
+		}
+		rec.add(tw)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	for i := int64(1); i <= 3; i++ {
+		assert.Nil(t, eventbus.Publish(ctx, posted, Tweet{ID: i, Text: "gm"}))
+	}
+	assert.Eventually(t, func() bool {
+		return len(rec.ids()) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int64{1, 3}, rec.ids(), "a panicking handler must not stop the subscription")
+
+	assert.Nil(t, bus.Close())
+	_, err = eventbus.Subscribe(posted, func(ctx context.Context, tw Tweet) error { return nil })
+	assert.ErrorIs(t, err, eventbus.ClosedError)
+}
+
+func TestStreams(t *testing.T) {
+	rc := testx.NewMiniRedis()
+	ctx := context.Background()
+	newBus := func() *eventbus.Bus {
+		backend := eventbus.NewStreams(rc, "notifier")
+		backend.Block = 50 * time.Millisecond
+		backend.MinIdle = 100 * time.Millisecond
+		return eventbus.New(backend, testx.NewLog())
+	}
+
+	bus := newBus()
+	confirmed := eventbus.NewTopic[Tweet](bus, "tx.confirmed")
+	rec := &recorder{}
+	failed := false
+	_, err := eventbus.Subscribe(confirmed, func(ctx context.Context, tw Tweet) error {
+		if tw.ID == 2 && !failed {
+			failed = true
+			return errors.New("temporary failure")
+		}
+		rec.add(tw)
+		return nil
+	})
+	assert.Nil(t, err)
+	for i := int64(1); i <= 3; i++ {
+		assert.Nil(t, eventbus.Publish(ctx, confirmed, Tweet{ID: i}))
+	}
+	assert.Eventually(t, func() bool {
+		return len(rec.ids()) == 3
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int64{1, 3, 2}, rec.ids(), "failed events must be delivered again")
+	assert.Nil(t, bus.Close())
+
+	// events published while no consumer runs are delivered when one subscribes
+	assert.Nil(t, eventbus.Publish(ctx, confirmed, Tweet{ID: 4}))
+	bus = newBus()
+	defer bus.Close()
+	rec2 := &recorder{}
+	_, err = eventbus.Subscribe(eventbus.NewTopic[Tweet](bus, "tx.confirmed"), func(ctx context.Context, tw Tweet) error {
+		rec2.add(tw)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return len(rec2.ids()) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int64{4}, rec2.ids())
+}
+
+func TestStreams_MaxDeliveries(t *testing.T) {
+	rc := testx.NewMiniRedis()
+	ctx := context.Background()
+	backend := eventbus.NewStreams(rc, "notifier")
+	backend.Block = 20 * time.Millisecond
+	backend.MinIdle = 50 * time.Millisecond
+	backend.MaxDeliveries = 2
+	bus := eventbus.New(backend, testx.NewLog())
+	defer bus.Close()
+
+	poison := eventbus.NewTopic[Tweet](bus, "tweet.poison")
+	var mu sync.Mutex
+	deliveries := 0
+	_, err := eventbus.Subscribe(poison, func(ctx context.Context, tw Tweet) error {
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries++
+		return errors.New("always fails")
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, eventbus.Publish(ctx, poison, Tweet{ID: 1}))
+
+	assert.Eventually(t, func() bool {
+		return rc.XLen(ctx, "tweet.poison:dead").Val() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(150 * time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, 2, deliveries)
+	mu.Unlock()
+	pending := rc.XPending(ctx, "tweet.poison", "notifier").Val()
+	assert.Equal(t, int64(0), pending.Count)
+	dead := rc.XRange(ctx, "tweet.poison:dead", "-", "+").Val()
+	assert.Equal(t, `{"id":1,"text":""}`, dead[0].Values["payload"])
+}
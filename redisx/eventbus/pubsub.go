@@ -0,0 +1,57 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/chain-products-org/goal/redisx"
+)
+
+// PubSub is a fire-and-forget backend on redis pub/sub, events published while a subscriber is
+// disconnected, or whose handler fails, are lost.
+type PubSub struct {
+	rc     redisx.Client
+	prefix string
+}
+
+// NewPubSub returns a PubSub backend, the channels are named prefix + topic.
+func NewPubSub(rc redisx.Client, prefix string) *PubSub {
+	return &PubSub{rc: rc, prefix: prefix}
+}
+
+func (p *PubSub) Publish(ctx context.Context, topic string, payload []byte) error {
+	return p.rc.Publish(ctx, p.prefix+topic, payload).Err()
+}
+
+func (p *PubSub) Subscribe(ctx context.Context, topic string, handle func(ctx context.Context, msg Message) error) (Subscription, error) {
+	ps := p.rc.Subscribe(ctx, p.prefix+topic)
+	// wait for the confirmation, so that no event published after Subscribe returns is missed
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &pubSubscription{close: ps.Close, cancel: cancel}
+	ch := ps.Channel()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for m := range ch {
+			_ = handle(ctx, Message{Topic: topic, Payload: []byte(m.Payload)})
+		}
+	}()
+	return s, nil
+}
+
+type pubSubscription struct {
+	wg     sync.WaitGroup
+	close  func() error
+	cancel context.CancelFunc
+}
+
+func (s *pubSubscription) Close() error {
+	err := s.close()
+	s.wg.Wait()
+	s.cancel()
+	return err
+}
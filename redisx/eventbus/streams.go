@@ -0,0 +1,249 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chain-products-org/goal/redisx"
+	"github.com/chain-products-org/goal/stringx"
+	"github.com/redis/go-redis/v9"
+)
+
+const payloadField = "payload"
+
+// Streams is a durable backend on redis streams. Every consumer group, e.g. a service, receives
+// each event once, which is handled by one of its consumers, e.g. replicas, and acknowledged if
+// the handler succeeds. Events whose handler failed, or whose consumer died, are claimed and
+// handled again after MinIdle, up to MaxDeliveries times.
+type Streams struct {
+	rc redisx.Client
+	// Prefix of the stream keys, the stream of a topic is named Prefix + topic.
+	Prefix string
+	// Group is the consumer group of the subscriptions.
+	Group string
+	// Consumer is the name of this consumer in the group, hostname-pid-random by default.
+	Consumer string
+	// MaxLen caps the streams approximately, 0 is unlimited.
+	MaxLen int64
+	// Block is the max time to wait for new events, which also bounds the time to close.
+	Block time.Duration
+	// MinIdle is the time after which pending events are claimed and handled again.
+	MinIdle time.Duration
+	// Batch is the max number of events read at a time.
+	Batch int64
+	// MaxDeliveries caps the deliveries of an event, after which it is moved to the dead letter
+	// stream of its topic, named by its stream and DeadLetterSuffix, and acknowledged, so that a
+	// poison event is not delivered forever. 0 is unlimited.
+	MaxDeliveries int64
+	// DeadLetterSuffix names the dead letter streams, the events over MaxDeliveries are only
+	// acknowledged if it is empty.
+	DeadLetterSuffix string
+}
+
+// NewStreams returns a Streams backend subscribing as group.
+func NewStreams(rc redisx.Client, group string) *Streams {
+	host, _ := os.Hostname()
+	return &Streams{
+		rc:       rc,
+		Group:    group,
+		Consumer: fmt.Sprintf("%s-%d-%s", host, os.Getpid(), stringx.Randn(6)),
+		MaxLen:   100000,
+		Block:    time.Second,
+		MinIdle:  time.Minute,
+		Batch:    16,
+
+		MaxDeliveries:    10,
+		DeadLetterSuffix: ":dead",
+	}
+}
+
+func (s *Streams) Publish(ctx context.Context, topic string, payload []byte) error {
+	return s.rc.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.Prefix + topic,
+		MaxLen: s.MaxLen,
+		Approx: s.MaxLen > 0,
+		Values: []any{payloadField, payload},
+	}).Err()
+}
+
+func (s *Streams) Subscribe(ctx context.Context, topic string, handle func(ctx context.Context, msg Message) error) (Subscription, error) {
+	stream := s.Prefix + topic
+	// start from the new events if the group does not exist yet
+	err := s.rc.XGroupCreateMkStream(ctx, stream, s.Group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &streamSubscription{cancel: cancel}
+	c := &consumer{Streams: s, stream: stream, topic: topic, handle: handle}
+	sub.wg.Add(1)
+	go func() {
+		defer sub.wg.Done()
+		c.run(ctx)
+	}()
+	return sub, nil
+}
+
+type consumer struct {
+	*Streams
+	stream string
+	topic  string
+	handle func(ctx context.Context, msg Message) error
+}
+
+func (c *consumer) run(ctx context.Context) {
+	// handle the events left pending by a previous run of this consumer first, if its name is
+	// stable across restarts, the others are claimed after MinIdle
+	c.claim(ctx, "0")
+	lastClaim := time.Now()
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= c.MinIdle {
+			c.claim(ctx, "0-0")
+			lastClaim = time.Now()
+		}
+		streams, err := c.rc.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.Group,
+			Consumer: c.Consumer,
+			Streams:  []string{c.stream, ">"},
+			Count:    c.Batch,
+			Block:    c.Block,
+		}).Result()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+				// e.g. redis is down, retry later
+				sleep(ctx, c.Block)
+			}
+			continue
+		}
+		for _, st := range streams {
+			c.handleAll(ctx, st.Messages)
+		}
+	}
+}
+
+// claim handles the pending events, of this consumer if start is "0", or of any consumer idle
+// for MinIdle if start is "0-0".
+func (c *consumer) claim(ctx context.Context, start string) {
+	for ctx.Err() == nil {
+		var (
+			msgs []redis.XMessage
+			next string
+			err  error
+		)
+		if start == "0" {
+			var streams []redis.XStream
+			streams, err = c.rc.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    c.Group,
+				Consumer: c.Consumer,
+				Streams:  []string{c.stream, "0"},
+				Count:    c.Batch,
+			}).Result()
+			if err == nil && len(streams) > 0 {
+				msgs = streams[0].Messages
+			}
+		} else {
+			msgs, next, err = c.rc.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   c.stream,
+				Group:    c.Group,
+				Consumer: c.Consumer,
+				MinIdle:  c.MinIdle,
+				Start:    start,
+				Count:    c.Batch,
+			}).Result()
+		}
+		if err != nil || len(msgs) == 0 {
+			return
+		}
+		c.handleAll(ctx, c.deadLetter(ctx, msgs))
+		if start == "0" {
+			// the failed events stay pending, they are retried after MinIdle
+			return
+		}
+		if next == "0-0" {
+			return
+		}
+		start = next
+	}
+}
+
+// deadLetter moves the claimed msgs delivered over MaxDeliveries times to the dead letter
+// stream, and returns the others. The msgs are all returned if their deliveries are unknown.
+func (c *consumer) deadLetter(ctx context.Context, msgs []redis.XMessage) []redis.XMessage {
+	if c.MaxDeliveries <= 0 {
+		return msgs
+	}
+	pending, err := c.rc.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   c.stream,
+		Group:    c.Group,
+		Start:    msgs[0].ID,
+		End:      msgs[len(msgs)-1].ID,
+		Count:    int64(len(msgs)),
+		Consumer: c.Consumer,
+	}).Result()
+	if err != nil {
+		return msgs
+	}
+	deliveries := make(map[string]int64, len(pending))
+	for _, p := range pending {
+		deliveries[p.ID] = p.RetryCount
+	}
+	left := msgs[:0:0]
+	for _, m := range msgs {
+		if deliveries[m.ID] <= c.MaxDeliveries {
+			left = append(left, m)
+			continue
+		}
+		if c.DeadLetterSuffix != "" {
+			err = c.rc.XAdd(ctx, &redis.XAddArgs{
+				Stream: c.stream + c.DeadLetterSuffix,
+				MaxLen: c.MaxLen,
+				Approx: c.MaxLen > 0,
+				Values: []any{payloadField, m.Values[payloadField], "id", m.ID, "group", c.Group},
+			}).Err()
+			if err != nil {
+				// keep it pending to dead letter it later
+				continue
+			}
+		}
+		_ = c.rc.XAck(ctx, c.stream, c.Group, m.ID).Err()
+	}
+	return left
+}
+
+// handleAll handles msgs until the subscription is closed, the handlers are not canceled by
+// closing, the msgs left are handled again later.
+func (c *consumer) handleAll(ctx context.Context, msgs []redis.XMessage) {
+	for _, m := range msgs {
+		if ctx.Err() != nil {
+			return
+		}
+		payload, _ := m.Values[payloadField].(string)
+		err := c.handle(context.Background(), Message{ID: m.ID, Topic: c.topic, Payload: []byte(payload)})
+		if err == nil {
+			_ = c.rc.XAck(context.Background(), c.stream, c.Group, m.ID).Err()
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+type streamSubscription struct {
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+func (s *streamSubscription) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,243 @@
+package redisx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LeaderboardExpiredError is returned by the writes to a board which has expired, like the board
+// of a period whose retention has ended, as the write would revive it.
+var LeaderboardExpiredError = errors.New("the leaderboard has expired")
+
+// Entry is a member of a leaderboard, Rank starts from 1 for the highest score.
+type Entry[M any] struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+	Rank   int64   `json:"rank"`
+	Meta   M       `json:"meta"`
+}
+
+// Leaderboard ranks members by score, highest first, in a sorted set. Each member may have
+// metadata of type M, e.g. the nickname and avatar, stored as JSON in a hash.
+type Leaderboard[M any] struct {
+	rc      Client
+	key     string
+	metaKey string
+	expires time.Time
+}
+
+// NewLeaderboard returns the leaderboard of name, its keys are hash tagged by name.
+func NewLeaderboard[M any](rc Client, name string) *Leaderboard[M] {
+	key := HashTag(name)
+	return &Leaderboard[M]{rc: rc, key: key, metaKey: key + ":meta"}
+}
+
+// Key returns the key of the sorted set.
+func (l *Leaderboard[M]) Key() string {
+	return l.key
+}
+
+// Incr increments the score of member by delta, and returns the new score.
+func (l *Leaderboard[M]) Incr(ctx context.Context, member string, delta float64) (float64, error) {
+	if l.expires.IsZero() {
+		return l.rc.ZIncrBy(ctx, l.key, delta, member).Result()
+	}
+	if !time.Now().Before(l.expires) {
+		return 0, LeaderboardExpiredError
+	}
+	var cmd *redis.FloatCmd
+	_, err := l.rc.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		cmd = p.ZIncrBy(ctx, l.key, delta, member)
+		p.ExpireAt(ctx, l.key, l.expires)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return cmd.Val(), nil
+}
+
+// SetScore sets the score of member.
+func (l *Leaderboard[M]) SetScore(ctx context.Context, member string, score float64) error {
+	if !l.expires.IsZero() && !time.Now().Before(l.expires) {
+		return LeaderboardExpiredError
+	}
+	_, err := l.rc.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.ZAdd(ctx, l.key, redis.Z{Score: score, Member: member})
+		if !l.expires.IsZero() {
+			p.ExpireAt(ctx, l.key, l.expires)
+		}
+		return nil
+	})
+	return err
+}
+
+// Score returns the score of member, or redis.Nil if it is not ranked.
+func (l *Leaderboard[M]) Score(ctx context.Context, member string) (float64, error) {
+	return l.rc.ZScore(ctx, l.key, member).Result()
+}
+
+// Rank returns the rank of member, starting from 1, or redis.Nil if it is not ranked.
+func (l *Leaderboard[M]) Rank(ctx context.Context, member string) (int64, error) {
+	rank, err := l.rc.ZRevRank(ctx, l.key, member).Result()
+	if err != nil {
+		return 0, err
+	}
+	return rank + 1, nil
+}
+
+// Len returns the number of ranked members.
+func (l *Leaderboard[M]) Len(ctx context.Context) (int64, error) {
+	return l.rc.ZCard(ctx, l.key).Result()
+}
+
+// Remove removes members from the leaderboard, their metadata is kept.
+func (l *Leaderboard[M]) Remove(ctx context.Context, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	ms := make([]any, len(members))
+	for i, m := range members {
+		ms[i] = m
+	}
+	return l.rc.ZRem(ctx, l.key, ms...).Err()
+}
+
+// SetMeta sets the metadata of member.
+func (l *Leaderboard[M]) SetMeta(ctx context.Context, member string, meta M) error {
+	bs, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return l.rc.HSet(ctx, l.metaKey, member, bs).Err()
+}
+
+// Top returns the page, starting from 1, of the members ranked highest, with their metadata.
+func (l *Leaderboard[M]) Top(ctx context.Context, page, size int) ([]Entry[M], error) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		return []Entry[M]{}, nil
+	}
+	start := int64((page - 1) * size)
+	return l.rangeByRank(ctx, start, start+int64(size)-1)
+}
+
+// Around returns member and the n members ranked right above and below it, with their
+// metadata, or redis.Nil if member is not ranked.
+func (l *Leaderboard[M]) Around(ctx context.Context, member string, n int) ([]Entry[M], error) {
+	rank, err := l.rc.ZRevRank(ctx, l.key, member).Result()
+	if err != nil {
+		return nil, err
+	}
+	start := rank - int64(n)
+	if start < 0 {
+		start = 0
+	}
+	return l.rangeByRank(ctx, start, rank+int64(n))
+}
+
+// rangeByRank returns the entries of the 0 based ranks from start to stop inclusive.
+func (l *Leaderboard[M]) rangeByRank(ctx context.Context, start, stop int64) ([]Entry[M], error) {
+	zs, err := l.rc.ZRevRangeWithScores(ctx, l.key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry[M], len(zs))
+	if len(zs) == 0 {
+		return entries, nil
+	}
+	members := make([]string, len(zs))
+	for i, z := range zs {
+		members[i] = fmt.Sprint(z.Member)
+		entries[i] = Entry[M]{Member: members[i], Score: z.Score, Rank: start + int64(i) + 1}
+	}
+	metas, err := l.rc.HMGet(ctx, l.metaKey, members...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, meta := range metas {
+		if s, ok := meta.(string); ok {
+			if err := json.Unmarshal([]byte(s), &entries[i].Meta); err != nil {
+				return nil, fmt.Errorf("decode meta of %s: %w", members[i], err)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// Period is the length of the buckets of a BucketedLeaderboard.
+type Period int
+
+const (
+	Daily Period = iota
+	Weekly
+)
+
+// bucket returns the id of the bucket of t, e.g. 20240102 for Daily and 2024W01 for Weekly,
+// and the time it ends.
+func (p Period) bucket(t time.Time) (string, time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if p == Weekly {
+		year, week := t.ISOWeek()
+		// ISO weeks start on monday
+		offset := (int(day.Weekday()) + 6) % 7
+		return fmt.Sprintf("%dW%02d", year, week), day.AddDate(0, 0, 7-offset)
+	}
+	return day.Format("20060102"), day.AddDate(0, 0, 1)
+}
+
+// BucketedLeaderboard is a leaderboard which rolls over every period, e.g. a daily ranking.
+// The board of each period expires retention periods after it ends. The metadata is shared by
+// all boards.
+type BucketedLeaderboard[M any] struct {
+	rc        Client
+	name      string
+	period    Period
+	retention int
+	loc       *time.Location
+}
+
+// NewBucketedLeaderboard returns the bucketed leaderboard of name, the buckets are in the
+// local time zone, see In.
+func NewBucketedLeaderboard[M any](rc Client, name string, period Period, retention int) *BucketedLeaderboard[M] {
+	return &BucketedLeaderboard[M]{rc: rc, name: name, period: period, retention: retention, loc: time.Local}
+}
+
+// In sets the time zone of the buckets.
+func (b *BucketedLeaderboard[M]) In(loc *time.Location) *BucketedLeaderboard[M] {
+	b.loc = loc
+	return b
+}
+
+// Current returns the board of the current period.
+func (b *BucketedLeaderboard[M]) Current() *Leaderboard[M] {
+	return b.At(time.Now())
+}
+
+// At returns the board of the period of t. The board expires at the same time whenever it is
+// written, and the writes after it expires fail with LeaderboardExpiredError.
+func (b *BucketedLeaderboard[M]) At(t time.Time) *Leaderboard[M] {
+	id, end := b.period.bucket(t.In(b.loc))
+	tag := HashTag(b.name)
+	expires := end.AddDate(0, 0, b.days()*b.retention)
+	return &Leaderboard[M]{rc: b.rc, key: tag + ":" + id, metaKey: tag + ":meta", expires: expires}
+}
+
+// Incr increments the score of member in the board of the current period.
+func (b *BucketedLeaderboard[M]) Incr(ctx context.Context, member string, delta float64) (float64, error) {
+	return b.Current().Incr(ctx, member, delta)
+}
+
+func (b *BucketedLeaderboard[M]) days() int {
+	if b.period == Weekly {
+		return 7
+	}
+	return 1
+}
@@ -0,0 +1,125 @@
+package redisx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/redisx"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type Profile struct {
+	Nickname string `json:"nickname"`
+}
+
+func TestLeaderboard(t *testing.T) {
+	rc := testx.NewRedisCluster()
+	ctx := context.Background()
+	lb := redisx.NewLeaderboard[Profile](rc, "mint")
+
+	for i, m := range []string{"a", "b", "c", "d", "e"} {
+		_, err := lb.Incr(ctx, m, float64(10*(i+1)))
+		assert.Nil(t, err)
+	}
+	score, err := lb.Incr(ctx, "a", 100)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(110), score)
+	assert.Nil(t, lb.SetMeta(ctx, "a", Profile{Nickname: "alice"}))
+
+	rank, err := lb.Rank(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), rank)
+	_, err = lb.Rank(ctx, "x")
+	assert.Equal(t, redis.Nil, err)
+
+	top, err := lb.Top(ctx, 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []redisx.Entry[Profile]{
+		{Member: "a", Score: 110, Rank: 1, Meta: Profile{Nickname: "alice"}},
+		{Member: "e", Score: 50, Rank: 2},
+	}, top)
+	top, err = lb.Top(ctx, 3, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(top))
+	assert.Equal(t, int64(5), top[0].Rank)
+
+	around, err := lb.Around(ctx, "d", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"e", "d", "c"}, members(around))
+	around, err = lb.Around(ctx, "a", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "e"}, members(around))
+
+	assert.Nil(t, lb.Remove(ctx, "a"))
+	n, _ := lb.Len(ctx)
+	assert.Equal(t, int64(4), n)
+}
+
+func TestBucketedLeaderboard(t *testing.T) {
+	rc := testx.NewMiniRedis()
+	ctx := context.Background()
+	lb := redisx.NewBucketedLeaderboard[Profile](rc, "retweet", redisx.Weekly, 1).In(time.UTC)
+
+	_, err := lb.Incr(ctx, "a", 1)
+	assert.Nil(t, err)
+	ttl := rc.TTL(ctx, lb.Current().Key()).Val()
+	assert.True(t, ttl > 7*24*time.Hour && ttl <= 14*24*time.Hour, ttl)
+
+	// 2024-01-01 is the monday of the first ISO week of 2024
+	sunday := time.Date(2024, 1, 7, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, "{retweet}:2024W01", lb.At(sunday).Key())
+	assert.Equal(t, "{retweet}:2024W02", lb.At(sunday.Add(time.Hour)).Key())
+
+	daily := redisx.NewBucketedLeaderboard[Profile](rc, "follow", redisx.Daily, 0).In(time.UTC)
+	assert.Equal(t, "{follow}:20240107", daily.At(sunday).Key())
+
+	// an ended board keeps its expiry, and is not revived after it expires
+	lastWeek := time.Now().AddDate(0, 0, -7)
+	_, err = lb.At(lastWeek).Incr(ctx, "a", 1)
+	assert.Nil(t, err)
+	ttl = rc.TTL(ctx, lb.At(lastWeek).Key()).Val()
+	assert.True(t, ttl > 0 && ttl <= 7*24*time.Hour, ttl)
+	yesterday := time.Now().AddDate(0, 0, -1)
+	_, err = daily.At(yesterday).Incr(ctx, "a", 1)
+	assert.True(t, errors.Is(err, redisx.LeaderboardExpiredError))
+	assert.True(t, errors.Is(daily.At(yesterday).SetScore(ctx, "a", 1), redisx.LeaderboardExpiredError))
+	assert.Equal(t, int64(0), rc.Exists(ctx, daily.At(yesterday).Key()).Val())
+}
+
+func TestCounter(t *testing.T) {
+	rc := testx.NewRedisCluster()
+	ctx := context.Background()
+	c := redisx.NewCounter(rc, "retweets", 200*time.Millisecond)
+
+	for i := 1; i <= 3; i++ {
+		n, err := c.Incr(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(i), n)
+	}
+	time.Sleep(250 * time.Millisecond)
+	n, err := c.Count(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n, "hits out of the window are not counted")
+
+	changed, err := c.AddUnique(ctx, "u1", "u2")
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	changed, _ = c.AddUnique(ctx, "u1")
+	assert.False(t, changed)
+	n, err = c.Unique(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Nil(t, c.Reset(ctx))
+}
+
+func members(es []redisx.Entry[Profile]) []string {
+	ms := make([]string, len(es))
+	for i, e := range es {
+		ms[i] = e.Member
+	}
+	return ms
+}
@@ -63,9 +63,9 @@ func (rl *Lock) AcquireWait() error {
 // AcquireCtx acquires the lock with the given ctx.
 func (rl *Lock) AcquireCtx(ctx context.Context) (bool, error) {
 	seconds := atomic.LoadUint32(&rl.seconds)
-	cmd := lockScript.Run(ctx, rl.store, []string{rl.key}, []string{
-		rl.id, strconv.Itoa(int(seconds)*1000 + tolerance),
-	})
+	cmd := runScript(ctx, rl.store, lockScript, []string{rl.key},
+		rl.id, strconv.Itoa(int(seconds)*1000+tolerance),
+	)
 	resp := cmd.Val()
 	err := cmd.Err()
 	if err == redis.Nil {
@@ -107,7 +107,7 @@ func (rl *Lock) Release() (bool, error) {
 
 // ReleaseCtx releases the lock with the given ctx.
 func (rl *Lock) ReleaseCtx(ctx context.Context) (bool, error) {
-	cmd := delScript.Run(ctx, rl.store, []string{rl.key}, []string{rl.id})
+	cmd := runScript(ctx, rl.store, delScript, []string{rl.key}, rl.id)
 	resp := cmd.Val()
 	err := cmd.Err()
 	if err != nil {
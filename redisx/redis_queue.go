@@ -2,8 +2,8 @@ package redisx
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/chain-products-org/goal/queue"
@@ -14,6 +14,58 @@ const (
 	maxRetry = 6
 )
 
+// QueueFullError is returned when pushing to a bounded queue that reaches its capacity.
+var QueueFullError = errors.New("queue reaches max capacity, can not push now")
+
+var (
+	// Push the values not in the set, KEYS: list, set; ARGV: cap (0 is unbounded), values...
+	// Returns the number of values pushed, or -1 if the queue is full or the new values exceed
+	// the cap, in which case nothing is pushed.
+	uniquePushScript = redis.NewScript(`local cap = tonumber(ARGV[1])
+if cap > 0 then
+    local len = redis.call("LLEN", KEYS[1])
+    if len >= cap then
+        return -1
+    end
+    local new, seen = 0, {}
+    for i = 2, #ARGV do
+        if not seen[ARGV[i]] and redis.call("SISMEMBER", KEYS[2], ARGV[i]) == 0 then
+            seen[ARGV[i]] = true
+            new = new + 1
+        end
+    end
+    if len + new > cap then
+        return -1
+    end
+end
+local n = 0
+for i = 2, #ARGV do
+    if redis.call("SADD", KEYS[2], ARGV[i]) == 1 then
+        redis.call("LPUSH", KEYS[1], ARGV[i])
+        n = n + 1
+    end
+end
+return n`)
+
+	// Pop values and remove them from the set, KEYS: list, set; ARGV: count
+	uniquePopScript = redis.NewScript(`local vs = redis.call("RPOP", KEYS[1], ARGV[1])
+if not vs then
+    return {}
+end
+for _, v in ipairs(vs) do
+    redis.call("SREM", KEYS[2], v)
+end
+return vs`)
+
+	// Push values if they fit in the queue, KEYS: list; ARGV: cap, values...
+	// Returns the length of the list, or -1 if the values exceed the cap, in which case nothing
+	// is pushed.
+	boundedPushScript = redis.NewScript(`if redis.call("LLEN", KEYS[1]) + #ARGV - 1 > tonumber(ARGV[1]) then
+    return -1
+end
+return redis.call("LPUSH", KEYS[1], unpack(ARGV, 2))`)
+)
+
 // ==============================
 // normal queue
 // ==============================
@@ -74,7 +126,7 @@ func NewUniqueQueue(rc Client, key string) queue.Queue {
 	// , only the content within the braces is used for hash calculation. This ensures
 	// that keys related to operations like queues and sets are mapped to the same
 	// slot, preventing errors. See: https://redis.io/docs/reference/cluster-spec/
-	key = "{" + key + "}"
+	key = HashTag(key)
 	setkey := key + "_set"
 	return &uniqueQueue{
 		queueImpl: NewQueue(rc, key).(*queueImpl),
@@ -101,129 +153,65 @@ func createSetWhenExists(rc Client, key string) (string, Set) {
 }
 
 type uniqueQueue struct {
-	mu sync.Mutex
 	*queueImpl
 
 	set    Set
 	setkey string
 }
 
-func (uq *uniqueQueue) retryPush(vs ...any) error {
-	ctx := context.TODO()
-	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-				fmt.Printf("error recovered: %v\n", err)
-			}
-		}()
-		times := 0
-		for {
-			cmd := uq.rc.LPush(ctx, uq.key, vs...)
-			if cmd.Err() == nil {
-				break
-			}
-			times++
-			if times > maxRetry {
-				fmt.Println(fmt.Errorf("ERROR: push failed after retry the max %d times", maxRetry))
-				break
-			}
-			fmt.Printf("ERROR: push failed, will retry at the %d time after %d second\n", times, 1<<times)
-			time.Sleep(time.Second * (1 << times))
-		}
-	}()
-	return nil
+func (uq *uniqueQueue) Push(vs ...any) error {
+	return uq.push(0, vs...)
 }
 
-func (uq *uniqueQueue) Push(vs ...any) error {
-	uq.mu.Lock()
-	defer uq.mu.Unlock()
-
-	// 去重
-	bs := uq.set.IsMembers(vs...)
-	var dest []any
-	for i, v := range vs {
-		if !bs[i] {
-			dest = append(dest, v)
-		}
-	}
-	if len(dest) == 0 {
+// push pushes the values not in the queue, if the queue has less than cap values, 0 is unbounded.
+// The check and the push run in a script, so the queue stays unique across processes.
+func (uq *uniqueQueue) push(cap uint64, vs ...any) error {
+	if len(vs) == 0 {
 		return nil
 	}
-
-	// use Pipliner to start transaction
-	ctx := context.TODO()
-	_, err := uq.queueImpl.rc.TxPipelined(ctx, func(pip redis.Pipeliner) error {
-		cmd := pip.LPush(ctx, uq.key, dest...)
-		if cmd.Err() != nil {
-			return cmd.Err()
-		}
-		return pip.SAdd(context.Background(), uq.setkey, dest...).Err()
-	})
-	return err
+	args := append([]any{cap}, vs...)
+	n, err := runScript(context.Background(), uq.rc, uniquePushScript, []string{uq.key, uq.setkey}, args...).Int64()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return QueueFullError
+	}
+	return nil
 }
 
 func (uq *uniqueQueue) Pop() (string, error) {
-	if s, err := uq.queueImpl.Pop(); err != nil {
+	vs, err := uq.PopN(1)
+	if err != nil {
 		return "", err
-	} else {
-		// if an error occured, retry to push s into the queue
-		if err := uq.set.Rem(s); err != nil {
-			fmt.Printf("rem [%v] from set error, will retry %d times to push to queue: %v\n", s, maxRetry, err)
-			return s, uq.retryPush(s)
-			// return s, err
-		}
-		return s, nil
 	}
+	if len(vs) == 0 {
+		return "", redis.Nil
+	}
+	return vs[0], nil
 }
 
 func (uq *uniqueQueue) PopN(n int) ([]string, error) {
-	//var vs []string
-	//var ctx = context.TODO()
-	//_, err := uq.queueImpl.rc.TxPipelined(ctx, func(pip redis.Pipeliner) error {
-	//	sscmd := pip.RPopCount(ctx, uq.key, n)
-	//	if sscmd.Err() != nil {
-	//		return sscmd.Err()
-	//	}
-	//	vs = sscmd.Val()
-	//	if len(vs) == 0 {
-	//		return nil
-	//	}
-	//	var ss = make([]any, len(vs))
-	//	for i, v := range vs {
-	//		ss[i] = v
-	//	}
-	//	return pip.SRem(ctx, uq.setkey, ss...).Err()
-	//})
-	//return vs, err
-	if vs, err := uq.queueImpl.PopN(n); err != nil {
-		return vs, err
-	} else {
-		if len(vs) == 0 {
-			return []string{}, nil
-		}
-		ss := make([]any, len(vs))
-		for i, v := range vs {
-			ss[i] = v
-		}
-		if err := uq.set.Rem(ss...); err != nil {
-			// fmt.Printf("rem [%v] from set error, will retry %d times to push to queue: %v\n", ss, maxRetry, err)
-			return vs, uq.retryPush(ss...)
-		}
-		return vs, nil
+	vs, err := runScript(context.Background(), uq.rc, uniquePopScript, []string{uq.key, uq.setkey}, n).StringSlice()
+	if err != nil {
+		return []string{}, err
 	}
+	return vs, nil
 }
 
+// BPop blocks until the queue is not empty, and pops a value along with its removal from the set
+// in a script like Pop. The wait is a BLMOVE of the list onto itself, which leaves the list as is,
+// and it waits again if another consumer pops the value first.
 func (uq *uniqueQueue) BPop() (string, error) {
-	if s, err := uq.queueImpl.BPop(); err != nil { // BPop will block if the queue is empty until popped a string
-		return "", err
-	} else {
-		// Failed to remove an element from the set, reinsert it into the queue
-		if err := uq.set.Rem(s); err != nil {
-			fmt.Printf("rem [%s] from set error, will retry %d times to push to queue: %v\n", s, maxRetry, err)
-			return s, uq.retryPush(s)
-			// return s, err
+	ctx := context.Background()
+	for {
+		if err := uq.rc.BLMove(ctx, uq.key, uq.key, "RIGHT", "RIGHT", 0).Err(); err != nil {
+			return "", err
+		}
+		s, err := uq.Pop()
+		if err != redis.Nil {
+			return s, err
 		}
-		return s, err
 	}
 }
 
@@ -248,11 +236,20 @@ func (bq *boundedQueue) Cap() uint64 {
 	return bq.cap
 }
 
+// Push pushes all the values, or none of them with QueueFullError if they exceed the cap.
 func (bq *boundedQueue) Push(vs ...any) error {
-	if bq.Len() >= bq.cap {
-		return fmt.Errorf("quene reachs max capacity, can not push now")
+	if len(vs) == 0 {
+		return nil
+	}
+	args := append([]any{bq.cap}, vs...)
+	n, err := runScript(context.Background(), bq.rc, boundedPushScript, []string{bq.key}, args...).Int64()
+	if err != nil {
+		return err
 	}
-	return bq.queueImpl.Push(vs...)
+	if n < 0 {
+		return QueueFullError
+	}
+	return nil
 }
 
 // ==============================
@@ -276,9 +273,8 @@ func (bq *boundedUniqueQueue) Cap() uint64 {
 	return bq.cap
 }
 
+// Push pushes the values not in the queue, or none of them with QueueFullError if the queue is
+// full or they exceed the cap.
 func (bq *boundedUniqueQueue) Push(vs ...any) error {
-	if bq.Len() >= bq.cap {
-		return fmt.Errorf("quene reachs max capacity, can not push now")
-	}
-	return bq.uniqueQueue.Push(vs...)
+	return bq.uniqueQueue.push(bq.cap, vs...)
 }
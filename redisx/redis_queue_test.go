@@ -153,6 +153,12 @@ func TestBoundedQueue(t *testing.T) {
 	assert.True(t, s == "key1")
 	err = q.Push("key1")
 	assert.True(t, err == nil) // 又可以继续添加了
+
+	// 多个值超出容量时，一个都不添加
+	q = redisx.NewBoundedQueue(rc, "test_bounded_queue_multi", 2)
+	assert.Nil(t, q.Push("key1"))
+	assert.ErrorIs(t, q.Push("key2", "key3"), redisx.QueueFullError)
+	assert.Equal(t, uint64(1), q.Len())
 }
 
 func TestBoundedUniqueQueue(t *testing.T) {
@@ -176,4 +182,38 @@ func TestBoundedUniqueQueue(t *testing.T) {
 	err = q.Push("key1")
 	assert.True(t, err == nil)
 	assert.True(t, q.Len() == 2)
+
+	// 新值超出容量时，一个都不添加，重复的值不占容量
+	q = redisx.NewBoundedUniqueQueue(rc, "test_unique_bounded_queue_multi", 3)
+	assert.Nil(t, q.Push("key1"))
+	assert.ErrorIs(t, q.Push("key2", "key3", "key4"), redisx.QueueFullError)
+	assert.Equal(t, uint64(1), q.Len())
+	assert.Nil(t, q.Push("key1", "key2", "key2", "key3"))
+	assert.Equal(t, uint64(3), q.Len())
+}
+
+func TestUniqueQueue_BPop(t *testing.T) {
+	rc := testx.NewMiniRedis().(*redis.Client)
+	q := redisx.NewUniqueQueue(rc, "test_uniqueue_bpop")
+	popped := make(chan string)
+	go func() {
+		s, err := q.BPop()
+		assert.Nil(t, err)
+		popped <- s
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, q.Push("key1"))
+	select {
+	case s := <-popped:
+		assert.Equal(t, "key1", s)
+	case <-time.After(time.Second):
+		t.Fatal("BPop is not woken by Push")
+	}
+	// 弹出的值已从集合中移除，可以再次添加
+	assert.Nil(t, q.Push("key1"))
+	assert.Equal(t, uint64(1), q.Len())
+	s, err := q.BPop()
+	assert.Nil(t, err)
+	assert.Equal(t, "key1", s)
+	assert.Equal(t, uint64(0), q.Len())
 }
@@ -0,0 +1,93 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SlotCount is the number of hash slots of a redis cluster.
+const SlotCount = 16384
+
+// CrossSlotError is returned when the keys of a script or transaction hash to different slots,
+// which a redis cluster rejects, or worse, partially applies.
+var CrossSlotError = errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+
+// Slot returns the cluster hash slot of key, only the hash tag of the key is hashed if present.
+// See https://redis.io/docs/reference/cluster-spec/#hash-tags
+func Slot(key string) int {
+	if tag, ok := hashTag(key); ok {
+		key = tag
+	}
+	return int(crc16(key) % SlotCount)
+}
+
+// SameSlot reports whether keys hash to the same cluster slot.
+func SameSlot(keys ...string) bool {
+	for i := 1; i < len(keys); i++ {
+		if Slot(keys[i]) != Slot(keys[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// HashTag wraps key in braces, "queue" becomes "{queue}", so that the keys derived from it by
+// appending suffixes, e.g. "{queue}_set", hash to the same slot. Keys which already have a hash
+// tag are returned as is.
+func HashTag(key string) string {
+	if _, ok := hashTag(key); ok {
+		return key
+	}
+	return "{" + key + "}"
+}
+
+// hashTag returns the content between the first '{' and the next '}', if not empty.
+func hashTag(key string) (string, bool) {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return "", false
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return "", false
+	}
+	return key[start+1 : start+1+end], true
+}
+
+// runScript runs a script after verifying its keys hash to the same slot.
+func runScript(ctx context.Context, rc redis.Scripter, script *redis.Script, keys []string, args ...any) *redis.Cmd {
+	if !SameSlot(keys...) {
+		cmd := redis.NewCmd(ctx)
+		cmd.SetErr(fmt.Errorf("%w: %v", CrossSlotError, keys))
+		return cmd
+	}
+	return script.Run(ctx, rc, keys, args...)
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum used by redis cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+var crc16Table = func() (t [256]uint16) {
+	for i := range t {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		t[i] = crc
+	}
+	return t
+}()
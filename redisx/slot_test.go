@@ -0,0 +1,68 @@
+package redisx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chain-products-org/goal/redisx"
+	"github.com/chain-products-org/goal/testx"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlot(t *testing.T) {
+	// crc16("123456789") = 0x31C3, see the cluster spec
+	assert.Equal(t, 0x31C3%redisx.SlotCount, redisx.Slot("123456789"))
+	assert.Equal(t, redisx.Slot("user1000"), redisx.Slot("{user1000}.following"))
+	assert.True(t, redisx.SameSlot("{user1000}.following", "{user1000}.followers"))
+	assert.Equal(t, redisx.Slot("foo{}{bar}"), redisx.Slot("foo{}{bar}"))
+	assert.NotEqual(t, redisx.Slot("bar"), redisx.Slot("foo{}{bar}"), "empty tag hashes the whole key")
+
+	assert.Equal(t, "{queue}", redisx.HashTag("queue"))
+	assert.Equal(t, "app:{queue}", redisx.HashTag("app:{queue}"))
+}
+
+func TestRedisCluster(t *testing.T) {
+	rc := testx.NewRedisCluster()
+	ctx := context.Background()
+	assert.False(t, redisx.SameSlot("a", "b"))
+
+	err := rc.Eval(ctx, "return 1", []string{"a", "b"}).Err()
+	assert.ErrorIs(t, err, redisx.CrossSlotError)
+	assert.ErrorIs(t, rc.MGet(ctx, "a", "b").Err(), redisx.CrossSlotError)
+	assert.Nil(t, rc.MGet(ctx, "{a}1", "{a}2").Err())
+}
+
+func TestClusterQueues(t *testing.T) {
+	rc := testx.NewRedisCluster()
+
+	uq := redisx.NewBoundedUniqueQueue(rc, "cluster_queue", 3)
+	assert.Nil(t, uq.Push("k1", "k2", "k1"))
+	assert.Equal(t, uint64(2), uq.Len())
+	assert.Nil(t, uq.Push("k2", "k3"))
+	assert.ErrorIs(t, uq.Push("k4"), redisx.QueueFullError)
+	vs, err := uq.PopN(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"k1", "k2"}, vs)
+	assert.Nil(t, uq.Push("k1"))
+	s, err := uq.Pop()
+	assert.Nil(t, err)
+	assert.Equal(t, "k3", s)
+	s, err = uq.Pop()
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", s)
+	_, err = uq.Pop()
+	assert.Equal(t, redis.Nil, err)
+
+	bq := redisx.NewBoundedQueue(rc, "cluster_bounded", 1)
+	assert.Nil(t, bq.Push("a"))
+	assert.ErrorIs(t, bq.Push("b"), redisx.QueueFullError)
+
+	lock := redisx.NewLock(rc, "cluster_lock", 2)
+	ok, err := lock.Acquire()
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	ok, err = lock.Release()
+	assert.True(t, ok)
+	assert.Nil(t, err)
+}
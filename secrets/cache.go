@@ -0,0 +1,158 @@
+package secrets
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Cache caches the secrets of a provider for a TTL, and notifies the subscribers of OnRotate when
+// a secret is changed, as found by a refetch after the TTL, Refresh or Watch.
+type Cache struct {
+	p   Provider
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	subs    map[string]map[int]func(value []byte)
+	nextSub int
+}
+
+type cacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewCache returns a Cache of p, the secrets are cached for ttl, or until Refresh if ttl is 0.
+func NewCache(p Provider, ttl time.Duration) *Cache {
+	return &Cache{
+		p:       p,
+		ttl:     ttl,
+		entries: map[string]*cacheEntry{},
+		subs:    map[string]map[int]func(value []byte){},
+	}
+}
+
+func (c *Cache) Get(ctx context.Context, name string) ([]byte, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[name]; ok && (c.ttl <= 0 || time.Now().Before(e.expires)) {
+		value := clone(e.value)
+		c.mu.Unlock()
+		return value, nil
+	}
+	c.mu.Unlock()
+	return c.fetch(ctx, name)
+}
+
+// OnRotate calls fn with the new value of the secret when it is changed, until cancel is called.
+// The value is a copy owned by fn.
+func (c *Cache) OnRotate(name string, fn func(value []byte)) (cancel func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextSub
+	c.nextSub++
+	if c.subs[name] == nil {
+		c.subs[name] = map[int]func(value []byte){}
+	}
+	c.subs[name][id] = fn
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.subs[name], id)
+		if len(c.subs[name]) == 0 {
+			delete(c.subs, name)
+		}
+	}
+}
+
+// Refresh refetches the cached secrets, the cached values are kept on errors, which are joined.
+func (c *Cache) Refresh(ctx context.Context) error {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.entries))
+	for name := range c.entries {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+	var errs []error
+	for _, name := range names {
+		value, err := c.fetch(ctx, name)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		Wipe(value)
+	}
+	return errors.Join(errs...)
+}
+
+// Watch refreshes the cached secrets every interval until ctx is done, the errors are reported to
+// onError, which may be nil.
+func (c *Cache) Watch(ctx context.Context, interval time.Duration, onError func(err error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.Refresh(ctx); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Invalidate wipes the cached secret, which is refetched by the next Get.
+func (c *Cache) Invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[name]; ok {
+		Wipe(e.value)
+		delete(c.entries, name)
+	}
+}
+
+// Close wipes the cached secrets.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, e := range c.entries {
+		Wipe(e.value)
+		delete(c.entries, name)
+	}
+	return nil
+}
+
+// fetch fetches and caches the secret, and notifies the subscribers if it is changed. The
+// returned value is a copy.
+func (c *Cache) fetch(ctx context.Context, name string) ([]byte, error) {
+	value, err := c.p.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	old, ok := c.entries[name]
+	c.entries[name] = &cacheEntry{value: value, expires: time.Now().Add(c.ttl)}
+	var fns []func(value []byte)
+	if ok && subtle.ConstantTimeCompare(old.value, value) != 1 {
+		for _, fn := range c.subs[name] {
+			fns = append(fns, fn)
+		}
+	}
+	if ok {
+		Wipe(old.value)
+	}
+	copies := make([][]byte, len(fns))
+	for i := range fns {
+		copies[i] = clone(value)
+	}
+	result := clone(value)
+	c.mu.Unlock()
+	for i, fn := range fns {
+		fn(copies[i])
+	}
+	return result, nil
+}
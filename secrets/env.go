@@ -0,0 +1,57 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Env reads the secrets from env, named by the prefix and the names of the secrets in upper snake
+// case, e.g. APP_MOBULA_API_KEY for mobula/apiKey with the prefix APP.
+type Env struct {
+	Prefix string
+	// LookupEnv looks up env, os.LookupEnv if nil.
+	LookupEnv func(key string) (string, bool)
+}
+
+// NewEnv returns an Env of prefix, which may be empty.
+func NewEnv(prefix string) *Env {
+	return &Env{Prefix: prefix}
+}
+
+func (e *Env) Get(_ context.Context, name string) ([]byte, error) {
+	lookup := e.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	key := e.Name(name)
+	value, ok := lookup(key)
+	if !ok {
+		return nil, fmt.Errorf("env %s: %w", key, NotFoundError)
+	}
+	return []byte(value), nil
+}
+
+// Name returns the env name of the secret.
+func (e *Env) Name(name string) string {
+	var b strings.Builder
+	if e.Prefix != "" {
+		b.WriteString(e.Prefix)
+		b.WriteByte('_')
+	}
+	rs := []rune(name)
+	for i, r := range rs {
+		switch {
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(rs[i-1]) || unicode.IsDigit(rs[i-1])):
+			b.WriteByte('_')
+			b.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToUpper(r))
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
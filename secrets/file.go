@@ -0,0 +1,37 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File reads the secrets from the files of a directory named by the secrets, e.g. the secrets
+// mounted at /run/secrets by docker or k8s. The trailing line break of a file is trimmed.
+type File struct {
+	Dir string
+}
+
+// NewFile returns a File of dir.
+func NewFile(dir string) *File {
+	return &File{Dir: dir}
+}
+
+func (f *File) Get(_ context.Context, name string) ([]byte, error) {
+	if name == "" || !filepath.IsLocal(name) || strings.Contains(name, `\`) {
+		return nil, fmt.Errorf("invalid secret name %q", name)
+	}
+	b, err := os.ReadFile(filepath.Join(f.Dir, filepath.FromSlash(name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("file %s: %w", name, NotFoundError)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b, "\r\n"), nil
+}
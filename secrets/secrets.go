@@ -0,0 +1,73 @@
+// Package secrets reads the private keys and the API keys, like the Mobula and Moralis keys, the
+// Twitter client secrets and the OSS/S3 credentials, from a Provider instead of plain strings in
+// the code or the config:
+//
+//   - Env reads env, like MOBULA_API_KEY for mobula/apiKey
+//   - File reads the files of a directory, like the secrets mounted by docker or k8s
+//   - VaultFile reads a local file encrypted by AES-GCM
+//   - Vault reads the KV v2 secrets engine of HashiCorp Vault
+//
+// Cache caches the secrets of a provider, and notifies the rotations of them. The values returned
+// are owned by the callers, who should Wipe them after use.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"runtime"
+)
+
+var NotFoundError = errors.New("secret not found")
+
+// Provider provides the secrets by their names, like mobula/apiKey. The error of a missing secret
+// is NotFoundError. The returned value is a copy owned by the caller.
+type Provider interface {
+	Get(ctx context.Context, name string) ([]byte, error)
+}
+
+// ProviderFunc adapts a function to a Provider.
+type ProviderFunc func(ctx context.Context, name string) ([]byte, error)
+
+func (f ProviderFunc) Get(ctx context.Context, name string) ([]byte, error) {
+	return f(ctx, name)
+}
+
+// GetString returns the secret as a string, for the APIs taking strings, e.g.
+// solana.GetSolPriceMobula. A string can not be wiped, prefer the bytes for the key material.
+func GetString(ctx context.Context, p Provider, name string) (string, error) {
+	b, err := p.Get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer Wipe(b)
+	return string(b), nil
+}
+
+// Chain returns a Provider trying the providers in order, until one has the secret.
+func Chain(providers ...Provider) Provider {
+	return ProviderFunc(func(ctx context.Context, name string) ([]byte, error) {
+		for _, p := range providers {
+			b, err := p.Get(ctx, name)
+			if !errors.Is(err, NotFoundError) {
+				return b, err
+			}
+		}
+		return nil, NotFoundError
+	})
+}
+
+// Wipe zeros the key material in bs.
+func Wipe(bs ...[]byte) {
+	for _, b := range bs {
+		for i := range b {
+			b[i] = 0
+		}
+		// keeps the zeroing from being optimized away
+		runtime.KeepAlive(b)
+	}
+}
+
+// clone returns a copy of b, which is not nil for an empty b.
+func clone(b []byte) []byte {
+	return append(make([]byte, 0, len(b)), b...)
+}
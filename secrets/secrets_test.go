@@ -0,0 +1,210 @@
+package secrets_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chain-products-org/goal/secrets"
+	"github.com/stretchr/testify/assert"
+)
+
+var ctx = context.Background()
+
+func TestEnv(t *testing.T) {
+	env := secrets.NewEnv("APP")
+	env.LookupEnv = func(key string) (string, bool) {
+		v, ok := map[string]string{"APP_MOBULA_API_KEY": "key"}[key]
+		return v, ok
+	}
+	assert.Equal(t, "APP_TWITTER_CLIENT_SECRET", env.Name("twitter/clientSecret"))
+	v, err := secrets.GetString(ctx, env, "mobula/apiKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "key", v)
+	_, err = env.Get(ctx, "moralis/apiKey")
+	assert.True(t, errors.Is(err, secrets.NotFoundError))
+	assert.ErrorContains(t, err, "APP_MORALIS_API_KEY")
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "oss"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "oss", "accessSecret"), []byte("s3cret\n"), 0o600))
+	f := secrets.NewFile(dir)
+
+	v, err := f.Get(ctx, "oss/accessSecret")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", string(v))
+	_, err = f.Get(ctx, "oss/accessKey")
+	assert.True(t, errors.Is(err, secrets.NotFoundError))
+	_, err = f.Get(ctx, "../etc/passwd")
+	assert.ErrorContains(t, err, "invalid secret name")
+
+	// falls back to the file
+	env := secrets.NewEnv("")
+	env.LookupEnv = func(string) (string, bool) { return "", false }
+	v, err = secrets.Chain(env, f).Get(ctx, "oss/accessSecret")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", string(v))
+}
+
+func TestWipe(t *testing.T) {
+	a, b := []byte("key"), []byte("secret")
+	secrets.Wipe(a, b, nil)
+	assert.Equal(t, []byte{0, 0, 0}, a)
+	assert.Equal(t, make([]byte, 6), b)
+}
+
+func TestVaultFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+	key, err := secrets.GenerateKey()
+	assert.NoError(t, err)
+	v, err := secrets.NewVaultFile(path, key)
+	assert.NoError(t, err)
+
+	_, err = v.Get(ctx, "solana/privateKey")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.NoError(t, v.Set("solana/privateKey", []byte("base58")))
+	assert.NoError(t, v.Set("mobula/apiKey", []byte("key")))
+	assert.NoError(t, v.Delete("mobula/apiKey"))
+	assert.NoError(t, v.Set("moralis/apiKey", []byte("key")))
+
+	value, err := v.Get(ctx, "solana/privateKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "base58", string(value))
+	names, err := v.Names()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"moralis/apiKey", "solana/privateKey"}, names)
+	_, err = v.Get(ctx, "mobula/apiKey")
+	assert.True(t, errors.Is(err, secrets.NotFoundError))
+
+	// the file is encrypted
+	bs, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(bs), "solana")
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	other, _ := secrets.GenerateKey()
+	wrong, _ := secrets.NewVaultFile(path, other)
+	_, err = wrong.Get(ctx, "solana/privateKey")
+	assert.ErrorContains(t, err, "decrypt vault file")
+	_, err = secrets.NewVaultFileWithPassphrase(path, []byte("pass")).Get(ctx, "solana/privateKey")
+	assert.ErrorContains(t, err, "encrypted by a key")
+
+	assert.NoError(t, v.Close())
+	assert.NotEqual(t, make([]byte, len(key)), key, "the key is copied")
+	_, err = v.Get(ctx, "solana/privateKey")
+	assert.ErrorContains(t, err, "closed")
+}
+
+func TestVaultFile_Passphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+	v := secrets.NewVaultFileWithPassphrase(path, []byte("correct horse"))
+	assert.NoError(t, v.Set("twitter/clientSecret", []byte("secret")))
+
+	value, err := secrets.NewVaultFileWithPassphrase(path, []byte("correct horse")).Get(ctx, "twitter/clientSecret")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(value))
+	_, err = secrets.NewVaultFileWithPassphrase(path, []byte("wrong")).Get(ctx, "twitter/clientSecret")
+	assert.ErrorContains(t, err, "decrypt vault file")
+}
+
+// rotating is a provider whose secrets are changed by set.
+type rotating struct {
+	mu     sync.Mutex
+	values map[string]string
+	gets   atomic.Int32
+}
+
+func (r *rotating) Get(_ context.Context, name string) ([]byte, error) {
+	r.gets.Add(1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.values[name]
+	if !ok {
+		return nil, secrets.NotFoundError
+	}
+	return []byte(v), nil
+}
+
+func (r *rotating) set(name, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[name] = value
+}
+
+func TestCache(t *testing.T) {
+	p := &rotating{values: map[string]string{"s3/accessSecret": "v1"}}
+	c := secrets.NewCache(p, 0)
+
+	var rotated []string
+	cancel := c.OnRotate("s3/accessSecret", func(value []byte) { rotated = append(rotated, string(value)) })
+	v, err := c.Get(ctx, "s3/accessSecret")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", string(v))
+	// the value is a copy
+	secrets.Wipe(v)
+	v, _ = c.Get(ctx, "s3/accessSecret")
+	assert.Equal(t, "v1", string(v))
+	assert.Equal(t, int32(1), p.gets.Load())
+
+	assert.NoError(t, c.Refresh(ctx))
+	assert.Empty(t, rotated)
+	p.set("s3/accessSecret", "v2")
+	assert.NoError(t, c.Refresh(ctx))
+	assert.Equal(t, []string{"v2"}, rotated)
+	v, _ = c.Get(ctx, "s3/accessSecret")
+	assert.Equal(t, "v2", string(v))
+
+	cancel()
+	p.set("s3/accessSecret", "v3")
+	assert.NoError(t, c.Refresh(ctx))
+	assert.Equal(t, []string{"v2"}, rotated)
+
+	// the cached value is kept on errors
+	p.mu.Lock()
+	delete(p.values, "s3/accessSecret")
+	p.mu.Unlock()
+	assert.True(t, errors.Is(c.Refresh(ctx), secrets.NotFoundError))
+	v, _ = c.Get(ctx, "s3/accessSecret")
+	assert.Equal(t, "v3", string(v))
+
+	c.Invalidate("s3/accessSecret")
+	_, err = c.Get(ctx, "s3/accessSecret")
+	assert.True(t, errors.Is(err, secrets.NotFoundError))
+}
+
+func TestCache_Watch(t *testing.T) {
+	p := &rotating{values: map[string]string{"mobula/apiKey": "v1"}}
+	c := secrets.NewCache(p, 20*time.Millisecond)
+	defer c.Close()
+	rotated := make(chan string, 1)
+	c.OnRotate("mobula/apiKey", func(value []byte) { rotated <- string(value) })
+	_, err := c.Get(ctx, "mobula/apiKey")
+	assert.NoError(t, err)
+
+	// refetched after the TTL
+	p.set("mobula/apiKey", "v2")
+	time.Sleep(30 * time.Millisecond)
+	v, _ := c.Get(ctx, "mobula/apiKey")
+	assert.Equal(t, "v2", string(v))
+	assert.Equal(t, "v2", <-rotated)
+
+	watchCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	c.Watch(watchCtx, 10*time.Millisecond, nil)
+	p.set("mobula/apiKey", "v3")
+	select {
+	case v := <-rotated:
+		assert.Equal(t, "v3", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rotation")
+	}
+}
@@ -0,0 +1,200 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// VaultOption configures a Vault.
+type VaultOption func(v *Vault)
+
+// WithMount sets the mount path of the KV v2 secrets engine, secret by default.
+func WithMount(mount string) VaultOption {
+	return func(v *Vault) {
+		v.mount = strings.Trim(mount, "/")
+	}
+}
+
+// WithNamespace sets the namespace of Vault Enterprise.
+func WithNamespace(namespace string) VaultOption {
+	return func(v *Vault) {
+		v.namespace = namespace
+	}
+}
+
+// WithHTTPClient sets the client of the requests, whose timeout is 10s by default.
+func WithHTTPClient(c *http.Client) VaultOption {
+	return func(v *Vault) {
+		v.client = c
+	}
+}
+
+// Vault reads the secrets from the KV v2 secrets engine of HashiCorp Vault. The name of a secret
+// is its path and the key in its data joined by #, like app/mobula#apiKey, the key is value if
+// omitted.
+type Vault struct {
+	addr      string
+	token     string
+	mount     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVault returns a Vault at addr, like https://vault.example.com:8200, authorized by token.
+func NewVault(addr, token string, opts ...VaultOption) *Vault {
+	v := &Vault{
+		addr:   strings.TrimRight(addr, "/"),
+		token:  token,
+		mount:  "secret",
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// VaultError is an error responded by Vault.
+type VaultError struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (e *VaultError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("vault: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("vault: status %d: %s", e.StatusCode, strings.Join(e.Errors, "; "))
+}
+
+type vaultData struct {
+	Data     map[string]json.RawMessage `json:"data"`
+	Metadata struct {
+		Version int `json:"version"`
+	} `json:"metadata"`
+}
+
+func (v *Vault) Get(ctx context.Context, name string) ([]byte, error) {
+	path, key := splitVaultName(name)
+	data, _, err := v.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := data[key]
+	if !ok {
+		return nil, fmt.Errorf("vault %s: %w", name, NotFoundError)
+	}
+	return []byte(value), nil
+}
+
+// Read reads the latest version of the secret at path, and the version. The values which are not
+// strings, like numbers and objects, are returned as JSON.
+func (v *Vault) Read(ctx context.Context, path string) (map[string]string, int, error) {
+	var resp struct {
+		Data vaultData `json:"data"`
+	}
+	err := v.do(ctx, http.MethodGet, "data/"+path, nil, &resp)
+	if err, ok := err.(*VaultError); ok && err.StatusCode == http.StatusNotFound {
+		return nil, 0, fmt.Errorf("vault %s: %w", path, NotFoundError)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	// the data of a deleted version is null
+	if resp.Data.Data == nil {
+		return nil, 0, fmt.Errorf("vault %s: %w", path, NotFoundError)
+	}
+	data := make(map[string]string, len(resp.Data.Data))
+	for k, raw := range resp.Data.Data {
+		var s string
+		if err = json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		data[k] = s
+	}
+	return data, resp.Data.Metadata.Version, nil
+}
+
+// Write writes data as a new version of the secret at path, e.g. to rotate a key, and returns the
+// version.
+func (v *Vault) Write(ctx context.Context, path string, data map[string]string) (int, error) {
+	var resp struct {
+		Data struct {
+			Version int `json:"version"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodPost, "data/"+path, map[string]any{"data": data}, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Data.Version, nil
+}
+
+func (v *Vault) do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		bs, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(bs)
+	}
+	u := v.addr + "/v1/" + v.mount + "/" + escapePath(path)
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &VaultError{StatusCode: resp.StatusCode}
+		var errs struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(bs, &errs) == nil {
+			e.Errors = errs.Errors
+		}
+		return e
+	}
+	defer Wipe(bs)
+	if result == nil || len(bs) == 0 {
+		return nil
+	}
+	return json.Unmarshal(bs, result)
+}
+
+func splitVaultName(name string) (path, key string) {
+	path, key, ok := strings.Cut(name, "#")
+	if !ok {
+		key = "value"
+	}
+	return strings.Trim(path, "/"), key
+}
+
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,241 @@
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/chain-products-org/goal/ciphers"
+	"golang.org/x/crypto/scrypt"
+)
+
+const vaultFileVersion = 1
+
+// the scrypt parameters recommended for interactive logins in 2017
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptSalt   = 16
+	vaultKeySize = 32
+)
+
+// vaultEnvelope is the content of a VaultFile, Data is the secrets in JSON encrypted by AES-GCM
+// with the nonce prepended.
+type vaultEnvelope struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf,omitempty"`
+	Salt    []byte `json:"salt,omitempty"`
+	Data    []byte `json:"data"`
+}
+
+// VaultFile is a local file of secrets encrypted by AES-GCM, with a key or a passphrase. It is
+// decrypted on every access, the plain secrets are not kept in memory.
+type VaultFile struct {
+	path       string
+	key        []byte
+	passphrase []byte
+
+	mu sync.Mutex
+}
+
+// GenerateKey returns a random key of a VaultFile.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, vaultKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewVaultFile returns a VaultFile at path encrypted by key of 16, 24 or 32 bytes, the file is
+// created by the first Set. key is copied.
+func NewVaultFile(path string, key []byte) (*VaultFile, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("invalid key size %d", len(key))
+	}
+	return &VaultFile{path: path, key: clone(key)}, nil
+}
+
+// NewVaultFileWithPassphrase returns a VaultFile at path encrypted by a key derived from
+// passphrase by scrypt, with a random salt on every save. passphrase is copied.
+func NewVaultFileWithPassphrase(path string, passphrase []byte) *VaultFile {
+	return &VaultFile{path: path, passphrase: clone(passphrase)}
+}
+
+func (v *VaultFile) Get(_ context.Context, name string) ([]byte, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	data, err := v.load()
+	if err != nil {
+		return nil, err
+	}
+	defer wipeMap(data)
+	value, ok := data[name]
+	if !ok {
+		return nil, fmt.Errorf("vault file %s: %w", name, NotFoundError)
+	}
+	return clone(value), nil
+}
+
+// Names returns the sorted names of the secrets.
+func (v *VaultFile) Names() ([]string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	data, err := v.load()
+	if err != nil {
+		return nil, err
+	}
+	defer wipeMap(data)
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Set sets the secret and saves the file, value is copied.
+func (v *VaultFile) Set(name string, value []byte) error {
+	return v.update(func(data map[string][]byte) {
+		Wipe(data[name])
+		data[name] = clone(value)
+	})
+}
+
+// Delete deletes the secret and saves the file.
+func (v *VaultFile) Delete(name string) error {
+	return v.update(func(data map[string][]byte) {
+		Wipe(data[name])
+		delete(data, name)
+	})
+}
+
+// Close wipes the key or the passphrase, v is not usable after.
+func (v *VaultFile) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	Wipe(v.key, v.passphrase)
+	v.key, v.passphrase = nil, nil
+	return nil
+}
+
+func (v *VaultFile) update(fn func(data map[string][]byte)) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	data, err := v.load()
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = map[string][]byte{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	defer wipeMap(data)
+	fn(data)
+	return v.save(data)
+}
+
+func (v *VaultFile) load() (map[string][]byte, error) {
+	bs, err := os.ReadFile(v.path)
+	if err != nil {
+		return nil, err
+	}
+	var env vaultEnvelope
+	if err = json.Unmarshal(bs, &env); err != nil {
+		return nil, fmt.Errorf("parse vault file: %w", err)
+	}
+	if env.Version != vaultFileVersion {
+		return nil, fmt.Errorf("unsupported vault file version %d", env.Version)
+	}
+	key, err := v.deriveKey(env.KDF, env.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if v.passphrase != nil {
+		defer Wipe(key)
+	}
+	plain, err := ciphers.AES.Decrypt(env.Data, key, ciphers.GCM, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt vault file: %w", err)
+	}
+	defer Wipe(plain)
+	data := map[string][]byte{}
+	if err = json.Unmarshal(plain, &data); err != nil {
+		return nil, fmt.Errorf("parse vault file: %w", err)
+	}
+	return data, nil
+}
+
+func (v *VaultFile) save(data map[string][]byte) error {
+	env := vaultEnvelope{Version: vaultFileVersion}
+	if v.passphrase != nil {
+		env.KDF = "scrypt"
+		env.Salt = make([]byte, scryptSalt)
+		if _, err := rand.Read(env.Salt); err != nil {
+			return err
+		}
+	}
+	key, err := v.deriveKey(env.KDF, env.Salt)
+	if err != nil {
+		return err
+	}
+	if v.passphrase != nil {
+		defer Wipe(key)
+	}
+	plain, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	defer Wipe(plain)
+	if env.Data, err = ciphers.AES.Encrypt(plain, key, ciphers.GCM, nil); err != nil {
+		return err
+	}
+	bs, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	// replaces the file atomically
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), filepath.Base(v.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(bs); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), v.path)
+}
+
+func (v *VaultFile) deriveKey(kdf string, salt []byte) ([]byte, error) {
+	switch {
+	case v.key == nil && v.passphrase == nil:
+		return nil, errors.New("vault file is closed")
+	case kdf == "" && v.key != nil:
+		return v.key, nil
+	case kdf == "scrypt" && v.passphrase != nil:
+		return scrypt.Key(v.passphrase, salt, scryptN, scryptR, scryptP, vaultKeySize)
+	case v.key != nil:
+		return nil, fmt.Errorf("vault file is encrypted by a passphrase, not by a key")
+	default:
+		return nil, fmt.Errorf("vault file is encrypted by a key, not by a passphrase")
+	}
+}
+
+func wipeMap(data map[string][]byte) {
+	for _, value := range data {
+		Wipe(value)
+	}
+}
@@ -0,0 +1,116 @@
+package secrets_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chain-products-org/goal/secrets"
+	"github.com/stretchr/testify/assert"
+)
+
+// vaultStub is a KV v2 secrets engine mounted at kv.
+type vaultStub struct {
+	mu       sync.Mutex
+	versions map[string][]map[string]any
+}
+
+func (s *vaultStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	path, ok := strings.CutPrefix(r.URL.Path, "/v1/kv/data/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errors":[]}`))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		versions := s.versions[path]
+		if len(versions) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"data":     versions[len(versions)-1],
+			"metadata": map[string]any{"version": len(versions)},
+		}})
+	case http.MethodPost:
+		var body struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.versions[path] = append(s.versions[path], body.Data)
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"version": len(s.versions[path])}})
+	}
+}
+
+func TestVault(t *testing.T) {
+	stub := &vaultStub{versions: map[string][]map[string]any{}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	v := secrets.NewVault(server.URL, "token", secrets.WithMount("kv"))
+
+	version, err := v.Write(ctx, "app/mobula", map[string]string{"apiKey": "k1", "value": "default"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+	value, err := v.Get(ctx, "app/mobula#apiKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", string(value))
+	value, err = v.Get(ctx, "app/mobula")
+	assert.NoError(t, err)
+	assert.Equal(t, "default", string(value))
+
+	_, err = v.Get(ctx, "app/mobula#secret")
+	assert.True(t, errors.Is(err, secrets.NotFoundError))
+	_, err = v.Get(ctx, "app/moralis#apiKey")
+	assert.True(t, errors.Is(err, secrets.NotFoundError))
+
+	// a rotation is found by the cache
+	c := secrets.NewCache(v, 0)
+	rotated := make(chan string, 1)
+	c.OnRotate("app/mobula#apiKey", func(value []byte) { rotated <- string(value) })
+	_, err = c.Get(ctx, "app/mobula#apiKey")
+	assert.NoError(t, err)
+	version, err = v.Write(ctx, "app/mobula", map[string]string{"apiKey": "k2"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	assert.NoError(t, c.Refresh(ctx))
+	assert.Equal(t, "k2", <-rotated)
+	data, version, err := v.Read(ctx, "app/mobula")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"apiKey": "k2"}, data)
+	assert.Equal(t, 2, version)
+
+	// the values of other types are JSON
+	stub.versions["app/db"] = []map[string]any{{"password": "p", "port": 5432, "tls": true, "hosts": []string{"a", "b"}}}
+	value, err = v.Get(ctx, "app/db#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "p", string(value))
+	value, err = v.Get(ctx, "app/db#port")
+	assert.NoError(t, err)
+	assert.Equal(t, "5432", string(value))
+	data, _, err = v.Read(ctx, "app/db")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"password": "p", "port": "5432", "tls": "true", "hosts": `["a","b"]`}, data)
+
+	_, err = secrets.NewVault(server.URL, "bad", secrets.WithMount("kv")).Get(ctx, "app/mobula#apiKey")
+	var vaultErr *secrets.VaultError
+	if assert.True(t, errors.As(err, &vaultErr)) {
+		assert.Equal(t, http.StatusForbidden, vaultErr.StatusCode)
+		assert.EqualError(t, err, "vault: status 403: permission denied")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/chain-products-org/goal/logx"
 	"github.com/chain-products-org/goal/redisx"
 	"github.com/redis/go-redis/v9"
+	"strconv"
 	"time"
 )
 
@@ -55,7 +56,119 @@ func NewMiniRedis() redisx.Client {
 	return client
 }
 
+// NewRedisCluster starts a local stand-in of a redis cluster, 3 miniredis nodes each serving a
+// third of the hash slots, and returns a cluster client of it. Like a real cluster, scripts and
+// multi-key commands whose keys hash to different slots fail with a CROSSSLOT error.
 func NewRedisCluster() redisx.Client {
-	// TODO mock redis cluster
+	const nodes = 3
+	var slots []redis.ClusterSlot
+	per := redisx.SlotCount / nodes
+	for i := 0; i < nodes; i++ {
+		mr, err := miniredis.Run()
+		if err != nil {
+			panic(fmt.Errorf("new test redis cluster error: %v", err))
+		}
+		end := (i+1)*per - 1
+		if i == nodes-1 {
+			end = redisx.SlotCount - 1
+		}
+		slots = append(slots, redis.ClusterSlot{
+			Start: i * per,
+			End:   end,
+			Nodes: []redis.ClusterNode{{ID: fmt.Sprint("node", i), Addr: mr.Addr()}},
+		})
+	}
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(ctx context.Context) ([]redis.ClusterSlot, error) {
+			return slots, nil
+		},
+		MaxRetries: 5,
+	})
+	client.AddHook(crossSlotHook{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.ForEachShard(ctx, func(ctx context.Context, shard *redis.Client) error {
+		return shard.Ping(ctx).Err()
+	}); err != nil {
+		panic(fmt.Sprintf("Redis cluster error: %s", err.Error()))
+	}
+	return client
+}
+
+// crossSlotHook rejects the commands whose keys hash to different slots, which a cluster node
+// would reject, while miniredis serves any key.
+type crossSlotHook struct{}
+
+func (crossSlotHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (crossSlotHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := checkSlot(cmd); err != nil {
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (crossSlotHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			if err := checkSlot(cmd); err != nil {
+				return err
+			}
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func checkSlot(cmd redis.Cmder) error {
+	keys := cmdKeys(cmd)
+	if !redisx.SameSlot(keys...) {
+		err := fmt.Errorf("%w: %s %v", redisx.CrossSlotError, cmd.Name(), keys)
+		cmd.SetErr(err)
+		return err
+	}
+	return nil
+}
+
+// cmdKeys returns the keys of scripts and of the common multi-key commands.
+func cmdKeys(cmd redis.Cmder) []string {
+	args := cmd.Args()
+	strs := func(args []any) []string {
+		keys := make([]string, len(args))
+		for i, arg := range args {
+			keys[i] = fmt.Sprint(arg)
+		}
+		return keys
+	}
+	switch cmd.Name() {
+	case "eval", "evalsha", "eval_ro", "evalsha_ro", "fcall", "fcall_ro":
+		if len(args) < 3 {
+			return nil
+		}
+		n, _ := strconv.Atoi(fmt.Sprint(args[2]))
+		if n > len(args)-3 {
+			n = len(args) - 3
+		}
+		return strs(args[3 : 3+n])
+	case "del", "unlink", "exists", "mget", "touch", "watch", "pfcount", "pfmerge",
+		"sunion", "sinter", "sdiff", "sunionstore", "sinterstore", "sdiffstore":
+		return strs(args[1:])
+	case "blpop", "brpop":
+		return strs(args[1 : len(args)-1])
+	case "rename", "renamenx", "rpoplpush", "lmove", "smove", "copy":
+		if len(args) >= 3 {
+			return strs(args[1:3])
+		}
+	case "mset", "msetnx":
+		var keys []string
+		for i := 1; i < len(args); i += 2 {
+			keys = append(keys, fmt.Sprint(args[i]))
+		}
+		return keys
+	}
 	return nil
 }
@@ -0,0 +1,190 @@
+package testx
+
+import (
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SMTPMessage is a mail received by SMTPServer.
+type SMTPMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// SMTPServer is a fake in-process SMTP server, it accepts any credentials, and keeps the
+// received mails in memory.
+type SMTPServer struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []SMTPMessage
+	conns    int
+	failNext int
+}
+
+// NewSMTPServer starts a SMTPServer on a random local port.
+func NewSMTPServer() *SMTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	s := &SMTPServer{ln: ln}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Host returns the host of the server.
+func (s *SMTPServer) Host() string {
+	return s.ln.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port of the server.
+func (s *SMTPServer) Port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// Messages returns the received mails.
+func (s *SMTPServer) Messages() []SMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SMTPMessage(nil), s.messages...)
+}
+
+// Conns returns the number of accepted connections.
+func (s *SMTPServer) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// FailNext rejects the next n mails with a temporary error.
+func (s *SMTPServer) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext = n
+}
+
+// Close stops the server and closes the connections.
+func (s *SMTPServer) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *SMTPServer) serve() {
+	defer s.wg.Done()
+	var conns sync.WaitGroup
+	var mu sync.Mutex
+	open := map[net.Conn]struct{}{}
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			break
+		}
+		s.mu.Lock()
+		s.conns++
+		s.mu.Unlock()
+		mu.Lock()
+		open[conn] = struct{}{}
+		mu.Unlock()
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			s.handle(conn)
+			mu.Lock()
+			delete(open, conn)
+			mu.Unlock()
+		}()
+	}
+	mu.Lock()
+	for conn := range open {
+		_ = conn.Close()
+	}
+	mu.Unlock()
+	conns.Wait()
+}
+
+func (s *SMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(code int, msg string) bool {
+		return tp.PrintfLine("%d %s", code, msg) == nil
+	}
+	if !reply(220, "testx ESMTP") {
+		return
+	}
+	var msg SMTPMessage
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		var ok bool
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			ok = tp.PrintfLine("250-testx") == nil && reply(250, "AUTH PLAIN")
+		case "HELO", "NOOP":
+			ok = reply(250, "OK")
+		case "AUTH":
+			ok = reply(235, "authenticated")
+		case "MAIL":
+			msg = SMTPMessage{From: address(arg)}
+			ok = reply(250, "OK")
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+			ok = reply(250, "OK")
+		case "RSET":
+			msg = SMTPMessage{}
+			ok = reply(250, "OK")
+		case "DATA":
+			if !reply(354, "end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			ok = s.receive(msg, reply)
+			msg = SMTPMessage{}
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			ok = reply(502, "command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+func (s *SMTPServer) receive(msg SMTPMessage, reply func(code int, msg string) bool) bool {
+	s.mu.Lock()
+	fail := s.failNext > 0
+	if fail {
+		s.failNext--
+	} else {
+		s.messages = append(s.messages, msg)
+	}
+	n := len(s.messages)
+	s.mu.Unlock()
+	if fail {
+		return reply(451, "try again later")
+	}
+	return reply(250, "queued as "+strconv.Itoa(n))
+}
+
+// address returns the address of a "FROM:<addr>" or "TO:<addr>" argument.
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
@@ -0,0 +1,132 @@
+// Package sqlitex tests repositories against an in-memory SQLite database. It is apart from testx
+// as it needs cgo.
+package sqlitex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chain-products-org/goal/gormx"
+	"gopkg.in/yaml.v3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var sqliteSeq int64
+
+// SqliteDB is an in-memory SQLite database for testing repositories against real SQL, without
+// hand-written sqlmock expectations.
+type SqliteDB struct {
+	DB *gorm.DB
+}
+
+// NewSqlite opens a new, empty, in-memory SQLite database and auto-migrates the models.
+//
+//	var sqlite = sqlitex.NewSqlite(&User{}, &Order{})
+//
+//	func TestUserRepo(t *testing.T) {
+//		ctx := sqlite.Tx(t, "testdata/users.yaml")
+//		repo := gormx.NewRepo[User](sqlite.DB)
+//		users, err := repo.Find(ctx)
+//		...
+//	}
+func NewSqlite(models ...any) *SqliteDB {
+	dsn := fmt.Sprintf("file:testx_%d?mode=memory&cache=shared", atomic.AddInt64(&sqliteSeq, 1))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+	if err != nil {
+		panic(fmt.Errorf("failed to open sqlite: %v", err))
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic(fmt.Errorf("failed to open sqlite: %v", err))
+	}
+	// a single connection keeps the database alive, and serializes the test transactions
+	sqlDB.SetMaxOpenConns(1)
+	if err = db.AutoMigrate(models...); err != nil {
+		panic(fmt.Errorf("failed to migrate sqlite: %v", err))
+	}
+	return &SqliteDB{DB: db}
+}
+
+// rollbackError rolls back the transaction of a test.
+var rollbackError = errors.New("rollback the test transaction")
+
+// Tx begins a transaction by gormx.WithTxCtx, which is rolled back when the test finishes, loads
+// the fixtures in it, see LoadFixtures, and returns the context carrying it. Repositories called
+// with the context join the transaction, and the transactions of the tested code, including
+// gormx.WithTx, run as savepoints of it. The AfterCommit hooks are discarded with the rollback.
+func (s *SqliteDB) Tx(t testing.TB, fixtures ...string) context.Context {
+	t.Helper()
+	began := make(chan context.Context)
+	done := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		result <- gormx.WithTxCtx(context.Background(), s.DB, func(ctx context.Context) error {
+			began <- ctx
+			<-done
+			return rollbackError
+		}, gormx.TxMaxRetries(0))
+	}()
+	var ctx context.Context
+	select {
+	case ctx = <-began:
+	case err := <-result:
+		t.Fatalf("failed to begin sqlite transaction: %v", err)
+	}
+	t.Cleanup(func() {
+		close(done)
+		<-result
+	})
+	if err := LoadFixtures(gormx.DB(ctx, s.DB), fixtures...); err != nil {
+		t.Fatalf("failed to load fixtures: %v", err)
+	}
+	return ctx
+}
+
+// LoadFixtures inserts the rows of the YAML fixture files, which map table names to rows, in
+// the order of the files and tables:
+//
+//	users:
+//	  - id: 1
+//	    name: tom
+//	orders:
+//	  - id: 1
+//	    user_id: 1
+func LoadFixtures(db *gorm.DB, files ...string) error {
+	for _, file := range files {
+		bs, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var doc yaml.Node
+		if err = yaml.Unmarshal(bs, &doc); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		tables := doc.Content[0]
+		if tables.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: fixtures must map table names to rows", file)
+		}
+		// decode pair by pair to keep the order of the tables, e.g. for foreign keys
+		for i := 0; i+1 < len(tables.Content); i += 2 {
+			table := tables.Content[i].Value
+			var rows []map[string]any
+			if err = tables.Content[i+1].Decode(&rows); err != nil {
+				return fmt.Errorf("%s: table %s: %w", file, table, err)
+			}
+			for _, row := range rows {
+				if err = db.Table(table).Create(row).Error; err != nil {
+					return fmt.Errorf("%s: table %s: %w", file, table, err)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package sqlitex_test
+
+import (
+	"testing"
+
+	"github.com/chain-products-org/goal/gormx"
+	"github.com/chain-products-org/goal/testx/sqlitex"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type User struct {
+	ID   uint64
+	Name string `gorm:"uniqueIndex"`
+	Age  int
+}
+
+var sqlite = sqlitex.NewSqlite(&User{})
+
+func TestSqlite_Fixtures(t *testing.T) {
+	ctx := sqlite.Tx(t, "testdata/users.yaml")
+	repo := gormx.NewRepo[User](sqlite.DB)
+
+	users, err := repo.Find(ctx, gormx.Where("age >= ?", 20))
+	assert.Nil(t, err)
+	assert.Equal(t, []User{{ID: 1, Name: "tom", Age: 20}}, users)
+
+	_, err = repo.Create(ctx, &User{Name: "tom"})
+	assert.NotNil(t, err, "unique index must be enforced")
+
+	err = gormx.WithTx(ctx, sqlite.DB, func(tx *gorm.DB) error {
+		return tx.Create(&User{Name: "spike"}).Error
+	})
+	assert.Nil(t, err)
+	n, _ := repo.Count(ctx)
+	assert.Equal(t, int64(3), n)
+}
+
+func TestSqlite_Rollback(t *testing.T) {
+	t.Run("insert", func(t *testing.T) {
+		ctx := sqlite.Tx(t)
+		assert.Nil(t, gormx.DB(ctx, sqlite.DB).Create(&User{Name: "tyke"}).Error)
+	})
+	var n int64
+	assert.Nil(t, sqlite.DB.Model(&User{}).Count(&n).Error)
+	assert.Equal(t, int64(0), n, "changes of a test must be rolled back")
+}
+
+func TestSqlite_Update(t *testing.T) {
+	ctx := sqlite.Tx(t, "testdata/users.yaml")
+	repo := gormx.NewRepo[User](sqlite.DB)
+
+	// the zero fields are not updated
+	u, err := repo.Update(ctx, &User{ID: 1, Age: 21})
+	assert.Nil(t, err)
+	assert.Equal(t, 21, u.Age)
+	got, _ := repo.Get(ctx, 1)
+	assert.Equal(t, &User{ID: 1, Name: "tom", Age: 21}, got)
+	_, err = repo.Update(ctx, &User{ID: 3, Age: 21})
+	assert.ErrorIs(t, err, gormx.UpdateError)
+
+	assert.Nil(t, repo.UpdateFields(ctx, 2, map[string]any{"age": 19}))
+	got, _ = repo.Get(ctx, 2)
+	assert.Equal(t, &User{ID: 2, Name: "jerry", Age: 19}, got)
+
+	// all the fields are saved
+	_, err = repo.Save(ctx, &User{ID: 1, Name: "tommy"})
+	assert.Nil(t, err)
+	got, _ = repo.Get(ctx, 1)
+	assert.Equal(t, &User{ID: 1, Name: "tommy"}, got)
+	got, _ = repo.Get(ctx, 2)
+	assert.Equal(t, "jerry", got.Name, "other rows must be kept")
+	u, err = repo.Save(ctx, &User{Name: "spike", Age: 3})
+	assert.Nil(t, err)
+	assert.NotZero(t, u.ID)
+	n, _ := repo.Count(ctx)
+	assert.Equal(t, int64(3), n)
+}
@@ -225,9 +225,9 @@ func (o *OAuth2AuthApi) RevokeAccessToken(clientId, clientSecret string, token s
 type AccessToken struct {
 	TokenType    string `json:"token_type"`
 	ExpiresIn    int64  `json:"expires_in"`
-	AccessToken  string `json:"access_token"`
+	AccessToken  string `json:"access_token" log:"secret"`
 	Scope        string `json:"scope"`
-	RefreshToken string `json:"refresh_token"`
+	RefreshToken string `json:"refresh_token" log:"secret"`
 }
 
 // AppOnlyToken to get app-only token through consumer keys(api key and secret).
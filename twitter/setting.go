@@ -3,12 +3,12 @@ package twitter
 type Setting struct {
 	// oauth 2.0
 	ClientId     string `mapstructure:"clientId" yaml:"clientId"`
-	ClientSecret string `mapstructure:"clientSecret" yaml:"clientSecret"`
+	ClientSecret string `mapstructure:"clientSecret" yaml:"clientSecret" log:"secret"`
 	// oauth 1.0
 	ApiKey            string `mapstructure:"apiKey" yaml:"apiKey"`
-	ApiSecret         string `mapstructure:"apiSecret" yaml:"apiSecret"`
-	AccessToken       string `mapstructure:"accessToken" yaml:"accessToken"`
-	AccessTokenSecret string `mapstructure:"accessTokenSecret" yaml:"accessTokenSecret"`
+	ApiSecret         string `mapstructure:"apiSecret" yaml:"apiSecret" log:"secret"`
+	AccessToken       string `mapstructure:"accessToken" yaml:"accessToken" log:"secret"`
+	AccessTokenSecret string `mapstructure:"accessTokenSecret" yaml:"accessTokenSecret" log:"secret"`
 	// app only access token
-	BearerToken string `mapstructure:"bearerToken" yaml:"bearerToken"`
+	BearerToken string `mapstructure:"bearerToken" yaml:"bearerToken" log:"secret"`
 }
@@ -17,11 +17,12 @@ import (
 	"github.com/gagliardetto/solana-go/text"
 	"github.com/chain-products-org/goal/errorx"
 	"github.com/chain-products-org/goal/httpx"
+	"github.com/chain-products-org/goal/secrets"
 )
 
 type SOLWallet struct {
 	PublicKey  string
-	PrivateKey string
+	PrivateKey string `log:"secret"`
 }
 
 func CreateWallet() *SOLWallet {
@@ -41,6 +42,21 @@ func ImportFromPrivateKey(filePath string) (*SOLWallet, error) {
 	return &SOLWallet{PrivateKey: account.PrivateKey.String(), PublicKey: account.PublicKey().String()}, nil
 }
 
+// ImportFromSecret imports the wallet of the base58 private key named name in p, e.g. a
+// secrets.VaultFile, instead of a keygen file in plain text.
+func ImportFromSecret(ctx context.Context, p secrets.Provider, name string) (*SOLWallet, error) {
+	b, err := p.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer secrets.Wipe(b)
+	account, err := solana.WalletFromPrivateKeyBase58(string(b))
+	if err != nil {
+		return nil, err
+	}
+	return &SOLWallet{PrivateKey: account.PrivateKey.String(), PublicKey: account.PublicKey().String()}, nil
+}
+
 func (w *SOLWallet) GetAirdrop(sol float64, rpcUrl string) (string, error) {
 	client := rpc.New(rpcUrl)
 	// Airdrop 1 SOL to the new account: